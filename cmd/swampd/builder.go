@@ -1,18 +1,55 @@
 package main
 
 import (
-	"path/filepath"
-	"time"
-
-	"github.com/blugelabs/bluge"
-	"github.com/rubiojr/rapi/repository"
-	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/enrichers"
 )
 
-type FileDocumentBuilder struct{}
+// newPipeline returns the document pipeline configured in config.yaml, if
+// swamp is configured, with the enrichers given with --enable and --disable
+// overriding the configuration
+func newPipeline(enable, disable []string) (*enrichers.Pipeline, error) {
+	cfg, err := pipelineConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range enable {
+		cfg.Disable = without(cfg.Disable, name)
+		cfg.Enable = append(cfg.Enable, name)
+	}
+	for _, name := range disable {
+		cfg.Enable = without(cfg.Enable, name)
+		cfg.Disable = append(cfg.Disable, name)
+	}
+
+	return enrichers.NewPipeline(cfg)
+}
+
+func pipelineConfig() (config.Pipeline, error) {
+	if !config.Exists() {
+		return config.Pipeline{}, nil
+	}
+	c, err := config.Init()
+	if err != nil {
+		return config.Pipeline{}, err
+	}
+
+	// copied, so the configuration isn't modified
+	return config.Pipeline{
+		Enable:  append([]string{}, c.Pipeline.Enable...),
+		Disable: append([]string{}, c.Pipeline.Disable...),
+		Options: c.Pipeline.Options,
+	}, nil
+}
+
+func without(names []string, name string) []string {
+	kept := names[:0]
+	for _, n := range names {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
 
-func (i FileDocumentBuilder) BuildDocument(fileID string, node *restic.Node, repo *repository.Repository) *bluge.Document {
-	return bluge.NewDocument(fileID).
-		AddField(bluge.NewTextField("ext", filepath.Ext(node.Name)).StoreValue()).
-		AddField(bluge.NewDateTimeField("updated", time.Now()).StoreValue())
+	return kept
 }
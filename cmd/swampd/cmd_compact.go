@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/indexer"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "compact",
+		Usage:  "Rewrite indices to reclaim the space of removed documents",
+		Action: compactIndices,
+		Flags:  repositoryFlags("compact"),
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func compactIndices(c *cli.Context) error {
+	if indexer.IsRunning() {
+		return errors.New("swampd is indexing, compact the index when it finishes")
+	}
+
+	jobs, err := indexJobs(c)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		before, err := index.DiskSize(j.indexPath)
+		if err != nil {
+			return err
+		}
+		if err := index.Compact(j.indexPath); err != nil {
+			return fmt.Errorf("error compacting %s: %w", j.name, err)
+		}
+		after, err := index.DiskSize(j.indexPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf(
+			"%s: %s -> %s\n",
+			j.name,
+			humanize.Bytes(uint64(before)),
+			humanize.Bytes(uint64(after)),
+		)
+	}
+
+	return nil
+}
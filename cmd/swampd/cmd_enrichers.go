@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/swampapp/swamp/internal/enrichers"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "enrichers",
+		Usage:  "List the enrichers of the document pipeline",
+		Action: listEnrichers,
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func listEnrichers(c *cli.Context) error {
+	cfg, err := pipelineConfig()
+	if err != nil {
+		return err
+	}
+	// validates the configuration
+	if _, err := enrichers.NewPipeline(cfg); err != nil {
+		return err
+	}
+
+	for _, e := range enrichers.Registered() {
+		state := "disabled"
+		if enrichers.Enabled(e, cfg) {
+			state = "enabled"
+		}
+		fmt.Printf("%-10s %-8s %-9s %s\n", e.Name(), e.Cost(), state, strings.Join(e.Fields(), ", "))
+	}
+
+	return nil
+}
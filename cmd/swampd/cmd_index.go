@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,8 +21,13 @@ import (
 	"github.com/muesli/reflow/truncate"
 	"github.com/prometheus/procfs"
 	"github.com/rubiojr/rindex"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/credentials"
+	"github.com/swampapp/swamp/internal/enrichers"
+	"github.com/swampapp/swamp/internal/index"
 	"github.com/swampapp/swamp/internal/indexer"
 	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/tags"
 	"github.com/urfave/cli/v2"
 )
 
@@ -55,12 +61,44 @@ func init() {
 				Usage:    "Monitor progress",
 				Required: false,
 			},
+			&cli.StringSliceFlag{
+				Name:     "repository-id",
+				Usage:    "ID of a configured repository to index, can be repeated",
+				Required: false,
+			},
+			&cli.BoolFlag{
+				Name:     "all",
+				Usage:    "Index every configured repository",
+				Required: false,
+			},
+			&cli.IntFlag{
+				Name:     "concurrency",
+				Usage:    "Number of repositories indexed in parallel",
+				Value:    1,
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "skip-tag-rules",
+				Usage:    "ID of a repository whose tag rules are applied by the app, can be repeated",
+				Hidden:   true,
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "enable",
+				Usage:    "Enable an enricher of the document pipeline, can be repeated",
+				Required: false,
+			},
+			&cli.StringSliceFlag{
+				Name:     "disable",
+				Usage:    "Disable an enricher of the document pipeline, can be repeated",
+				Required: false,
+			},
 		},
 	}
 	appCommands = append(appCommands, cmd)
 }
 
-func socketServer(cancel context.CancelFunc, progress chan rindex.IndexStats) error {
+func socketServer(cancel context.CancelFunc, tracker *jobTracker) error {
 	f := fiber.New(
 		fiber.Config{
 			DisableStartupMessage: true,
@@ -99,15 +137,21 @@ func socketServer(cancel context.CancelFunc, progress chan rindex.IndexStats) er
 		return c.JSON(s)
 	})
 
-	var stats rindex.IndexStats
-	go func() {
-		for stats = range progress {
-			time.Sleep(100 * time.Millisecond)
-		}
-	}()
-
 	f.Get("/stats", func(c *fiber.Ctx) error {
-		return c.JSON(stats)
+		return c.JSON(tracker.stats())
+	})
+
+	f.Get("/stats/repos", func(c *fiber.Ctx) error {
+		return c.JSON(tracker.reposStats())
+	})
+
+	f.Post("/cancel/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if !tracker.cancel(id) {
+			return c.Status(fiber.StatusNotFound).SendString("repository not found")
+		}
+		logger.Debugf("swampd was told to stop indexing %s", id)
+		return c.SendString("cancelled")
 	})
 
 	f.Get("/pid", func(c *fiber.Ctx) error {
@@ -167,36 +211,58 @@ func indexRepo(cli *cli.Context) error {
 		logger.Error(err, "error running statsviz")
 	}
 
-	progress := make(chan rindex.IndexStats, 10)
-	idx, err := rindex.New(indexPath, globalOptions.Repo, globalOptions.Password)
+	jobs, err := indexJobs(cli)
 	if err != nil {
 		return err
 	}
-	idxOpts := rindex.DefaultIndexOptions
-	idxOpts.BatchSize = batchSize
-	idxOpts.DocumentBuilder = FileDocumentBuilder{}
-	idxOpts.Reindex = cli.Bool("reindex")
+	pipeline, err := newPipeline(cli.StringSlice("enable"), cli.StringSlice("disable"))
+	if err != nil {
+		return err
+	}
+	for _, id := range cli.StringSlice("skip-tag-rules") {
+		for _, j := range jobs {
+			if j.id == id {
+				j.skipTagRules = true
+			}
+		}
+	}
+	tracker := newJobTracker(jobs)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
-		if err := socketServer(cancel, progress); err != nil {
+		if err := socketServer(cancel, tracker); err != nil {
 			logger.Error(err, "socket server returned an error")
 		}
 	}()
 
 	if cli.Bool("monitor") {
-		go progressMonitor(cli.Bool("log-errors"), progress)
+		go progressMonitor(cli.Bool("log-errors"))
 	}
 
-	stats, err := idx.Index(ctx, idxOpts, progress)
-	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			logger.Fatal(err, "indexing process aborted with an unknown error")
+	concurrency := cli.Int("concurrency")
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
 		}
-		logger.Print("indexing stopped")
+		wg.Add(1)
+		go func(j *indexJob) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			runJob(ctx, j, tracker, cli.Bool("reindex"), pipeline)
+		}(j)
 	}
+	wg.Wait()
 
+	stats := tracker.stats()
 	if cli.Bool("monitor") {
 		fmt.Printf(
 			"\n💥 %d indexed, %d already present. %d new snapshots. Took %d seconds.\n",
@@ -218,7 +284,153 @@ func indexRepo(cli *cli.Context) error {
 	return os.Remove(indexer.SocketPath())
 }
 
-func progressMonitor(logErrors bool, progress chan rindex.IndexStats) {
+// indexJobs returns the repositories to index.
+//
+// Configured repositories are indexed when --all or --repository-id are
+// used, reading the credentials from the keyring. Otherwise the repository
+// given with --repo and --index-path is indexed.
+func indexJobs(cli *cli.Context) ([]*indexJob, error) {
+	ids := cli.StringSlice("repository-id")
+	if !cli.Bool("all") && len(ids) == 0 {
+		if indexPath == "" {
+			return nil, errors.New("--index-path is required to index a single repository")
+		}
+		return []*indexJob{
+			{
+				id:        "default",
+				name:      globalOptions.Repo,
+				indexPath: indexPath,
+				repo:      globalOptions.Repo,
+				password:  globalOptions.Password,
+			},
+		}, nil
+	}
+
+	if !config.Exists() {
+		return nil, errors.New("swamp needs to be configured first")
+	}
+	cfg, err := config.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	jobs := []*indexJob{}
+	for _, r := range cfg.ListRepositories() {
+		if !cli.Bool("all") && !wanted[r.ID] {
+			continue
+		}
+		delete(wanted, r.ID)
+		rs := credentials.New(r.ID)
+		jobs = append(jobs, &indexJob{
+			id:        r.ID,
+			name:      r.Name,
+			indexPath: index.PathFor(r.ID),
+			repo:      rs.Repository,
+			password:  rs.Password,
+			var1:      rs.Var1,
+			var2:      rs.Var2,
+		})
+	}
+
+	for id := range wanted {
+		return nil, fmt.Errorf("repository %s not found in the configuration", id)
+	}
+
+	return jobs, nil
+}
+
+func runJob(ctx context.Context, j *indexJob, tracker *jobTracker, reindex bool, pipeline *enrichers.Pipeline) {
+	jctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if !tracker.start(j, cancel) {
+		logger.Infof("indexing %s cancelled before starting", j.name)
+		return
+	}
+
+	schema, err := index.Migrate(j.indexPath)
+	if err != nil {
+		tracker.setState(j.id, indexer.StateFailed)
+		logger.Errorf(err, "error migrating the index of %s", j.name)
+		return
+	}
+	if schema.Rebuild {
+		logger.Infof("rebuilding the index of %s after migrating it", j.name)
+		reindex = true
+	}
+
+	logger.Infof("indexing repository %s", j.name)
+	idx, err := j.openIndex(false)
+	if err != nil {
+		tracker.setState(j.id, indexer.StateFailed)
+		logger.Errorf(err, "error opening repository %s", j.name)
+		return
+	}
+
+	idxOpts := rindex.DefaultIndexOptions
+	idxOpts.BatchSize = batchSize
+	idxOpts.DocumentBuilder = pipeline
+	idxOpts.Reindex = reindex
+
+	progress := make(chan rindex.IndexStats, 10)
+	go func() {
+		for stats := range progress {
+			tracker.setStats(j.id, stats)
+		}
+	}()
+
+	stats, err := idx.Index(jctx, idxOpts, progress)
+	tracker.setStats(j.id, stats)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			tracker.setState(j.id, indexer.StateCancelled)
+			logger.Infof("indexing %s stopped", j.name)
+		} else {
+			tracker.setState(j.id, indexer.StateFailed)
+			logger.Errorf(err, "error indexing %s", j.name)
+		}
+		return
+	}
+
+	tracker.setState(j.id, indexer.StateDone)
+	if schema.Rebuild {
+		schema.Rebuild = false
+		if err := schema.Save(j.indexPath); err != nil {
+			logger.Errorf(err, "error saving the index schema of %s", j.name)
+		}
+	}
+	logger.Infof(
+		"%s: %d indexed, %d already present. %d new snapshots.",
+		j.name,
+		stats.IndexedFiles,
+		stats.AlreadyIndexed,
+		stats.ScannedSnapshots,
+	)
+
+	// single repositories given with --repo aren't configured, they have no
+	// tag rules. The app applies the rules of the repositories whose tags
+	// database it holds open.
+	if j.id == "default" || j.skipTagRules {
+		return
+	}
+	tagged, err := tags.ApplyRules(j.id, idx)
+	if err != nil {
+		// rules are only marked as applied on success, so new files are
+		// tagged the next time
+		logger.Errorf(err, "error applying tag rules to %s", j.name)
+		return
+	}
+	if tagged > 0 {
+		logger.Infof("%s: %d files tagged by tag rules", j.name, tagged)
+	}
+}
+
+func progressMonitor(logErrors bool) {
 	s := spinner.New(spinner.CharSets[11], 200*time.Millisecond)
 	//nolint
 	s.Color("fgGreen")
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "migrate",
+		Usage:  "Upgrade indices to the current schema version without indexing",
+		Action: migrateIndices,
+		Flags:  repositoryFlags("migrate"),
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// repositoryFlags returns the flags selecting the configured repositories
+// the command acts on
+func repositoryFlags(action string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "repository-id",
+			Usage:    "ID of a configured repository to " + action + ", can be repeated",
+			Required: false,
+		},
+		&cli.BoolFlag{
+			Name:     "all",
+			Usage:    "Apply to every configured repository",
+			Required: false,
+		},
+	}
+}
+
+func migrateIndices(c *cli.Context) error {
+	jobs, err := indexJobs(c)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		schema, err := index.Migrate(j.indexPath)
+		if err != nil {
+			return fmt.Errorf("error migrating the index of %s: %w", j.name, err)
+		}
+		fmt.Printf("%s: schema version %d", j.name, schema.Version)
+		if schema.Rebuild {
+			fmt.Print(", rebuilt the next time it's indexed")
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/schedule"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "schedule",
+		Usage:  "Show when configured repositories are indexed automatically",
+		Action: showSchedule,
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func showSchedule(c *cli.Context) error {
+	if !config.Exists() {
+		return errors.New("swamp needs to be configured first")
+	}
+	cfg, err := config.Init()
+	if err != nil {
+		return err
+	}
+
+	state, err := schedule.LoadState()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range cfg.ListRepositories() {
+		fmt.Printf("%s (%s)\n", r.Name, r.ID)
+
+		s, err := schedule.Parse(r.Schedule)
+		if err != nil {
+			fmt.Printf("  schedule: %s\n\n", err)
+			continue
+		}
+		fmt.Printf("  schedule: %s\n", s)
+
+		rs := state[r.ID]
+		fmt.Printf("  last run: %s\n", formatRunTime(rs.LastRun))
+
+		next := rs.NextRun
+		if !rs.LastRun.IsZero() {
+			next = s.Next(rs.LastRun)
+		} else if next.IsZero() && s.Kind == schedule.Cron {
+			next = s.Next(time.Now())
+		}
+		switch {
+		case s.Kind == schedule.Never:
+			fmt.Printf("  next run: never\n\n")
+		case next.IsZero():
+			fmt.Printf("  next run: one interval after swamp starts\n\n")
+		case next.Before(time.Now()):
+			fmt.Printf("  next run: overdue since %s\n\n", formatRunTime(next))
+		default:
+			fmt.Printf("  next run: %s\n\n", formatRunTime(next))
+		}
+	}
+
+	return nil
+}
+
+func formatRunTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("Jan 2 15:04 2006")
+}
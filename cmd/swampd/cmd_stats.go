@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "index-stats",
+		Usage:  "Show the documents, size and snapshots covered of indices",
+		Action: showIndexStats,
+		Flags: append(repositoryFlags("show"), &cli.BoolFlag{
+			Name:     "offline",
+			Usage:    "Don't open the repository to count the snapshots covered",
+			Required: false,
+		}),
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func showIndexStats(c *cli.Context) error {
+	jobs, err := indexJobs(c)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		fmt.Printf("%s (%s)\n", j.name, j.id)
+
+		stats, err := index.ReadStats(j.indexPath)
+		if err != nil {
+			fmt.Printf("  error: %s\n\n", err)
+			continue
+		}
+		schema, err := index.LoadSchema(j.indexPath)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("  documents: %d\n", stats.Documents)
+		fmt.Printf("  size:      %s\n", humanize.Bytes(uint64(stats.Size)))
+		fmt.Printf("  schema:    version %d\n", schema.Version)
+		if !c.Bool("offline") {
+			fmt.Printf("  snapshots: %s\n", snapshotsCovered(context.Background(), j))
+		}
+
+		fields := make([]string, 0, len(stats.Fields))
+		for name, count := range stats.Fields {
+			fields = append(fields, fmt.Sprintf("%s (%d)", name, count))
+		}
+		sort.Strings(fields)
+		fmt.Printf("  fields:    %s\n\n", strings.Join(fields, ", "))
+	}
+
+	return nil
+}
+
+// snapshotsCovered describes how many snapshots of the repository are
+// indexed
+func snapshotsCovered(ctx context.Context, j *indexJob) string {
+	repo, err := j.openRepository()
+	if err != nil {
+		return err.Error()
+	}
+	total := 0
+	err = repo.List(ctx, restic.SnapshotFile, func(restic.ID, int64) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return err.Error()
+	}
+
+	idx, err := j.openIndex(true)
+	if err != nil {
+		return err.Error()
+	}
+	missing, err := idx.MissingSnapshots(ctx)
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%d of %d indexed", total-len(missing), total)
+}
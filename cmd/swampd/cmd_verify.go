@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/indexer"
+	"github.com/swampapp/swamp/internal/snapshots"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "verify",
+		Usage:  "Check the indexed files against the repository snapshots",
+		Action: verifyIndices,
+		Flags: append(repositoryFlags("verify"), &cli.BoolFlag{
+			Name:     "verbose",
+			Usage:    "List the files with problems",
+			Required: false,
+		}),
+	}
+	appCommands = append(appCommands, cmd)
+
+	cmd = &cli.Command{
+		Name:   "prune",
+		Usage:  "Remove the files no longer found in any snapshot from the index",
+		Action: pruneIndices,
+		Flags: append(repositoryFlags("prune"), &cli.BoolFlag{
+			Name:     "dry-run",
+			Usage:    "List the files that would be removed",
+			Required: false,
+		}),
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// indexCheck is the result of checking the indexed files against the
+// snapshots of the repository
+type indexCheck struct {
+	index.Check
+	snapshots int
+}
+
+func checkIndex(ctx context.Context, j *indexJob) (*indexCheck, error) {
+	repo, err := j.openRepository()
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.LoadIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	found, err := snapshots.AllFiles(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := index.CheckFiles(j.indexPath, found.Contains, func(blob string) bool {
+		id, err := restic.ParseID(blob)
+		if err != nil {
+			return false
+		}
+		_, ok := repo.LookupBlobSize(id, restic.DataBlob)
+		return ok
+	})
+
+	return &indexCheck{Check: check, snapshots: found.Snapshots}, err
+}
+
+func verifyIndices(c *cli.Context) error {
+	jobs, err := indexJobs(c)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, j := range jobs {
+		fmt.Printf("%s (%s)\n", j.name, j.id)
+
+		check, err := checkIndex(context.Background(), j)
+		if err != nil {
+			fmt.Printf("  error: %s\n\n", err)
+			problems++
+			continue
+		}
+
+		fmt.Printf("  %d files checked against %d snapshots\n", check.Files, check.snapshots)
+		fmt.Printf("  %d not found in any snapshot, remove them with swampd prune\n", len(check.Stale))
+		fmt.Printf("  %d with data missing from the repository\n", len(check.Unreadable))
+		if c.Bool("verbose") {
+			printDocuments("not found", check.Stale)
+			printDocuments("data missing", check.Unreadable)
+		}
+		fmt.Println()
+		problems += len(check.Stale) + len(check.Unreadable)
+	}
+
+	if problems > 0 {
+		return errors.New("the index doesn't match the repository")
+	}
+
+	return nil
+}
+
+func pruneIndices(c *cli.Context) error {
+	if !c.Bool("dry-run") && indexer.IsRunning() {
+		return errors.New("swampd is indexing, prune the index when it finishes")
+	}
+
+	jobs, err := indexJobs(c)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		check, err := checkIndex(context.Background(), j)
+		if err != nil {
+			return fmt.Errorf("error checking %s: %w", j.name, err)
+		}
+		// a repository without snapshots is more likely the wrong one
+		if check.snapshots == 0 {
+			return fmt.Errorf("no snapshots found in %s, nothing pruned", j.name)
+		}
+
+		if c.Bool("dry-run") {
+			printDocuments("would remove", check.Stale)
+			continue
+		}
+
+		ids := make([]string, len(check.Stale))
+		for i, doc := range check.Stale {
+			ids[i] = doc.ID
+		}
+		if err := index.Delete(j.indexPath, ids); err != nil {
+			return fmt.Errorf("error pruning %s: %w", j.name, err)
+		}
+		fmt.Printf("%s: %d files removed from the index\n", j.name, len(ids))
+	}
+
+	return nil
+}
+
+func printDocuments(problem string, docs []index.Document) {
+	for _, doc := range docs {
+		fmt.Printf("  %s: %s (%s)\n", problem, doc.Path, doc.ID)
+	}
+}
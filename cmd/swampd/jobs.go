@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rindex"
+	"github.com/swampapp/swamp/internal/credentials"
+	"github.com/swampapp/swamp/internal/indexer"
+)
+
+// indexJob indexes a single repository
+type indexJob struct {
+	id        string
+	name      string
+	indexPath string
+	repo      string
+	password  string
+	var1      string
+	var2      string
+	cancel    context.CancelFunc
+	// skipTagRules is set when the app applies the tag rules itself
+	skipTagRules bool
+}
+
+// withCredentials runs fn with the S3 credentials of the job in the
+// environment, see credentials.WithS3Env
+func (j *indexJob) withCredentials(fn func() error) error {
+	return credentials.WithS3Env(j.var1, j.var2, fn)
+}
+
+// openRepository opens the restic repository of the job
+func (j *indexJob) openRepository() (*repository.Repository, error) {
+	var repo *repository.Repository
+	err := j.withCredentials(func() error {
+		opts := rapi.DefaultOptions
+		opts.Repo = j.repo
+		opts.Password = j.password
+
+		var err error
+		repo, err = rapi.OpenRepository(opts)
+		return err
+	})
+
+	return repo, err
+}
+
+// openIndex opens the index of the job, offline if it's only searched
+func (j *indexJob) openIndex(offline bool) (rindex.Indexer, error) {
+	var idx rindex.Indexer
+	err := j.withCredentials(func() error {
+		var err error
+		if offline {
+			idx, err = rindex.NewOffline(j.indexPath, j.repo, j.password)
+		} else {
+			idx, err = rindex.New(j.indexPath, j.repo, j.password)
+		}
+		return err
+	})
+
+	return idx, err
+}
+
+// jobTracker keeps the state and stats of every repository being indexed
+// so the socket server can report them and cancel them individually.
+type jobTracker struct {
+	mutex sync.Mutex
+	jobs  []*indexJob
+	state map[string]indexer.RepoStats
+}
+
+func newJobTracker(jobs []*indexJob) *jobTracker {
+	t := &jobTracker{jobs: jobs, state: map[string]indexer.RepoStats{}}
+	for _, j := range jobs {
+		t.state[j.id] = indexer.RepoStats{Name: j.name, State: indexer.StateQueued}
+	}
+	return t
+}
+
+func (t *jobTracker) setState(id, state string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := t.state[id]
+	// a cancelled job stays cancelled, rindex returns when it's done
+	if s.State == indexer.StateCancelled {
+		return
+	}
+	s.State = state
+	t.state[id] = s
+}
+
+func (t *jobTracker) setStats(id string, stats rindex.IndexStats) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := t.state[id]
+	s.Stats = stats
+	t.state[id] = s
+}
+
+func (t *jobTracker) reposStats() map[string]indexer.RepoStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	m := make(map[string]indexer.RepoStats, len(t.state))
+	for k, v := range t.state {
+		m[k] = v
+	}
+	return m
+}
+
+// stats aggregates the stats of every repository, so clients not aware of
+// multiple repositories can still display the global progress.
+func (t *jobTracker) stats() rindex.IndexStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var agg rindex.IndexStats
+	for _, s := range t.state {
+		agg.Mismatch += s.Stats.Mismatch
+		agg.ScannedNodes += s.Stats.ScannedNodes
+		agg.IndexedFiles += s.Stats.IndexedFiles
+		agg.ScannedSnapshots += s.Stats.ScannedSnapshots
+		agg.AlreadyIndexed += s.Stats.AlreadyIndexed
+		agg.ScannedFiles += s.Stats.ScannedFiles
+		agg.Errors = append(agg.Errors, s.Stats.Errors...)
+		agg.MissingSnapshots += s.Stats.MissingSnapshots
+		agg.CurrentSnapshotFiles += s.Stats.CurrentSnapshotFiles
+		agg.CurrentSnapshotTotalFiles += s.Stats.CurrentSnapshotTotalFiles
+		agg.TotalSnapshots += s.Stats.TotalSnapshots
+		if s.State == indexer.StateIndexing && s.Stats.LastMatch != "" {
+			agg.LastMatch = s.Stats.LastMatch
+		}
+	}
+
+	return agg
+}
+
+// start marks the job as being indexed, unless it was cancelled while queued
+func (t *jobTracker) start(j *indexJob, cancel context.CancelFunc) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := t.state[j.id]
+	if s.State == indexer.StateCancelled {
+		return false
+	}
+	j.cancel = cancel
+	s.State = indexer.StateIndexing
+	t.state[j.id] = s
+
+	return true
+}
+
+// cancel stops indexing the given repository, returning false if not found
+func (t *jobTracker) cancel(id string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, j := range t.jobs {
+		if j.id != id {
+			continue
+		}
+		s := t.state[id]
+		s.State = indexer.StateCancelled
+		t.state[id] = s
+		if j.cancel != nil {
+			j.cancel()
+		}
+		return true
+	}
+
+	return false
+}
@@ -49,7 +49,7 @@ func main() {
 			&cli.StringFlag{
 				Name:        "index-path",
 				Usage:       "Index path",
-				Required:    true,
+				Required:    false,
 				Destination: &indexPath,
 			},
 			&cli.BoolFlag{
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/dupes"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/paths"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "dupes",
+		Usage:  "Report duplicated files",
+		Action: doDupes,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "repo",
+				Usage:    "Repository to scan",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "min-size",
+				Usage:    "Ignore files smaller than this size (i.e. 10MB)",
+				Value:    "1B",
+				Required: false,
+			},
+			&cli.BoolFlag{
+				Name:     "cross-repo",
+				Usage:    "Report which files are backed up in every configured repository",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "format",
+				Usage:    "Output format (text, csv or json)",
+				Value:    "text",
+				Required: false,
+			},
+			&cli.StringFlag{
+				Name:     "output",
+				Aliases:  []string{"o"},
+				Usage:    "Write the report to a file instead of stdout",
+				Required: false,
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func doDupes(c *cli.Context) error {
+	// the cross repository report compares every file of every repository
+	if c.Bool("cross-repo") && (c.IsSet("repo") || c.IsSet("min-size")) {
+		return fmt.Errorf("--repo and --min-size can't be used with --cross-repo")
+	}
+
+	if _, err := os.Stat(paths.RepositoriesDir()); os.IsNotExist(err) {
+		return fmt.Errorf("no repositories found.\nRun the swamp app first")
+	}
+
+	if c.Bool("debug") {
+		logger.Init(logger.DebugLevel, "swp")
+	} else {
+		logger.Init(logger.InfoLevel, "swp")
+	}
+
+	cfg, err := config.Init()
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if o := c.String("output"); o != "" {
+		f, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if !c.Bool("cross-repo") {
+		return doWastedReport(c, cfg, out)
+	}
+
+	report, err := dupes.CrossRepo(cfg.ListRepositories())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "⚠️  repository '%s' skipped, it needs to be indexed first\n", r.Name)
+	}
+
+	switch c.String("format") {
+	case "csv":
+		return report.WriteCSV(out)
+	case "json":
+		return report.WriteJSON(out)
+	case "text":
+		return printCrossRepo(out, report)
+	default:
+		return fmt.Errorf("unknown format '%s'", c.String("format"))
+	}
+}
+
+func printCrossRepo(out io.Writer, report *dupes.CrossRepoReport) error {
+	for _, g := range report.Groups {
+		if g.Coverage != dupes.AtRisk {
+			continue
+		}
+		for _, l := range g.Locations {
+			fmt.Fprintf(out, "%-20s %10s  %s\n", l.RepositoryName, humanize.Bytes(g.Size), l.Path)
+		}
+	}
+
+	_, err := fmt.Fprintf(out,
+		"\n%d files only in one repository, %d in some, %d in all %d repositories\n",
+		report.Count(dupes.AtRisk),
+		report.Count(dupes.Partial),
+		report.Count(dupes.Everywhere),
+		len(report.Repositories),
+	)
+
+	return err
+}
+
+func doWastedReport(c *cli.Context, cfg *config.Config, out io.Writer) error {
+	minSize, err := humanize.ParseBytes(c.String("min-size"))
+	if err != nil {
+		return fmt.Errorf("invalid size '%s' specified", c.String("min-size"))
+	}
+
+	var repo *config.Repository
+	name := c.String("repo")
+	for _, r := range cfg.ListRepositories() {
+		if (name == "" && r.ID == cfg.PreferredRepo()) || (name != "" && r.Name == name) {
+			r := r
+			repo = &r
+			break
+		}
+	}
+	if repo == nil {
+		if name == "" {
+			return fmt.Errorf("preferred repository not set")
+		}
+		return fmt.Errorf("no repository found with name '%s'", name)
+	}
+
+	report, err := dupes.Find(*repo, minSize)
+	if err != nil {
+		return err
+	}
+
+	switch c.String("format") {
+	case "csv":
+		return report.WriteCSV(out)
+	case "json":
+		return report.WriteJSON(out)
+	case "text":
+		return printWasted(out, report)
+	default:
+		return fmt.Errorf("unknown format '%s'", c.String("format"))
+	}
+}
+
+func printWasted(out io.Writer, report *dupes.Report) error {
+	for _, g := range report.Groups {
+		fmt.Fprintf(out, "%10s wasted, %d copies of %s\n", humanize.Bytes(g.Wasted), len(g.Locations), g.Name)
+		for _, l := range g.Locations {
+			fmt.Fprintf(out, "%10s %s\n", "", l.Path)
+		}
+	}
+
+	fmt.Fprintln(out, "\nDirectories with the most wasted space:")
+	for i, d := range report.Directories {
+		if i == 10 {
+			break
+		}
+		fmt.Fprintf(out, "%10s %4d files  %s\n", humanize.Bytes(d.Wasted), d.Files, d.Path)
+	}
+
+	_, err := fmt.Fprintf(out, "\n%d duplicated files wasting %s\n", len(report.Groups), humanize.Bytes(report.Wasted))
+
+	return err
+}
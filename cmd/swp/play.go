@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/queryparser"
+	"github.com/swampapp/swamp/internal/streamer"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "play",
+		Usage:     "Stream the files matching the query as a playlist",
+		ArgsUsage: "<query>",
+		Action:    doPlay,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:     "shuffle",
+				Usage:    "Play the files in random order",
+				Required: false,
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func doPlay(c *cli.Context) error {
+	if c.Bool("debug") {
+		logger.Init(logger.DebugLevel, "swp")
+	} else {
+		logger.Init(logger.InfoLevel, "swp")
+	}
+
+	q := c.Args().Get(0)
+	if q == "" {
+		return errors.New("missing query argument")
+	}
+	q, err := queryparser.ParseQuery(q)
+	if err != nil {
+		return err
+	}
+
+	if !config.Exists() {
+		return errors.New("swamp needs to be configured first")
+	}
+	if _, err := config.Init(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(index.PathFor(config.Get().PreferredRepo())); os.IsNotExist(err) {
+		return errors.New("the preferred repository needs to be indexed first. Open swamp to do it")
+	}
+
+	idx, err := index.Client()
+	if err != nil {
+		return err
+	}
+
+	docs, err := index.Collect(idx, q)
+	if err != nil {
+		return err
+	}
+
+	tracks := []streamer.Track{}
+	for _, doc := range docs {
+		if streamer.IsStreamable(doc.Name) {
+			tracks = append(tracks, streamer.Track{ID: doc.ID, Name: doc.Name, Path: doc.Path})
+		}
+	}
+	if len(tracks) == 0 {
+		return errors.New("no streamable files found")
+	}
+
+	// albums and series play in order
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].Path < tracks[j].Path
+	})
+
+	if c.Bool("shuffle") {
+		rand.Seed(time.Now().UnixNano())
+		rand.Shuffle(len(tracks), func(i, j int) {
+			tracks[i], tracks[j] = tracks[j], tracks[i]
+		})
+	}
+
+	fmt.Printf("Playing %d files...\n", len(tracks))
+	return streamer.StreamPlaylist(tracks)
+}
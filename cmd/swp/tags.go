@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/swampapp/swamp/internal/annotations"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/tags"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:  "tags",
+		Usage: "Manage the tags of the preferred repository",
+		// leveldb databases can only be open by one process
+		Description: "Swamp keeps the tags and annotations databases open while running, close it before using these commands.",
+		Before:      tagsInit,
+		After: func(*cli.Context) error {
+			if err := annotations.Close(); err != nil {
+				return err
+			}
+			return tags.Close()
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List every tag",
+				Action: doTagsList,
+			},
+			{
+				Name:      "rename",
+				Usage:     "Rename a tag in every file",
+				ArgsUsage: "<tag> <new name>",
+				Action: func(c *cli.Context) error {
+					from, to, err := tagArgs(c)
+					if err != nil {
+						return err
+					}
+					return tags.Rename(from, to)
+				},
+			},
+			{
+				Name:      "merge",
+				Usage:     "Replace a tag with another one in every file",
+				ArgsUsage: "<tag> <into tag>",
+				Action: func(c *cli.Context) error {
+					from, into, err := tagArgs(c)
+					if err != nil {
+						return err
+					}
+					return tags.Merge(from, into)
+				},
+			},
+			{
+				Name:      "color",
+				Usage:     "Change the color of a tag, to #rrggbb or a color name",
+				ArgsUsage: "<tag> <color>",
+				Action: func(c *cli.Context) error {
+					tag, color, err := tagArgs(c)
+					if err != nil {
+						return err
+					}
+					return tags.SetColor(tag, color)
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "Remove a tag from every file",
+				ArgsUsage: "<tag>",
+				Action: func(c *cli.Context) error {
+					tag := c.Args().Get(0)
+					if tag == "" {
+						return errors.New("missing tag argument")
+					}
+					return tags.Delete(tag)
+				},
+			},
+			{
+				Name:   "export",
+				Usage:  "Export the tags of every file",
+				Action: doTagsExport,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "format",
+						Usage:    "Output format (json or csv)",
+						Value:    "json",
+						Required: false,
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "Write the tags to a file instead of stdout",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "Import tags exported with 'swp tags export'",
+				ArgsUsage: "<file>",
+				Action:    doTagsImport,
+			},
+			{
+				Name:   "orphans",
+				Usage:  "List the tagged files not found in the index",
+				Action: doTagsOrphans,
+			},
+			{
+				Name:   "relink",
+				Usage:  "Move the tags of orphaned files to the indexed files with the same content and path",
+				Action: doTagsRelink,
+			},
+			{
+				Name:  "backup",
+				Usage: "Back up the tags now",
+				Action: func(c *cli.Context) error {
+					path, err := tags.Backup(config.Get().PreferredRepo())
+					if err != nil {
+						return err
+					}
+					fmt.Println("Tags backed up to " + path)
+					return nil
+				},
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func tagsInit(c *cli.Context) error {
+	if c.Bool("debug") {
+		logger.Init(logger.DebugLevel, "swp")
+	} else {
+		logger.Init(logger.InfoLevel, "swp")
+	}
+
+	if !config.Exists() {
+		return errors.New("swamp needs to be configured first")
+	}
+	cfg, err := config.Init()
+	if err != nil {
+		return err
+	}
+	if cfg.PreferredRepo() == "" {
+		return errors.New("no preferred repository set")
+	}
+
+	return nil
+}
+
+func tagArgs(c *cli.Context) (string, string, error) {
+	if c.Args().Len() != 2 {
+		return "", "", fmt.Errorf("expected 2 arguments, got %d", c.Args().Len())
+	}
+
+	return c.Args().Get(0), c.Args().Get(1), nil
+}
+
+func doTagsList(c *cli.Context) error {
+	all, err := tags.Counts()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range all {
+		value := fmt.Sprintf("%d files", t.Files)
+		if t.Color != "" {
+			value += ", " + t.Color
+		}
+		printRow(t.Name, value, headerColor)
+	}
+
+	return nil
+}
+
+func doTagsExport(c *cli.Context) error {
+	e, err := tags.NewExport(config.Get().PreferredRepo())
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if o := c.String("output"); o != "" {
+		f, err := os.Create(o)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch c.String("format") {
+	case "csv":
+		return e.WriteCSV(out)
+	case "json":
+		return e.WriteJSON(out)
+	default:
+		return fmt.Errorf("unknown format '%s'", c.String("format"))
+	}
+}
+
+func doTagsImport(c *cli.Context) error {
+	fname := c.Args().Get(0)
+	if fname == "" {
+		return errors.New("missing file argument")
+	}
+
+	e, err := tags.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+
+	result, err := tags.Import(config.Get().PreferredRepo(), e)
+	if err != nil {
+		return err
+	}
+
+	printRow("Matched", strconv.Itoa(result.Matched), headerColor)
+	printRow("Relinked", strconv.Itoa(result.Relinked), headerColor)
+	printRow("Unmatched", strconv.Itoa(len(result.Unmatched)), headerColor)
+	for _, u := range result.Unmatched {
+		fmt.Fprintf(os.Stderr, "⚠️  %s not found in the index (%s)\n", u.FileID, u.Path)
+	}
+
+	return nil
+}
+
+func doTagsOrphans(c *cli.Context) error {
+	orphans, err := tags.Orphans(config.Get().PreferredRepo())
+	if err != nil {
+		return err
+	}
+
+	for _, o := range orphans {
+		printOrphan(o)
+	}
+
+	return nil
+}
+
+func doTagsRelink(c *cli.Context) error {
+	report, err := tags.Relink(config.Get().PreferredRepo())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range report.Recovered {
+		fmt.Printf("%s: %s -> %s\n", r.Path, r.From, r.To)
+	}
+	for _, o := range report.Dangling {
+		printOrphan(o)
+	}
+	printRow("Recovered", strconv.Itoa(len(report.Recovered)), headerColor)
+	printRow("Dangling", strconv.Itoa(len(report.Dangling)), headerColor)
+
+	return nil
+}
+
+func printOrphan(o tags.Entry) {
+	names := make([]string, 0, len(o.Tags))
+	for _, t := range o.Tags {
+		names = append(names, t.Name)
+	}
+
+	path := o.Path
+	if path == "" {
+		path = "unknown path"
+	}
+	fmt.Printf("%s (%s): %s\n", o.FileID, path, strings.Join(names, ", "))
+}
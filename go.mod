@@ -14,6 +14,7 @@ require (
 	github.com/dustin/go-humanize v1.0.0
 	github.com/glycerine/go-unsnap-stream v0.0.0-20181221182339-f9677308dec2 // indirect
 	github.com/glycerine/goconvey v0.0.0-20190410193231-58a59202ab31 // indirect
+	github.com/godbus/dbus/v5 v5.0.3
 	github.com/gofiber/fiber/v2 v2.4.1
 	github.com/gotk3/gotk3 v0.6.2-0.20210823201509-e9bf9aa867f1
 	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
@@ -0,0 +1,207 @@
+// Package annotations stores the notes and ratings users attach to indexed
+// files, in a database per repository next to the tags database.
+package annotations
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/paths"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MaxRating is the highest rating, files are rated from 1 to MaxRating
+// stars
+const MaxRating = 5
+
+// Annotation is the note and rating of a file
+type Annotation struct {
+	Note string
+	// Rating is 0 for files not rated
+	Rating  int
+	Updated time.Time
+}
+
+// IsZero returns true if the annotation has neither note nor rating
+func (a Annotation) IsZero() bool {
+	return strings.TrimSpace(a.Note) == "" && a.Rating == 0
+}
+
+// ErrLocked is returned when the annotations database is held open by
+// another process, the app keeps it open while running
+var ErrLocked = errors.New("the annotations database is in use, close Swamp first")
+
+var keyPrefix = []byte("a/")
+
+var mutex sync.Mutex
+var dbs = map[string]*leveldb.DB{}
+
+// DBPath returns the path to the annotations database of the repository
+func DBPath(repoID string) string {
+	return filepath.Join(paths.RepositoriesDir(), repoID, "annotations.db")
+}
+
+// open returns the database of the repository, opening it the first time
+func open(repoID string) (*leveldb.DB, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	path := DBPath(repoID)
+	if db, ok := dbs[path]; ok {
+		return db, nil
+	}
+
+	db, err := leveldb.OpenFile(path, nil)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	dbs[path] = db
+
+	return db, nil
+}
+
+// Close closes the open databases
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var err error
+	for path, db := range dbs {
+		if cerr := db.Close(); cerr != nil {
+			err = cerr
+		}
+		delete(dbs, path)
+	}
+
+	return err
+}
+
+func key(fileID string) []byte {
+	return append(append([]byte{}, keyPrefix...), fileID...)
+}
+
+// Get returns the annotation of the file, a zero Annotation if there's none
+func Get(repoID, fileID string) (Annotation, error) {
+	var a Annotation
+	db, err := open(repoID)
+	if err != nil {
+		return a, err
+	}
+
+	b, err := db.Get(key(fileID), nil)
+	if err == leveldb.ErrNotFound {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+
+	return a, msgpack.Unmarshal(b, &a)
+}
+
+// Set replaces the annotation of the file, removing it if it's empty
+func Set(repoID, fileID string, a Annotation) error {
+	db, err := open(repoID)
+	if err != nil {
+		return err
+	}
+
+	if a.IsZero() {
+		return db.Delete(key(fileID), nil)
+	}
+
+	a.Note = strings.TrimSpace(a.Note)
+	if a.Rating < 0 {
+		a.Rating = 0
+	}
+	if a.Rating > MaxRating {
+		a.Rating = MaxRating
+	}
+	if a.Updated.IsZero() {
+		a.Updated = time.Now()
+	}
+	b, err := msgpack.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return db.Put(key(fileID), b, nil)
+}
+
+// All returns the annotations of the repository, by file ID
+func All(repoID string) (map[string]Annotation, error) {
+	all := map[string]Annotation{}
+	err := forEach(repoID, func(id string, a Annotation) {
+		all[id] = a
+	})
+
+	return all, err
+}
+
+// Find returns the IDs of the annotated files matching the filter
+func Find(repoID string, f Filter) ([]string, error) {
+	ids := []string{}
+	err := forEach(repoID, func(id string, a Annotation) {
+		if f.Match(a) {
+			ids = append(ids, id)
+		}
+	})
+
+	return ids, err
+}
+
+// Move moves the annotations of the files to a new file ID, the ones of the
+// target files are kept
+func Move(repoID string, moves map[string]string) error {
+	db, err := open(repoID)
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	for from, to := range moves {
+		b, err := db.Get(key(from), nil)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		batch.Delete(key(from))
+		if _, err := db.Get(key(to), nil); err == leveldb.ErrNotFound {
+			batch.Put(key(to), b)
+		}
+	}
+
+	return db.Write(batch, nil)
+}
+
+func forEach(repoID string, fn func(string, Annotation)) error {
+	db, err := open(repoID)
+	if err != nil {
+		return err
+	}
+
+	iter := db.NewIterator(util.BytesPrefix(keyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var a Annotation
+		if err := msgpack.Unmarshal(iter.Value(), &a); err != nil {
+			logger.Errorf(err, "invalid annotation for %s", iter.Key())
+			continue
+		}
+		fn(string(iter.Key()[len(keyPrefix):]), a)
+	}
+
+	return iter.Error()
+}
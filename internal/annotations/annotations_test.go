@@ -0,0 +1,112 @@
+package annotations
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// testRepo returns the ID of a repository whose annotations are stored in a
+// temporary database
+func testRepo(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "swamp-annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := leveldb.OpenFile(filepath.Join(dir, "annotations.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// open returns the databases already open
+	repoID := filepath.Base(dir)
+	mutex.Lock()
+	dbs[DBPath(repoID)] = db
+	mutex.Unlock()
+	t.Cleanup(func() {
+		mutex.Lock()
+		delete(dbs, DBPath(repoID))
+		mutex.Unlock()
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	return repoID
+}
+
+func TestGetAndSet(t *testing.T) {
+	repoID := testRepo(t)
+
+	if a, err := Get(repoID, "a"); err != nil || !a.IsZero() {
+		t.Fatalf("unexpected annotation %+v, %v", a, err)
+	}
+
+	if err := Set(repoID, "a", Annotation{Note: "  for the audit \n", Rating: MaxRating + 1}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Get(repoID, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Note != "for the audit" || a.Rating != MaxRating || a.Updated.IsZero() {
+		t.Errorf("unexpected annotation %+v", a)
+	}
+
+	// empty annotations are removed
+	if err := Set(repoID, "a", Annotation{Note: " ", Rating: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := All(repoID); err != nil || len(all) != 0 {
+		t.Errorf("empty annotation kept: %v, %v", all, err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	repoID := testRepo(t)
+	updated := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	annotations := map[string]Annotation{
+		"old":    {Note: "moved", Rating: 2, Updated: updated},
+		"other":  {Note: "replaced", Updated: updated},
+		"target": {Rating: 4, Updated: updated},
+		"kept":   {Rating: 1, Updated: updated},
+	}
+	for id, a := range annotations {
+		if err := Set(repoID, id, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moves := map[string]string{
+		"old":   "new",
+		"other": "target",
+		// files without annotations are ignored
+		"missing": "elsewhere",
+	}
+	if err := Move(repoID, moves); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := All(repoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// times are decoded in the local time zone
+	for id, a := range all {
+		a.Updated = a.Updated.UTC()
+		all[id] = a
+	}
+	// the annotation of the target is kept
+	expected := map[string]Annotation{
+		"new":    annotations["old"],
+		"target": annotations["target"],
+		"kept":   annotations["kept"],
+	}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("unexpected annotations %v", all)
+	}
+}
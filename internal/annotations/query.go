@@ -0,0 +1,101 @@
+package annotations
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter selects annotations by note words and rating range
+type Filter struct {
+	// Words the note must contain, case insensitive
+	Words []string
+	// MinRating and MaxRating are inclusive, MaxRating is 0 if unbounded
+	MinRating int
+	MaxRating int
+}
+
+var fieldRegexp = regexp.MustCompile(`(?i)(?:^|\s)\+?(note|rating):("[^"]*"|\S+)`)
+var ratingRegexp = regexp.MustCompile(`^(>=|<=|>|<|=)?(\d)$`)
+
+// ParseQuery extracts the note: and rating: fields from a search query,
+// returning the filter and the rest of the query.
+//
+// note:word, note:"some words", rating:4, rating:>=4 and rating:<3 are
+// supported. ok is false if the query has none of them.
+func ParseQuery(query string) (f Filter, rest string, ok bool, err error) {
+	matches := fieldRegexp.FindAllStringSubmatchIndex(query, -1)
+	if len(matches) == 0 {
+		return f, query, false, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(query[last:m[0]])
+		last = m[1]
+
+		field := strings.ToLower(query[m[2]:m[3]])
+		value := strings.Trim(query[m[4]:m[5]], `"`)
+		switch field {
+		case "note":
+			f.Words = append(f.Words, strings.Fields(strings.ToLower(value))...)
+		case "rating":
+			if err := f.parseRating(value); err != nil {
+				return f, query, true, err
+			}
+		}
+	}
+	b.WriteString(query[last:])
+
+	return f, strings.TrimSpace(b.String()), true, nil
+}
+
+func (f *Filter) parseRating(value string) error {
+	m := ratingRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return fmt.Errorf("invalid rating '%s'", value)
+	}
+	n, _ := strconv.Atoi(m[2])
+
+	switch m[1] {
+	case ">=":
+		f.MinRating = n
+	case ">":
+		f.MinRating = n + 1
+	case "<=":
+		f.MaxRating = n
+	case "<":
+		if n <= 1 {
+			return fmt.Errorf("invalid rating '%s', ratings start at 1", value)
+		}
+		f.MaxRating = n - 1
+	default:
+		f.MinRating, f.MaxRating = n, n
+	}
+
+	return nil
+}
+
+// Match returns true if the annotation passes the filter. Files not rated
+// never match rating filters.
+func (f Filter) Match(a Annotation) bool {
+	note := strings.ToLower(a.Note)
+	for _, w := range f.Words {
+		if !strings.Contains(note, w) {
+			return false
+		}
+	}
+
+	if f.MinRating > 0 || f.MaxRating > 0 {
+		if a.Rating == 0 || a.Rating < f.MinRating {
+			return false
+		}
+		if f.MaxRating > 0 && a.Rating > f.MaxRating {
+			return false
+		}
+	}
+
+	return true
+}
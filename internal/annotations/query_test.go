@@ -0,0 +1,53 @@
+package annotations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	f, rest, ok, err := ParseQuery(`ext:pdf note:"Kept for" rating:>=4 path:Invoices`)
+	if err != nil || !ok {
+		t.Fatalf("unexpected result %v %v", ok, err)
+	}
+	if rest != "ext:pdf path:Invoices" {
+		t.Errorf("unexpected rest of the query %q", rest)
+	}
+	expected := Filter{Words: []string{"kept", "for"}, MinRating: 4}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("unexpected filter %+v", f)
+	}
+
+	if _, rest, ok, _ := ParseQuery("ext:pdf notes"); ok || rest != "ext:pdf notes" {
+		t.Errorf("unexpected annotation fields in %q", rest)
+	}
+
+	if _, _, _, err := ParseQuery("rating:great"); err == nil {
+		t.Error("expected invalid rating error")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	a := Annotation{Note: "Kept for the 2020 audit", Rating: 3}
+
+	for q, expected := range map[string]bool{
+		"note:audit":           true,
+		"note:AUDIT rating:3":  true,
+		"note:tax":             false,
+		"rating:>=4":           false,
+		"rating:<4":            true,
+		"rating:>2 rating:<=3": true,
+	} {
+		f, _, _, err := ParseQuery(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Match(a) != expected {
+			t.Errorf("%s: expected match %v", q, expected)
+		}
+	}
+
+	if f, _, _, _ := ParseQuery("rating:<=5"); f.Match(Annotation{Note: "not rated"}) {
+		t.Error("files not rated matched a rating filter")
+	}
+}
@@ -15,6 +15,16 @@ type Config struct {
 	Repositories    []Repository
 	PreferredRepoID string
 	DarkMode        bool
+	// IndexOnBattery allows automatic indexing on battery power, as long as
+	// the battery level is above MinBatteryLevel
+	IndexOnBattery  bool
+	MinBatteryLevel int
+	// Players maps file kinds (video, audio, image, pdf, other) to the
+	// command templates used to play or open them, see the players package
+	Players map[string][]string
+	// Pipeline selects and configures the enrichers swampd runs to build
+	// the index documents, see the enrichers package
+	Pipeline Pipeline
 }
 
 var prListeners []prListener
@@ -22,6 +32,17 @@ var prListeners []prListener
 type Repository struct {
 	Name string
 	ID   string
+	// Schedule controls when the repository is indexed automatically,
+	// see the schedule package for the format
+	Schedule string
+}
+
+// Pipeline lists the enrichers to run or skip, overriding their defaults,
+// and their options, keyed by enricher name
+type Pipeline struct {
+	Enable  []string
+	Disable []string
+	Options map[string]map[string]string
 }
 
 type prListener func(string)
@@ -151,6 +172,16 @@ func (c *Config) SetDarkMode(mode bool) {
 	c.Save()
 }
 
+// SetPlayers sets the command templates used for a kind of file
+func (c *Config) SetPlayers(kind string, templates []string) {
+	if c.Players == nil {
+		c.Players = map[string][]string{}
+	}
+	c.Players[kind] = templates
+
+	c.Save()
+}
+
 func Exists() bool {
 	_, err := os.Stat(paths.ConfigPath())
 
@@ -2,6 +2,7 @@ package credentials
 
 import (
 	"os"
+	"sync"
 
 	"github.com/swampapp/swamp/internal/config"
 	"github.com/zalando/go-keyring"
@@ -101,3 +102,25 @@ func (s *Credentials) Save() error {
 
 	return nil
 }
+
+// S3 credentials are read from the environment when a repository is opened,
+// so repositories can't be opened concurrently
+var envMutex sync.Mutex
+
+// WithS3Env runs fn with the given S3 credentials in the environment. They're
+// removed when empty, so repositories without credentials don't use the
+// ones of the repository opened before.
+func WithS3Env(key, secret string, fn func() error) error {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+
+	if key != "" {
+		os.Setenv("AWS_ACCESS_KEY", key)
+		os.Setenv("AWS_SECRET_ACCESS_KEY", secret)
+	} else {
+		os.Unsetenv("AWS_ACCESS_KEY")
+		os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	return fn()
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/swampapp/swamp/internal/index"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/paths"
+	"github.com/swampapp/swamp/internal/players"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -283,15 +284,56 @@ func PathFromID(fileID string) string {
 	return filepath.Join(paths.DownloadsDir(), fileID[:2], fileID)
 }
 
+// Open opens a downloaded file with the player configured for its kind,
+// falling back to xdg-open. It returns once the player is started.
 func Open(fid string) error {
 	fpath := PathFromID(fid)
 	logger.Print("Opening ", fpath)
-	cmd := exec.Command("/usr/bin/xdg-open", fpath)
-	err := cmd.Run()
-	if err != nil {
+
+	kind := players.Other
+	if doc, err := index.GetDocument(fid); err == nil {
+		kind = players.KindOf(doc.Name)
+	}
+
+	var cmd *exec.Cmd
+	var stdin *os.File
+	c, err := players.Find(kind, players.Args{File: fpath})
+	if err == nil {
+		if c.Stdin {
+			stdin, err = os.Open(fpath)
+			if err != nil {
+				return err
+			}
+			c.Cmd.Stdin = stdin
+		}
+		cmd = c.Cmd
+	} else {
+		logger.Errorf(err, "no player configured to open %s files, using xdg-open", kind)
+		bin, err := exec.LookPath("xdg-open")
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(bin, fpath)
+	}
+
+	// called from the UI, players run until closed
+	if err := cmd.Start(); err != nil {
+		if stdin != nil {
+			stdin.Close()
+		}
 		logger.Print("error opening ", fpath)
+		return err
 	}
-	return err
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			logger.Errorf(err, "error playing %s", fpath)
+		}
+		if stdin != nil {
+			stdin.Close()
+		}
+	}()
+
+	return nil
 }
 
 func safeExportName(dest string) string {
@@ -0,0 +1,257 @@
+// Package dupes finds files sharing the same content (BHash) within and
+// across the indexed repositories.
+package dupes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/index"
+)
+
+// Coverage describes how many of the scanned repositories hold a copy of a file
+type Coverage string
+
+const (
+	// AtRisk files are only backed up in a single repository
+	AtRisk Coverage = "at-risk"
+	// Partial files are backed up in more than one repository, but not all of them
+	Partial Coverage = "partial"
+	// Everywhere files are backed up in every scanned repository
+	Everywhere Coverage = "everywhere"
+)
+
+type Location struct {
+	RepositoryID   string `json:"repository_id"`
+	RepositoryName string `json:"repository_name"`
+	FileID         string `json:"file_id"`
+	Name           string `json:"name"`
+	Path           string `json:"path"`
+}
+
+// Group holds every indexed copy of a given file content
+type Group struct {
+	BHash        string     `json:"bhash"`
+	Name         string     `json:"name"`
+	Size         uint64     `json:"size"`
+	Wasted       uint64     `json:"wasted"`
+	Coverage     Coverage   `json:"coverage,omitempty"`
+	Repositories []string   `json:"repositories"`
+	Locations    []Location `json:"locations"`
+}
+
+// Directory sums the space wasted by duplicated files found in a directory
+type Directory struct {
+	Path   string `json:"path"`
+	Files  int    `json:"files"`
+	Wasted uint64 `json:"wasted"`
+}
+
+// Report lists the duplicated files found in a repository
+type Report struct {
+	Repository  config.Repository `json:"repository"`
+	MinSize     uint64            `json:"min_size"`
+	Wasted      uint64            `json:"wasted"`
+	Groups      []*Group          `json:"groups"`
+	Directories []*Directory      `json:"directories"`
+}
+
+// CrossRepoReport groups the documents of several repositories by BHash
+type CrossRepoReport struct {
+	Repositories []config.Repository `json:"repositories"`
+	// Repositories that were not scanned because they haven't been indexed yet
+	Skipped []config.Repository `json:"skipped"`
+	Groups  []*Group            `json:"groups"`
+}
+
+// CrossRepo scans the index of every repository given and reports
+// where every file is backed up.
+func CrossRepo(repos []config.Repository) (*CrossRepoReport, error) {
+	report := &CrossRepoReport{}
+	b := newBuilder()
+
+	for _, repo := range repos {
+		ipath := index.PathFor(repo.ID)
+		if _, err := os.Stat(ipath); os.IsNotExist(err) {
+			report.Skipped = append(report.Skipped, repo)
+			continue
+		}
+
+		err := index.ForEach(ipath, func(doc index.Document) bool {
+			b.add(repo, doc)
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading the index of repository '%s': %w", repo.Name, err)
+		}
+		report.Repositories = append(report.Repositories, repo)
+	}
+
+	report.Groups = b.build(len(report.Repositories))
+
+	return report, nil
+}
+
+// Find scans the index of the repository given and reports the files
+// with more than one copy, biggest wasters first.
+//
+// Files smaller than minSize are ignored.
+func Find(repo config.Repository, minSize uint64) (*Report, error) {
+	ipath := index.PathFor(repo.ID)
+	if _, err := os.Stat(ipath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("repository '%s' needs to be indexed first", repo.Name)
+	}
+
+	b := newBuilder()
+	err := index.ForEach(ipath, func(doc index.Document) bool {
+		if doc.Bytes() >= minSize {
+			b.add(repo, doc)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the index of repository '%s': %w", repo.Name, err)
+	}
+
+	report := &Report{Repository: repo, MinSize: minSize}
+	report.Groups, report.Directories = b.duplicates()
+	for _, g := range report.Groups {
+		report.Wasted += g.Wasted
+	}
+
+	return report, nil
+}
+
+// Count returns the number of groups with the given coverage
+func (r *CrossRepoReport) Count(c Coverage) int {
+	count := 0
+	for _, g := range r.Groups {
+		if g.Coverage == c {
+			count++
+		}
+	}
+	return count
+}
+
+type builder struct {
+	groups map[string]*Group
+	repos  map[string]map[string]struct{}
+}
+
+func newBuilder() *builder {
+	return &builder{
+		groups: map[string]*Group{},
+		repos:  map[string]map[string]struct{}{},
+	}
+}
+
+func (b *builder) add(repo config.Repository, doc index.Document) {
+	// files without content have no BHash
+	if doc.BHash == "" {
+		return
+	}
+
+	g, ok := b.groups[doc.BHash]
+	if !ok {
+		g = &Group{BHash: doc.BHash, Name: doc.Name, Size: doc.Bytes()}
+		b.groups[doc.BHash] = g
+		b.repos[doc.BHash] = map[string]struct{}{}
+	}
+
+	if _, ok := b.repos[doc.BHash][repo.ID]; !ok {
+		b.repos[doc.BHash][repo.ID] = struct{}{}
+		g.Repositories = append(g.Repositories, repo.Name)
+	}
+
+	g.Locations = append(g.Locations, Location{
+		RepositoryID:   repo.ID,
+		RepositoryName: repo.Name,
+		FileID:         doc.ID,
+		Name:           doc.Name,
+		Path:           doc.Path,
+	})
+}
+
+// build returns the groups sorted by coverage, at risk files first, and size
+func (b *builder) build(scanned int) []*Group {
+	groups := make([]*Group, 0, len(b.groups))
+	for bhash, g := range b.groups {
+		switch n := len(b.repos[bhash]); {
+		case n <= 1:
+			g.Coverage = AtRisk
+		case n >= scanned:
+			g.Coverage = Everywhere
+		default:
+			g.Coverage = Partial
+		}
+		groups = append(groups, g)
+	}
+
+	rank := map[Coverage]int{AtRisk: 0, Partial: 1, Everywhere: 2}
+	sort.Slice(groups, func(i, j int) bool {
+		if rank[groups[i].Coverage] != rank[groups[j].Coverage] {
+			return rank[groups[i].Coverage] < rank[groups[j].Coverage]
+		}
+		if groups[i].Size != groups[j].Size {
+			return groups[i].Size > groups[j].Size
+		}
+		return groups[i].BHash < groups[j].BHash
+	})
+
+	return groups
+}
+
+// duplicates returns the groups with more than one copy sorted by wasted
+// bytes, and the directories holding the redundant copies.
+//
+// The first copy of every group (sorted by path) is considered the original.
+func (b *builder) duplicates() ([]*Group, []*Directory) {
+	groups := []*Group{}
+	dirs := map[string]*Directory{}
+
+	for _, g := range b.groups {
+		if len(g.Locations) < 2 {
+			continue
+		}
+
+		sort.Slice(g.Locations, func(i, j int) bool {
+			return g.Locations[i].Path < g.Locations[j].Path
+		})
+		g.Wasted = uint64(len(g.Locations)-1) * g.Size
+		groups = append(groups, g)
+
+		for _, l := range g.Locations[1:] {
+			dpath := filepath.Dir(l.Path)
+			d, ok := dirs[dpath]
+			if !ok {
+				d = &Directory{Path: dpath}
+				dirs[dpath] = d
+			}
+			d.Files++
+			d.Wasted += g.Size
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted != groups[j].Wasted {
+			return groups[i].Wasted > groups[j].Wasted
+		}
+		return groups[i].BHash < groups[j].BHash
+	})
+
+	dlist := make([]*Directory, 0, len(dirs))
+	for _, d := range dirs {
+		dlist = append(dlist, d)
+	}
+	sort.Slice(dlist, func(i, j int) bool {
+		if dlist[i].Wasted != dlist[j].Wasted {
+			return dlist[i].Wasted > dlist[j].Wasted
+		}
+		return dlist[i].Path < dlist[j].Path
+	})
+
+	return groups, dlist
+}
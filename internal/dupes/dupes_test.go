@@ -0,0 +1,86 @@
+package dupes
+
+import (
+	"testing"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/index"
+)
+
+func TestCrossRepoCoverage(t *testing.T) {
+	r1 := config.Repository{ID: "r1", Name: "one"}
+	r2 := config.Repository{ID: "r2", Name: "two"}
+	r3 := config.Repository{ID: "r3", Name: "three"}
+
+	b := newBuilder()
+	b.add(r1, index.Document{ID: "a1", BHash: "a", Size: "10"})
+	b.add(r2, index.Document{ID: "a2", BHash: "a", Size: "10"})
+	b.add(r3, index.Document{ID: "a3", BHash: "a", Size: "10"})
+	b.add(r1, index.Document{ID: "b1", BHash: "b", Size: "20"})
+	b.add(r1, index.Document{ID: "b2", BHash: "b", Size: "20"})
+	b.add(r1, index.Document{ID: "c1", BHash: "c", Size: "30"})
+	b.add(r3, index.Document{ID: "c3", BHash: "c", Size: "30"})
+	// empty files are ignored
+	b.add(r2, index.Document{ID: "e", BHash: ""})
+
+	groups := b.build(3)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+
+	expected := []struct {
+		bhash     string
+		coverage  Coverage
+		locations int
+	}{
+		{"b", AtRisk, 2},
+		{"c", Partial, 2},
+		{"a", Everywhere, 3},
+	}
+
+	for i, e := range expected {
+		g := groups[i]
+		if g.BHash != e.bhash || g.Coverage != e.coverage || len(g.Locations) != e.locations {
+			t.Errorf("%d. expected %s/%s/%d, got %s/%s/%d", i, e.bhash, e.coverage, e.locations, g.BHash, g.Coverage, len(g.Locations))
+		}
+	}
+}
+
+func TestDuplicatesWasted(t *testing.T) {
+	r := config.Repository{ID: "r1", Name: "one"}
+
+	b := newBuilder()
+	b.add(r, index.Document{ID: "a1", BHash: "a", Size: "10", Path: "/home/foo/a"})
+	b.add(r, index.Document{ID: "a2", BHash: "a", Size: "10", Path: "/home/bar/a"})
+	b.add(r, index.Document{ID: "a3", BHash: "a", Size: "10", Path: "/home/baz/a"})
+	b.add(r, index.Document{ID: "b1", BHash: "b", Size: "100", Path: "/home/foo/b"})
+	b.add(r, index.Document{ID: "b2", BHash: "b", Size: "100", Path: "/home/bar/b"})
+	b.add(r, index.Document{ID: "c1", BHash: "c", Size: "1000", Path: "/home/foo/c"})
+
+	groups, dirs := b.duplicates()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	if groups[0].BHash != "b" || groups[0].Wasted != 100 {
+		t.Errorf("expected b wasting 100 bytes first, got %s wasting %d", groups[0].BHash, groups[0].Wasted)
+	}
+
+	if groups[1].BHash != "a" || groups[1].Wasted != 20 {
+		t.Errorf("expected a wasting 20 bytes, got %s wasting %d", groups[1].BHash, groups[1].Wasted)
+	}
+
+	// /home/bar sorts first, so the copies in /home/baz and /home/foo are the redundant ones
+	expected := []Directory{
+		{Path: "/home/foo", Files: 2, Wasted: 110},
+		{Path: "/home/baz", Files: 1, Wasted: 10},
+	}
+	if len(dirs) != len(expected) {
+		t.Fatalf("expected %d directories, got %d", len(expected), len(dirs))
+	}
+	for i, e := range expected {
+		if *dirs[i] != e {
+			t.Errorf("%d. expected %+v, got %+v", i, e, *dirs[i])
+		}
+	}
+}
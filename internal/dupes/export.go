@@ -0,0 +1,78 @@
+package dupes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// WriteCSV writes one row per file location
+func (r *CrossRepoReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"coverage", "bhash", "size", "repository", "path", "name", "file_id"})
+	if err != nil {
+		return err
+	}
+
+	for _, g := range r.Groups {
+		for _, l := range g.Locations {
+			err := cw.Write([]string{
+				string(g.Coverage),
+				g.BHash,
+				strconv.FormatUint(g.Size, 10),
+				l.RepositoryName,
+				l.Path,
+				l.Name,
+				l.FileID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *CrossRepoReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per duplicated file copy
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"bhash", "size", "copies", "wasted", "path", "name", "file_id"})
+	if err != nil {
+		return err
+	}
+
+	for _, g := range r.Groups {
+		for _, l := range g.Locations {
+			err := cw.Write([]string{
+				g.BHash,
+				strconv.FormatUint(g.Size, 10),
+				strconv.Itoa(len(g.Locations)),
+				strconv.FormatUint(g.Wasted, 10),
+				l.Path,
+				l.Name,
+				l.FileID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,220 @@
+package enrichers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/extractors"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// DefaultContentMaxSize is the size of the largest file whose content is
+// indexed, unless the max_size option of the content enricher is set
+const DefaultContentMaxSize = extractors.DefaultMaxSize
+
+// fileEnricher adds the snapshot metadata rindex doesn't index
+type fileEnricher struct{}
+
+func (fileEnricher) Name() string {
+	return "file"
+}
+
+func (fileEnricher) Fields() []string {
+	return []string{"ext", "mode", "owner", "updated"}
+}
+
+func (fileEnricher) Cost() Cost {
+	return Free
+}
+
+func (fileEnricher) Default() bool {
+	return true
+}
+
+func (fileEnricher) Applies(f *File) bool {
+	return true
+}
+
+func (fileEnricher) Enrich(f *File, doc *bluge.Document) error {
+	doc.AddField(bluge.NewTextField("ext", filepath.Ext(f.Node.Name)).StoreValue()).
+		AddField(bluge.NewKeywordField("mode", f.Node.Mode.String()).StoreValue()).
+		AddField(bluge.NewKeywordField("owner", owner(f.Node)).StoreValue()).
+		AddField(bluge.NewDateTimeField("updated", time.Now()).StoreValue())
+
+	return nil
+}
+
+// owner returns user:group, falling back to the numeric IDs when the names
+// weren't recorded in the snapshot
+func owner(node *restic.Node) string {
+	user := node.User
+	if user == "" {
+		user = fmt.Sprint(node.UID)
+	}
+	group := node.Group
+	if group == "" {
+		group = fmt.Sprint(node.GID)
+	}
+
+	return user + ":" + group
+}
+
+// mimeEnricher detects the MIME type of files from their first bytes
+type mimeEnricher struct{}
+
+func (mimeEnricher) Name() string {
+	return "mime"
+}
+
+func (mimeEnricher) Fields() []string {
+	return []string{"mime"}
+}
+
+func (mimeEnricher) Cost() Cost {
+	return Header
+}
+
+func (mimeEnricher) Default() bool {
+	return false
+}
+
+func (mimeEnricher) Applies(f *File) bool {
+	return f.Regular() && f.Node.Size > 0
+}
+
+func (mimeEnricher) Enrich(f *File, doc *bluge.Document) error {
+	header, err := f.Header()
+	if err != nil {
+		return err
+	}
+	mime := extractors.DetectMIME(header)
+	if mime != extractors.UnknownMIME {
+		doc.AddField(bluge.NewKeywordField("mime", mime).StoreValue())
+	}
+
+	return nil
+}
+
+// mediaEnricher reads the metadata of photos, audio and video files
+type mediaEnricher struct{}
+
+func (mediaEnricher) Name() string {
+	return "media"
+}
+
+func (mediaEnricher) Fields() []string {
+	return []string{"exif_date", "camera", "artist", "album", "title", "width", "height", "duration"}
+}
+
+func (mediaEnricher) Cost() Cost {
+	return Header
+}
+
+func (mediaEnricher) Default() bool {
+	return false
+}
+
+func (mediaEnricher) Applies(f *File) bool {
+	return f.Regular() && extractors.MediaFor(f.Node.Name) != nil
+}
+
+func (mediaEnricher) Enrich(f *File, doc *bluge.Document) error {
+	header, err := f.Header()
+	if err != nil {
+		return err
+	}
+
+	m, err := extractors.MediaFor(f.Node.Name).Extract(header)
+	if err != nil {
+		logger.Debugf("metadata of %s not indexed: %v", f.Node.Name, err)
+	}
+
+	if !m.ExifDate.IsZero() {
+		doc.AddField(bluge.NewDateTimeField("exif_date", m.ExifDate).StoreValue())
+	}
+	for name, value := range map[string]string{
+		"camera": m.Camera,
+		"artist": m.Artist,
+		"album":  m.Album,
+		"title":  m.Title,
+	} {
+		if value != "" {
+			doc.AddField(bluge.NewTextField(name, value).StoreValue())
+		}
+	}
+	if m.Width > 0 && m.Height > 0 {
+		doc.AddField(bluge.NewNumericField("width", float64(m.Width)).StoreValue())
+		doc.AddField(bluge.NewNumericField("height", float64(m.Height)).StoreValue())
+	}
+	if m.Duration > 0 {
+		doc.AddField(bluge.NewNumericField("duration", m.Duration.Seconds()).StoreValue())
+	}
+
+	return nil
+}
+
+// contentEnricher indexes the text of files not larger than maxSize
+type contentEnricher struct {
+	maxSize uint64
+}
+
+func (contentEnricher) Name() string {
+	return "content"
+}
+
+func (contentEnricher) Fields() []string {
+	return []string{"content"}
+}
+
+func (contentEnricher) Cost() Cost {
+	return Content
+}
+
+func (contentEnricher) Default() bool {
+	return false
+}
+
+// Configure supports the max_size option, in bytes
+func (e contentEnricher) Configure(options map[string]string) (Enricher, error) {
+	for name, value := range options {
+		switch name {
+		case "max_size":
+			size, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_size %q: %w", value, err)
+			}
+			e.maxSize = size
+		default:
+			return nil, fmt.Errorf("unknown option %s", name)
+		}
+	}
+
+	return e, nil
+}
+
+func (e contentEnricher) Applies(f *File) bool {
+	return f.Regular() && f.Node.Size <= e.maxSize && extractors.For(f.Node.Name) != nil
+}
+
+func (contentEnricher) Enrich(f *File, doc *bluge.Document) error {
+	content, err := f.Content()
+	if err != nil {
+		return err
+	}
+
+	text, err := extractors.For(f.Node.Name).Extract(content)
+	if err != nil {
+		logger.Debugf("content of %s not indexed: %v", f.Node.Name, err)
+		return nil
+	}
+	if text != "" {
+		// stored so matches can be highlighted in search results
+		doc.AddField(bluge.NewTextField("content", text).StoreValue().HighlightMatches())
+	}
+
+	return nil
+}
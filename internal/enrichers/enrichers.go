@@ -0,0 +1,94 @@
+// Package enrichers builds the index documents of the files found in Restic
+// repositories.
+//
+// Documents are built by an ordered pipeline of enrichers, each one adding
+// some fields. The file, mime, media and content enrichers are built in, new
+// metadata can be indexed registering an Enricher. Which enrichers run, and
+// their options, is configured in config.yaml.
+package enrichers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blugelabs/bluge"
+)
+
+// Cost tells how much of a file an enricher loads from the repository
+type Cost int
+
+const (
+	// Free enrichers only use the metadata stored in the snapshot
+	Free Cost = iota
+	// Header enrichers read the first HeaderSize bytes of files
+	Header
+	// Content enrichers read whole files
+	Content
+)
+
+func (c Cost) String() string {
+	switch c {
+	case Free:
+		return "free"
+	case Header:
+		return "header"
+	case Content:
+		return "content"
+	}
+
+	return fmt.Sprintf("cost(%d)", int(c))
+}
+
+// Enricher adds fields to the index documents of the files it applies to
+type Enricher interface {
+	// Name identifies the enricher in the configuration
+	Name() string
+	// Fields lists the document fields the enricher adds
+	Fields() []string
+	// Cost tells how much of the file the enricher loads
+	Cost() Cost
+	// Default returns true if the enricher runs unless disabled
+	Default() bool
+	// Applies returns true if the enricher handles the file
+	Applies(f *File) bool
+	// Enrich adds the fields to the document. Errors are logged, and the
+	// next enricher runs.
+	Enrich(f *File, doc *bluge.Document) error
+}
+
+// Configurable is implemented by enrichers accepting options
+type Configurable interface {
+	// Configure returns a copy of the enricher using the options
+	Configure(options map[string]string) (Enricher, error)
+}
+
+var mutex sync.RWMutex
+var registry = []Enricher{
+	fileEnricher{},
+	mimeEnricher{},
+	mediaEnricher{},
+	contentEnricher{maxSize: DefaultContentMaxSize},
+}
+
+// Register appends an enricher to the pipeline, running after the ones
+// registered before and the built-in ones. It panics if an enricher with the
+// same name is registered.
+func Register(e Enricher) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, r := range registry {
+		if r.Name() == e.Name() {
+			panic("enricher " + e.Name() + " already registered")
+		}
+	}
+	registry = append(registry, e)
+}
+
+// Registered returns the registered enrichers, in pipeline order
+func Registered() []Enricher {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return append([]Enricher{}, registry...)
+}
@@ -0,0 +1,82 @@
+package enrichers
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/extractors"
+)
+
+// HeaderSize is the number of bytes Header enrichers can read from the
+// beginning of files
+const HeaderSize = extractors.MediaHeaderSize
+
+// File is a file being indexed. Its content is loaded from the repository
+// the first time an enricher needs it, and shared with the rest.
+type File struct {
+	ID   string
+	Node *restic.Node
+
+	repo          *repository.Repository
+	header        []byte
+	headerErr     error
+	headerLoaded  bool
+	content       []byte
+	contentErr    error
+	contentLoaded bool
+}
+
+// NewFile returns the file with the given ID and snapshot node, stored in
+// the repository
+func NewFile(id string, node *restic.Node, repo *repository.Repository) *File {
+	return &File{ID: id, Node: node, repo: repo}
+}
+
+// Regular returns true if the file is a regular file
+func (f *File) Regular() bool {
+	return f.Node.Type == "file"
+}
+
+// Header returns the beginning of the file, at least HeaderSize bytes unless
+// the file is smaller. Whole blobs are loaded, so it may be longer.
+func (f *File) Header() ([]byte, error) {
+	if f.contentLoaded {
+		return f.content, f.contentErr
+	}
+	if !f.headerLoaded {
+		f.header, f.headerErr = f.load(HeaderSize)
+		f.headerLoaded = true
+	}
+
+	return f.header, f.headerErr
+}
+
+// Content returns the whole content of the file
+func (f *File) Content() ([]byte, error) {
+	if !f.contentLoaded {
+		f.content, f.contentErr = f.load(0)
+		f.contentLoaded = true
+	}
+
+	return f.content, f.contentErr
+}
+
+// load returns the content of the file, loading blobs until limit bytes are
+// read or the whole file if limit is 0
+func (f *File) load(limit int) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, id := range f.Node.Content {
+		blob, err := f.repo.LoadBlob(context.Background(), restic.DataBlob, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(blob)
+		if limit > 0 && buf.Len() >= limit {
+			break
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+package enrichers
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// Pipeline builds index documents running the enabled enrichers, in
+// registration order. It implements rindex.DocumentBuilder.
+type Pipeline struct {
+	enrichers []Enricher
+}
+
+// NewPipeline returns a pipeline with the registered enrichers enabled by
+// default or in cfg, unless disabled in cfg, configured with their options.
+// Unknown enricher names, invalid options and enrichers adding the same
+// fields are errors.
+func NewPipeline(cfg config.Pipeline) (*Pipeline, error) {
+	registered := Registered()
+
+	known := map[string]bool{}
+	for _, e := range registered {
+		known[e.Name()] = true
+	}
+	names := append(append([]string{}, cfg.Enable...), cfg.Disable...)
+	for name := range cfg.Options {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown enricher %s", name)
+		}
+	}
+
+	p := &Pipeline{}
+	fields := map[string]string{}
+	for _, e := range registered {
+		// options are validated even if the enricher is disabled
+		if options, ok := cfg.Options[e.Name()]; ok {
+			c, ok := e.(Configurable)
+			if !ok {
+				return nil, fmt.Errorf("enricher %s has no options", e.Name())
+			}
+			configured, err := c.Configure(options)
+			if err != nil {
+				return nil, fmt.Errorf("enricher %s: %w", e.Name(), err)
+			}
+			e = configured
+		}
+		if !Enabled(e, cfg) {
+			continue
+		}
+
+		for _, field := range e.Fields() {
+			if other, ok := fields[field]; ok {
+				return nil, fmt.Errorf("enrichers %s and %s both add the %s field", other, e.Name(), field)
+			}
+			fields[field] = e.Name()
+		}
+		p.enrichers = append(p.enrichers, e)
+	}
+
+	return p, nil
+}
+
+// Enabled returns true if the enricher runs with the given configuration.
+// Disabling an enricher takes precedence over enabling it.
+func Enabled(e Enricher, cfg config.Pipeline) bool {
+	for _, name := range cfg.Disable {
+		if name == e.Name() {
+			return false
+		}
+	}
+	for _, name := range cfg.Enable {
+		if name == e.Name() {
+			return true
+		}
+	}
+
+	return e.Default()
+}
+
+// Enrichers returns the enrichers the pipeline runs, in order
+func (p *Pipeline) Enrichers() []Enricher {
+	return append([]Enricher{}, p.enrichers...)
+}
+
+func (p *Pipeline) BuildDocument(fileID string, node *restic.Node, repo *repository.Repository) *bluge.Document {
+	doc := bluge.NewDocument(fileID)
+	f := NewFile(fileID, node, repo)
+	for _, e := range p.enrichers {
+		if !e.Applies(f) {
+			continue
+		}
+		if err := e.Enrich(f, doc); err != nil {
+			logger.Errorf(err, "enricher %s failed indexing %s", e.Name(), node.Name)
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,122 @@
+package enrichers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blugelabs/bluge"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/config"
+)
+
+type testEnricher struct {
+	name   string
+	fields []string
+	err    error
+}
+
+func (e testEnricher) Name() string         { return e.name }
+func (e testEnricher) Fields() []string     { return e.fields }
+func (e testEnricher) Cost() Cost           { return Free }
+func (e testEnricher) Default() bool        { return true }
+func (e testEnricher) Applies(f *File) bool { return f.Regular() }
+func (e testEnricher) Enrich(f *File, doc *bluge.Document) error {
+	for _, field := range e.fields {
+		doc.AddField(bluge.NewKeywordField(field, f.Node.Name))
+	}
+	return e.err
+}
+
+func init() {
+	Register(testEnricher{name: "test", fields: []string{"test"}, err: errors.New("logged")})
+	Register(testEnricher{name: "dupe", fields: []string{"ext"}})
+}
+
+func names(p *Pipeline) []string {
+	n := []string{}
+	for _, e := range p.Enrichers() {
+		n = append(n, e.Name())
+	}
+	return n
+}
+
+func TestNewPipeline(t *testing.T) {
+	tests := []struct {
+		cfg      config.Pipeline
+		expected []string
+	}{
+		{config.Pipeline{Disable: []string{"dupe"}}, []string{"file", "test"}},
+		{config.Pipeline{Enable: []string{"content", "mime"}, Disable: []string{"dupe"}}, []string{"file", "mime", "content", "test"}},
+		{config.Pipeline{Enable: []string{"media"}, Disable: []string{"media", "dupe", "test"}}, []string{"file"}},
+		{config.Pipeline{Disable: []string{"file"}}, []string{"test", "dupe"}},
+	}
+	for _, test := range tests {
+		p, err := NewPipeline(test.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := names(p); !reflect.DeepEqual(n, test.expected) {
+			t.Errorf("%+v: expected %v, got %v", test.cfg, test.expected, n)
+		}
+	}
+}
+
+func TestNewPipelineErrors(t *testing.T) {
+	tests := []config.Pipeline{
+		// file and dupe add ext
+		{},
+		{Enable: []string{"exif"}, Disable: []string{"dupe"}},
+		{Disable: []string{"dupe"}, Options: map[string]map[string]string{"mime": {"a": "b"}}},
+		{Disable: []string{"dupe"}, Options: map[string]map[string]string{"content": {"max_size": "1MB"}}},
+		{Disable: []string{"dupe"}, Options: map[string]map[string]string{"content": {"size": "1"}}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewPipeline(cfg); err == nil {
+			t.Errorf("%+v: expected an error", cfg)
+		}
+	}
+}
+
+func TestContentOptions(t *testing.T) {
+	p, err := NewPipeline(config.Pipeline{
+		Enable:  []string{"content"},
+		Disable: []string{"dupe"},
+		Options: map[string]map[string]string{"content": {"max_size": "10"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := p.Enrichers()[1]
+	small := NewFile("1", &restic.Node{Name: "a.txt", Type: "file", Size: 10}, nil)
+	large := NewFile("2", &restic.Node{Name: "a.txt", Type: "file", Size: 11}, nil)
+	if !e.Applies(small) || e.Applies(large) {
+		t.Errorf("max_size not applied")
+	}
+}
+
+func TestBuildDocument(t *testing.T) {
+	p, err := NewPipeline(config.Pipeline{Disable: []string{"dupe"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := func(node *restic.Node) []string {
+		f := []string{}
+		for _, field := range *p.BuildDocument("1", node, nil) {
+			f = append(f, field.Name())
+		}
+		return f
+	}
+
+	expected := []string{"_id", "ext", "mode", "owner", "updated", "test"}
+	if f := fields(&restic.Node{Name: "a.txt", Type: "file"}); !reflect.DeepEqual(f, expected) {
+		t.Errorf("expected fields %v, got %v", expected, f)
+	}
+	// the test enricher doesn't apply to directories
+	expected = expected[:5]
+	if f := fields(&restic.Node{Name: "a", Type: "dir"}); !reflect.DeepEqual(f, expected) {
+		t.Errorf("expected fields %v, got %v", expected, f)
+	}
+}
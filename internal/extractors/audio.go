@@ -0,0 +1,255 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ID3v2 text encodings
+const (
+	id3Latin1 = iota
+	id3UTF16
+	id3UTF16BE
+	id3UTF8
+)
+
+const id3HeaderLen = 10
+
+// parseID3 reads the artist, album, title and duration from the ID3v2 tag
+// of MP3 files
+func parseID3(h []byte) (Media, error) {
+	var m Media
+	if len(h) < id3HeaderLen || string(h[:3]) != "ID3" {
+		return m, ErrUnknownFormat
+	}
+
+	version := h[3]
+	end := id3HeaderLen + synchsafe(h[6:10])
+	if end > len(h) {
+		end = len(h)
+	}
+
+	pos := id3HeaderLen
+	// extended header
+	if h[5]&0x40 != 0 && len(h) >= id3HeaderLen+4 {
+		switch version {
+		case 3:
+			pos += 4 + int(binary.BigEndian.Uint32(h[10:14]))
+		case 4:
+			pos += synchsafe(h[10:14])
+		}
+	}
+
+	// ID3v2.2 frames have 3 character IDs and sizes
+	idLen, frameHeaderLen := 4, 10
+	if version == 2 {
+		idLen, frameHeaderLen = 3, 6
+	}
+
+	for pos+frameHeaderLen <= end && h[pos] != 0 {
+		id := string(h[pos : pos+idLen])
+		var size int
+		switch version {
+		case 2:
+			size = int(h[pos+3])<<16 | int(h[pos+4])<<8 | int(h[pos+5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(h[pos+4:]))
+		default:
+			size = synchsafe(h[pos+4 : pos+8])
+		}
+		pos += frameHeaderLen
+		if size < 0 || pos+size > end {
+			break
+		}
+		frame := h[pos : pos+size]
+		pos += size
+
+		switch id {
+		case "TPE1", "TP1":
+			m.Artist = id3Text(frame)
+		case "TALB", "TAL":
+			m.Album = id3Text(frame)
+		case "TIT2", "TT2":
+			m.Title = id3Text(frame)
+		case "TLEN", "TLE":
+			if ms, err := strconv.Atoi(id3Text(frame)); err == nil {
+				m.Duration = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// synchsafe decodes the 28 bit integers stored in 4 bytes with the most
+// significant bit unset used by ID3v2
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// id3Text decodes the first value of a text frame
+func id3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+
+	var text string
+	data := frame[1:]
+	switch frame[0] {
+	case id3Latin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	case id3UTF16:
+		text = decodeUTF16(data, bytes.HasPrefix(data, []byte{0xfe, 0xff}))
+	case id3UTF16BE:
+		text = decodeUTF16(data, true)
+	case id3UTF8:
+		text = string(data)
+	}
+
+	// ID3v2.4 separates multiple values with null characters
+	if i := strings.IndexRune(text, 0); i >= 0 {
+		text = text[:i]
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(text, "\ufeff"))
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = binary.BigEndian.Uint16(data[2*i:])
+		} else {
+			u[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+	}
+
+	return string(utf16.Decode(u))
+}
+
+// FLAC metadata block types
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+)
+
+// parseFLAC reads the duration from the stream info and the artist, album
+// and title from the Vorbis comments of FLAC files
+func parseFLAC(h []byte) (Media, error) {
+	var m Media
+	if !bytes.HasPrefix(h, []byte("fLaC")) {
+		return m, ErrUnknownFormat
+	}
+
+	pos := 4
+	for pos+4 <= len(h) {
+		last := h[pos]&0x80 != 0
+		typ := h[pos] & 0x7f
+		length := int(h[pos+1])<<16 | int(h[pos+2])<<8 | int(h[pos+3])
+		pos += 4
+		if pos+length > len(h) {
+			break
+		}
+		block := h[pos : pos+length]
+		pos += length
+
+		switch typ {
+		case flacStreamInfo:
+			if len(block) < 18 {
+				break
+			}
+			// 20 bits sample rate, 3 bits channels, 5 bits bits per
+			// sample and 36 bits total samples
+			v := binary.BigEndian.Uint64(block[10:18])
+			rate := v >> 44
+			samples := v & (1<<36 - 1)
+			if rate > 0 {
+				m.Duration = time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+			}
+		case flacVorbisComment:
+			vorbisComments(block, &m)
+		}
+
+		if last {
+			break
+		}
+	}
+
+	return m, nil
+}
+
+// parseOgg reads the artist, album and title from the comment header of
+// Ogg Vorbis and Opus files
+func parseOgg(h []byte) (Media, error) {
+	var m Media
+	if !bytes.HasPrefix(h, []byte("OggS")) {
+		return m, ErrUnknownFormat
+	}
+
+	for _, magic := range [][]byte{[]byte("\x03vorbis"), []byte("OpusTags")} {
+		if i := bytes.Index(h, magic); i >= 0 {
+			vorbisComments(h[i+len(magic):], &m)
+			break
+		}
+	}
+
+	return m, nil
+}
+
+// vorbisComments reads the artist, album and title from a Vorbis comment
+// structure
+func vorbisComments(b []byte, m *Media) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := uint64(binary.LittleEndian.Uint32(b))
+		if 4+n > uint64(len(b)) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+
+	// vendor string
+	if _, ok := next(); !ok || len(b) < 4 {
+		return
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		kv := strings.SplitN(comment, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.ToUpper(kv[0]) {
+		case "ARTIST":
+			if m.Artist == "" {
+				m.Artist = value
+			}
+		case "ALBUM":
+			if m.Album == "" {
+				m.Album = value
+			}
+		case "TITLE":
+			if m.Title == "" {
+				m.Title = value
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package extractors turns the content of indexed files into searchable
+// text and media metadata.
+//
+// Plain text, markdown, HTML and source code are supported out of the box.
+// Other formats, like PDF or ODF documents, can be supported registering an
+// Extractor. Media metadata is read from JPEG, TIFF, PNG, GIF, MP3, FLAC,
+// Ogg and MP4 files, more formats can be supported registering a
+// MediaExtractor.
+package extractors
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxSize is the size of the largest file whose content is indexed
+// by default
+const DefaultMaxSize = 1024 * 1024
+
+// ErrBinary is returned when extracting text from content that isn't text
+var ErrBinary = errors.New("binary content")
+
+// Extractor extracts the searchable text of files
+type Extractor interface {
+	// Supports returns true if the extractor handles files with the given
+	// name
+	Supports(name string) bool
+	// Extract returns the text of the file content
+	Extract(content []byte) (string, error)
+}
+
+var mutex sync.RWMutex
+var registry = []Extractor{
+	markdownExtractor,
+	htmlExtractor,
+	textExtractor,
+}
+
+// Register adds an extractor, taking precedence over the ones registered
+// before and the built-in ones
+func Register(e Extractor) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	registry = append([]Extractor{e}, registry...)
+}
+
+// For returns the extractor handling files with the given name, nil if
+// there's none
+func For(name string) Extractor {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for _, e := range registry {
+		if e.Supports(name) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// extensionSet matches file names by extension, case insensitive
+type extensionSet map[string]bool
+
+func newExtensionSet(exts ...string) extensionSet {
+	s := extensionSet{}
+	for _, ext := range exts {
+		s[ext] = true
+	}
+
+	return s
+}
+
+func (s extensionSet) match(name string) bool {
+	return s[strings.ToLower(filepath.Ext(name))]
+}
@@ -0,0 +1,69 @@
+package extractors
+
+import (
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	for name, expected := range map[string]Extractor{
+		"notes.TXT":  textExtractor,
+		"main.go":    textExtractor,
+		"README.md":  markdownExtractor,
+		"index.html": htmlExtractor,
+		"photo.jpg":  nil,
+		"Makefile":   nil,
+	} {
+		if e := For(name); e != expected {
+			t.Errorf("unexpected extractor for %s", name)
+		}
+	}
+}
+
+type pdfExtractor struct{}
+
+func (pdfExtractor) Supports(name string) bool              { return name == "doc.pdf" }
+func (pdfExtractor) Extract(content []byte) (string, error) { return "pdf", nil }
+
+func TestRegister(t *testing.T) {
+	Register(pdfExtractor{})
+	if _, ok := For("doc.pdf").(pdfExtractor); !ok {
+		t.Error("expected the registered extractor")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for _, tc := range []struct {
+		e        Extractor
+		content  string
+		expected string
+	}{
+		{
+			textExtractor,
+			"plain text",
+			"plain text",
+		},
+		{
+			markdownExtractor,
+			"# Title\n\nSome *emphasis* and a [link](https://example.com).\n- item",
+			"Title\n\nSome emphasis and a link.\nitem",
+		},
+		{
+			htmlExtractor,
+			"<html><head><style>p {}</style><script>var x</script></head>" +
+				"<body><!-- hidden --><p>Tom &amp; Jerry</p>\n<p>again</p></body></html>",
+			"Tom & Jerry again",
+		},
+	} {
+		text, err := tc.e.Extract([]byte(tc.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != tc.expected {
+			t.Errorf("extracted %q, expected %q", text, tc.expected)
+		}
+	}
+
+	if _, err := textExtractor.Extract([]byte{0, 1, 2}); err != ErrBinary {
+		t.Errorf("expected ErrBinary, got %v", err)
+	}
+}
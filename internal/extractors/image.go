@@ -0,0 +1,220 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	// decoders used to read the image dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+	"time"
+)
+
+// TIFF tags read from the EXIF data
+const (
+	tagImageWidth       = 0x0100
+	tagImageHeight      = 0x0101
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xa002
+	tagPixelYDimension  = 0xa003
+)
+
+const (
+	tiffTypeASCII = 2
+	tiffTypeShort = 3
+	tiffTypeLong  = 4
+	tiffEntrySize = 12
+)
+
+// JPEG markers
+const (
+	jpegStartOfScan      = 0xda
+	jpegEndOfImage       = 0xd9
+	jpegApp1             = 0xe1
+	jpegSegmentHeaderLen = 4
+)
+
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// parseImage reads the EXIF data of JPEG and TIFF files and the dimensions
+// of the images the standard library decodes
+func parseImage(header []byte) (Media, error) {
+	var m Media
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8}):
+		if tiff := jpegExif(header); tiff != nil {
+			m = parseTIFF(tiff)
+		}
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		m = parseTIFF(header)
+	}
+
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(header)); err == nil {
+		m.Width, m.Height = cfg.Width, cfg.Height
+	} else if m.IsZero() {
+		return m, ErrUnknownFormat
+	}
+
+	return m, nil
+}
+
+// jpegExif returns the TIFF structure found in the EXIF segment of a JPEG
+// file, nil if there's none
+func jpegExif(data []byte) []byte {
+	i := 2
+	for i+jpegSegmentHeaderLen <= len(data) {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		// fill bytes
+		if marker == 0xff {
+			i++
+			continue
+		}
+		if marker == jpegStartOfScan || marker == jpegEndOfImage {
+			return nil
+		}
+
+		// the length includes its own 2 bytes, shorter segments are corrupt
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 {
+			return nil
+		}
+		end := i + 2 + length
+		if end > len(data) {
+			end = len(data)
+		}
+		segment := data[i+jpegSegmentHeaderLen : end]
+		if marker == jpegApp1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):]
+		}
+		i += 2 + length
+	}
+
+	return nil
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	// value holds the value itself if it fits in 4 bytes, its offset
+	// otherwise
+	value []byte
+}
+
+// parseTIFF reads the camera, date and dimensions of a TIFF structure
+func parseTIFF(data []byte) Media {
+	var m Media
+	if len(data) < 8 {
+		return m
+	}
+
+	r := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return m
+	}
+
+	ifd0 := r.ifd(r.order.Uint32(data[4:]))
+	m.Camera = camera(r.ascii(ifd0[tagMake]), r.ascii(ifd0[tagModel]))
+	m.Width, m.Height = r.uint(ifd0[tagImageWidth]), r.uint(ifd0[tagImageHeight])
+
+	date := r.ascii(ifd0[tagDateTime])
+	if e, ok := ifd0[tagExifIFD]; ok {
+		exif := r.ifd(uint32(r.uint(e)))
+		if original := r.ascii(exif[tagDateTimeOriginal]); original != "" {
+			date = original
+		}
+		if m.Width == 0 {
+			m.Width, m.Height = r.uint(exif[tagPixelXDimension]), r.uint(exif[tagPixelYDimension])
+		}
+	}
+	// EXIF dates have no time zone, photos are usually taken in the local
+	// one
+	if t, err := time.ParseInLocation(exifDateLayout, date, time.Local); err == nil {
+		m.ExifDate = t
+	}
+
+	return m
+}
+
+// ifd returns the entries of the image file directory found at offset
+func (r tiffReader) ifd(offset uint32) map[uint16]ifdEntry {
+	entries := map[uint16]ifdEntry{}
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return entries
+	}
+
+	n := int(r.order.Uint16(r.data[offset:]))
+	for i := 0; i < n; i++ {
+		p := int(offset) + 2 + i*tiffEntrySize
+		if p+tiffEntrySize > len(r.data) {
+			break
+		}
+		entries[r.order.Uint16(r.data[p:])] = ifdEntry{
+			typ:   r.order.Uint16(r.data[p+2:]),
+			count: r.order.Uint32(r.data[p+4:]),
+			value: r.data[p+8 : p+12],
+		}
+	}
+
+	return entries
+}
+
+func (r tiffReader) ascii(e ifdEntry) string {
+	if e.typ != tiffTypeASCII {
+		return ""
+	}
+
+	b := e.value
+	if e.count > 4 {
+		offset := uint64(r.order.Uint32(e.value))
+		if offset+uint64(e.count) > uint64(len(r.data)) {
+			return ""
+		}
+		b = r.data[offset : offset+uint64(e.count)]
+	} else {
+		b = b[:e.count]
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+func (r tiffReader) uint(e ifdEntry) int {
+	switch e.typ {
+	case tiffTypeShort:
+		return int(r.order.Uint16(e.value))
+	case tiffTypeLong:
+		return int(r.order.Uint32(e.value))
+	}
+
+	return 0
+}
+
+// camera returns the camera name, avoiding repeating the make when the
+// model already includes it
+func camera(maker, model string) string {
+	if strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		return model
+	}
+
+	return strings.TrimSpace(maker + " " + model)
+}
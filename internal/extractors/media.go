@@ -0,0 +1,124 @@
+package extractors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MediaHeaderSize is the number of bytes read from the beginning of media
+// files to extract their metadata
+const MediaHeaderSize = 256 * 1024
+
+// ErrUnknownFormat is returned when the file header isn't in the format
+// expected by the extractor
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Media is the metadata of photos, audio and video files. Fields not found
+// are left empty.
+type Media struct {
+	// ExifDate is when the photo was taken
+	ExifDate time.Time
+	Camera   string
+	Width    int
+	Height   int
+	Artist   string
+	Album    string
+	Title    string
+	Duration time.Duration
+}
+
+// IsZero returns true if no metadata was found
+func (m Media) IsZero() bool {
+	return m == Media{}
+}
+
+// Dimensions returns the width and height as WIDTHxHEIGHT, empty if unknown
+func (m Media) Dimensions() string {
+	if m.Width == 0 || m.Height == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d×%d", m.Width, m.Height)
+}
+
+// MediaExtractor extracts the metadata of media files
+type MediaExtractor interface {
+	// Supports returns true if the extractor handles files with the given
+	// name
+	Supports(name string) bool
+	// Extract returns the metadata found in the first MediaHeaderSize
+	// bytes of the file, or the whole file if smaller
+	Extract(header []byte) (Media, error)
+}
+
+var mediaRegistry = []MediaExtractor{
+	imageExtractor,
+	id3Extractor,
+	flacExtractor,
+	oggExtractor,
+	mp4Extractor,
+}
+
+// RegisterMedia adds a media extractor, taking precedence over the ones
+// registered before and the built-in ones
+func RegisterMedia(e MediaExtractor) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	mediaRegistry = append([]MediaExtractor{e}, mediaRegistry...)
+}
+
+// MediaFor returns the media extractor handling files with the given name,
+// nil if there's none
+func MediaFor(name string) MediaExtractor {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for _, e := range mediaRegistry {
+		if e.Supports(name) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// mediaParser extracts metadata from the files with the given extensions
+type mediaParser struct {
+	exts  extensionSet
+	parse func([]byte) (Media, error)
+}
+
+func (p *mediaParser) Supports(name string) bool {
+	return p.exts.match(name)
+}
+
+func (p *mediaParser) Extract(header []byte) (Media, error) {
+	return p.parse(header)
+}
+
+var imageExtractor = &mediaParser{
+	exts:  newExtensionSet(".jpg", ".jpeg", ".tif", ".tiff", ".png", ".gif"),
+	parse: parseImage,
+}
+
+var id3Extractor = &mediaParser{
+	exts:  newExtensionSet(".mp3"),
+	parse: parseID3,
+}
+
+var flacExtractor = &mediaParser{
+	exts:  newExtensionSet(".flac"),
+	parse: parseFLAC,
+}
+
+var oggExtractor = &mediaParser{
+	exts:  newExtensionSet(".ogg", ".oga", ".opus"),
+	parse: parseOgg,
+}
+
+var mp4Extractor = &mediaParser{
+	exts:  newExtensionSet(".mp4", ".m4a", ".m4v", ".mov"),
+	parse: parseMP4,
+}
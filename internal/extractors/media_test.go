@@ -0,0 +1,198 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// tiff returns a little endian TIFF structure with the make, model and an
+// EXIF directory with the original date and dimensions
+func tiff() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II*\x00")
+	binary.Write(&b, le, uint32(8))
+
+	// IFD0 at 8: 3 entries, values from 8+2+3*12+4 = 50
+	maker, model := "Canon\x00", "Canon EOS 5D\x00"
+	binary.Write(&b, le, uint16(3))
+	binary.Write(&b, le, []uint16{tagMake, tiffTypeASCII})
+	binary.Write(&b, le, []uint32{uint32(len(maker)), 50})
+	binary.Write(&b, le, []uint16{tagModel, tiffTypeASCII})
+	binary.Write(&b, le, []uint32{uint32(len(model)), 50 + uint32(len(maker))})
+	exifOffset := 50 + uint32(len(maker)+len(model))
+	binary.Write(&b, le, []uint16{tagExifIFD, tiffTypeLong})
+	binary.Write(&b, le, []uint32{1, exifOffset})
+	binary.Write(&b, le, uint32(0))
+	b.WriteString(maker + model)
+
+	date := "2021:07:14 18:30:00\x00"
+	binary.Write(&b, le, uint16(3))
+	binary.Write(&b, le, []uint16{tagDateTimeOriginal, tiffTypeASCII})
+	binary.Write(&b, le, []uint32{uint32(len(date)), exifOffset + 2 + 3*12 + 4})
+	binary.Write(&b, le, []uint16{tagPixelXDimension, tiffTypeShort})
+	binary.Write(&b, le, []uint32{1, 4000})
+	binary.Write(&b, le, []uint16{tagPixelYDimension, tiffTypeLong})
+	binary.Write(&b, le, []uint32{1, 3000})
+	binary.Write(&b, le, uint32(0))
+	b.WriteString(date)
+
+	return b.Bytes()
+}
+
+func TestJPEG(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), tiff()...)
+	var b bytes.Buffer
+	b.Write([]byte{0xff, 0xd8})
+	// an APP0 segment before the EXIF one
+	b.Write([]byte{0xff, 0xe0, 0x00, 0x04, 0x00, 0x00})
+	b.Write([]byte{0xff, 0xe1})
+	binary.Write(&b, binary.BigEndian, uint16(len(exif)+2))
+	b.Write(exif)
+
+	m, err := MediaFor("IMG_0001.JPG").Extract(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Media{
+		ExifDate: time.Date(2021, 7, 14, 18, 30, 0, 0, time.Local),
+		Camera:   "Canon EOS 5D",
+		Width:    4000,
+		Height:   3000,
+	}
+	if m != expected {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+}
+
+func TestMalformedJPEG(t *testing.T) {
+	for _, length := range []byte{0, 1} {
+		// an APP1 segment with an invalid length
+		data := []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, length, 'E', 'x', 'i', 'f', 0, 0}
+		if _, err := MediaFor("broken.jpg").Extract(data); err != ErrUnknownFormat {
+			t.Errorf("length %d: expected ErrUnknownFormat, got %v", length, err)
+		}
+	}
+}
+
+func id3Frame(id, text string) []byte {
+	var b bytes.Buffer
+	b.WriteString(id)
+	binary.Write(&b, binary.BigEndian, uint32(len(text)+1))
+	b.Write([]byte{0, 0, id3UTF8})
+	b.WriteString(text)
+	return b.Bytes()
+}
+
+func TestID3(t *testing.T) {
+	var frames bytes.Buffer
+	frames.Write(id3Frame("TPE1", "Nina Simone"))
+	frames.Write(id3Frame("TALB", "Pastel Blues"))
+	frames.Write(id3Frame("TIT2", "Sinnerman"))
+	frames.Write(id3Frame("TLEN", "622000"))
+	// padding
+	frames.Write(make([]byte, 16))
+
+	size := frames.Len()
+	h := append([]byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)},
+		frames.Bytes()...)
+
+	m, err := MediaFor("song.mp3").Extract(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Media{Artist: "Nina Simone", Album: "Pastel Blues", Title: "Sinnerman", Duration: 622 * time.Second}
+	if m != expected {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+}
+
+func TestID3Text(t *testing.T) {
+	utf16 := []byte{id3UTF16, 0xff, 0xfe, 'B', 0, 'j', 0, 0xf6, 0, 'r', 0, 'k', 0}
+	if s := id3Text(utf16); s != "Björk" {
+		t.Errorf("unexpected text %q", s)
+	}
+	if s := id3Text([]byte{id3Latin1, 'B', 'j', 0xf6, 'r', 'k', 0, 'x'}); s != "Björk" {
+		t.Errorf("unexpected text %q", s)
+	}
+}
+
+func TestFLAC(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("fLaC")
+
+	// stream info: 44100Hz, 2 channels, 16 bits, 441000 samples
+	info := make([]byte, 34)
+	v := uint64(44100)<<44 | uint64(1)<<41 | uint64(15)<<36 | 441000
+	binary.BigEndian.PutUint64(info[10:], v)
+	b.Write([]byte{flacStreamInfo, 0, 0, byte(len(info))})
+	b.Write(info)
+
+	var comments bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&comments, le, uint32(6))
+	comments.WriteString("vendor")
+	binary.Write(&comments, le, uint32(2))
+	for _, c := range []string{"artist=Miles Davis", "ALBUM=Kind of Blue"} {
+		binary.Write(&comments, le, uint32(len(c)))
+		comments.WriteString(c)
+	}
+	b.Write([]byte{0x80 | flacVorbisComment, 0, 0, byte(comments.Len())})
+	b.Write(comments.Bytes())
+
+	m, err := MediaFor("so_what.flac").Extract(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Media{Artist: "Miles Davis", Album: "Kind of Blue", Duration: 10 * time.Second}
+	if m != expected {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+	copy(b[4:], typ)
+	return append(b, data...)
+}
+
+func TestMP4(t *testing.T) {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 90500)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], 1920<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], 1080<<16)
+	data := append(make([]byte, 8), "Some title"...)
+
+	h := append(box("ftyp", []byte("isom")),
+		box("moov",
+			box("mvhd", mvhd),
+			box("trak", box("tkhd", tkhd)),
+			box("udta", box("meta", make([]byte, 4), box("ilst", box("\xa9nam", box("data", data))))),
+		)...)
+	// media data follows, truncated
+	h = append(h, 0, 0, 0xff, 0xff, 'm', 'd', 'a', 't', 1, 2, 3)
+
+	m, err := MediaFor("clip.MOV").Extract(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Media{Width: 1920, Height: 1080, Title: "Some title", Duration: 90500 * time.Millisecond}
+	if m != expected {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	for _, name := range []string{"a.jpg", "a.mp3", "a.flac", "a.ogg", "a.mp4"} {
+		if _, err := MediaFor(name).Extract([]byte("not media")); err != ErrUnknownFormat {
+			t.Errorf("%s: expected ErrUnknownFormat, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// MIMESniffLen is the number of bytes read to detect MIME types
+const MIMESniffLen = 512
+
+// UnknownMIME is the MIME type of content not recognized
+const UnknownMIME = "application/octet-stream"
+
+// offsets of the zip local file header fields used to find the mimetype
+// file of OpenDocument and EPUB files
+const (
+	zipSizeOffset     = 18
+	zipNameLenOffset  = 26
+	zipExtraLenOffset = 28
+	zipHeaderLen      = 30
+)
+
+// DetectMIME returns the MIME type of the content, without parameters, from
+// its first MIMESniffLen bytes. UnknownMIME is returned if it's not
+// recognized.
+func DetectMIME(header []byte) string {
+	if len(header) > MIMESniffLen {
+		header = header[:MIMESniffLen]
+	}
+
+	if mime := detectContainer(header); mime != "" {
+		return mime
+	}
+
+	mime := http.DetectContentType(header)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+
+	return strings.TrimSpace(mime)
+}
+
+// detectContainer refines the MIME types of the formats net/http only
+// recognizes as generic containers, or doesn't recognize
+func detectContainer(h []byte) string {
+	switch {
+	case bytes.HasPrefix(h, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(h, []byte("OggS")):
+		switch {
+		case bytes.Contains(h, []byte("OpusHead")):
+			return "audio/opus"
+		case bytes.Contains(h, []byte("\x01vorbis")):
+			return "audio/ogg"
+		case bytes.Contains(h, []byte("theora")):
+			return "video/ogg"
+		}
+	case bytes.HasPrefix(h, []byte("\x1a\x45\xdf\xa3")) && bytes.Contains(h, []byte("matroska")):
+		return "video/x-matroska"
+	case len(h) >= 12 && string(h[4:8]) == "ftyp":
+		switch string(h[8:12]) {
+		case "qt  ":
+			return "video/quicktime"
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "M4V ":
+			return "video/x-m4v"
+		}
+	case bytes.HasPrefix(h, []byte("PK\x03\x04")):
+		return zipMIME(h)
+	}
+
+	return ""
+}
+
+// zipMIME returns the content of the uncompressed mimetype file OpenDocument
+// and EPUB files store first
+func zipMIME(h []byte) string {
+	if len(h) < zipHeaderLen {
+		return ""
+	}
+	nameLen := int(binary.LittleEndian.Uint16(h[zipNameLenOffset:]))
+	extraLen := int(binary.LittleEndian.Uint16(h[zipExtraLenOffset:]))
+	size := int(binary.LittleEndian.Uint32(h[zipSizeOffset:]))
+
+	nameEnd := zipHeaderLen + nameLen
+	start := nameEnd + extraLen
+	if start+size > len(h) || string(h[zipHeaderLen:nameEnd]) != "mimetype" {
+		return ""
+	}
+	mime := string(h[start : start+size])
+	if !strings.HasPrefix(mime, "application/") {
+		return ""
+	}
+
+	return mime
+}
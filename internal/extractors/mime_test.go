@@ -0,0 +1,41 @@
+package extractors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// zipWithMIME returns the beginning of a zip file storing the mimetype file
+// first, as OpenDocument and EPUB files do
+func zipWithMIME(mime string) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("PK\x03\x04")
+	binary.Write(&b, le, []uint16{20, 0, 0, 0, 0})
+	binary.Write(&b, le, []uint32{0, uint32(len(mime)), uint32(len(mime))})
+	binary.Write(&b, le, []uint16{uint16(len("mimetype")), 0})
+	b.WriteString("mimetype" + mime)
+	b.WriteString("PK\x03\x04")
+	return b.Bytes()
+}
+
+func TestDetectMIME(t *testing.T) {
+	for expected, header := range map[string][]byte{
+		"application/pdf":      []byte("%PDF-1.7\n"),
+		"image/png":            []byte("\x89PNG\x0d\x0a\x1a\x0a"),
+		"text/plain":           []byte("some notes"),
+		"audio/flac":           []byte("fLaC\x00\x00\x00\x22"),
+		"audio/mpeg":           []byte("ID3\x03\x00\x00"),
+		"audio/opus":           []byte("OggS\x00\x02OpusHead"),
+		"video/quicktime":      []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"),
+		"application/epub+zip": zipWithMIME("application/epub+zip"),
+		"application/vnd.oasis.opendocument.text": zipWithMIME("application/vnd.oasis.opendocument.text"),
+		"application/zip":                         zipWithMIME("not a mime type"),
+		UnknownMIME:                               {0x01, 0x02, 0x03},
+	} {
+		if mime := DetectMIME(header); mime != expected {
+			t.Errorf("detected %s, expected %s", mime, expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package extractors
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// types of the boxes found at the beginning of MP4 and QuickTime files
+var mp4TopLevel = map[string]bool{
+	"ftyp": true, "moov": true, "mdat": true, "free": true,
+	"skip": true, "wide": true, "pnot": true,
+}
+
+// parseMP4 reads the duration, video dimensions and iTunes style artist,
+// album and title of MP4 and QuickTime files.
+//
+// Nothing is found when the movie box is at the end of the file, after the
+// media data.
+func parseMP4(h []byte) (Media, error) {
+	var m Media
+	if len(h) < 8 || !mp4TopLevel[string(h[4:8])] {
+		return m, ErrUnknownFormat
+	}
+
+	mp4Boxes(h, func(typ string, payload []byte) {
+		if typ == "moov" {
+			parseMoov(payload, &m)
+		}
+	})
+
+	return m, nil
+}
+
+func parseMoov(moov []byte, m *Media) {
+	mp4Boxes(moov, func(typ string, payload []byte) {
+		switch typ {
+		case "mvhd":
+			m.Duration = mvhdDuration(payload)
+		case "trak":
+			mp4Boxes(payload, func(typ string, payload []byte) {
+				// the dimensions are the last 8 bytes of the track
+				// header, 16.16 fixed point numbers
+				if typ != "tkhd" || len(payload) < 84 || m.Width > 0 {
+					return
+				}
+				m.Width = int(binary.BigEndian.Uint32(payload[len(payload)-8:]) >> 16)
+				m.Height = int(binary.BigEndian.Uint32(payload[len(payload)-4:]) >> 16)
+			})
+		case "udta":
+			mp4Boxes(payload, func(typ string, payload []byte) {
+				// meta is a full box, with 4 bytes of version and flags
+				if typ == "meta" && len(payload) > 4 {
+					parseMeta(payload[4:], m)
+				}
+			})
+		}
+	})
+}
+
+// mvhdDuration returns the duration stored in the movie header
+func mvhdDuration(mvhd []byte) time.Duration {
+	var scale, duration uint64
+	switch {
+	case len(mvhd) >= 32 && mvhd[0] == 1:
+		scale = uint64(binary.BigEndian.Uint32(mvhd[20:]))
+		duration = binary.BigEndian.Uint64(mvhd[24:])
+	case len(mvhd) >= 20:
+		scale = uint64(binary.BigEndian.Uint32(mvhd[12:]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:]))
+	}
+	if scale == 0 {
+		return 0
+	}
+
+	return time.Duration(float64(duration) / float64(scale) * float64(time.Second))
+}
+
+// parseMeta reads the iTunes metadata list
+func parseMeta(meta []byte, m *Media) {
+	mp4Boxes(meta, func(typ string, payload []byte) {
+		if typ != "ilst" {
+			return
+		}
+		mp4Boxes(payload, func(typ string, payload []byte) {
+			switch typ {
+			case "\xa9ART":
+				m.Artist = mp4Text(payload)
+			case "\xa9alb":
+				m.Album = mp4Text(payload)
+			case "\xa9nam":
+				m.Title = mp4Text(payload)
+			}
+		})
+	})
+}
+
+// mp4Text returns the text of the data box of an iTunes metadata item
+func mp4Text(item []byte) string {
+	var text string
+	mp4Boxes(item, func(typ string, payload []byte) {
+		// 4 bytes of type and 4 of locale precede the value
+		if typ == "data" && len(payload) > 8 && text == "" {
+			text = strings.TrimSpace(string(payload[8:]))
+		}
+	})
+
+	return text
+}
+
+// mp4Boxes calls fn with the type and payload of every box found in data.
+// The payload of the last box is truncated if data ends before it.
+func mp4Boxes(data []byte, fn func(typ string, payload []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			// the box extends to the end of the file
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			header = 16
+		}
+		if size < header {
+			return
+		}
+
+		end := size
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		fn(typ, data[header:end])
+		data = data[end:]
+	}
+}
@@ -0,0 +1,75 @@
+package extractors
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/swampapp/swamp/internal/textpreview"
+)
+
+// plainExtractor decodes text files, cleaning the text up with clean if
+// not nil
+type plainExtractor struct {
+	exts  extensionSet
+	clean func(string) string
+}
+
+func (p plainExtractor) Supports(name string) bool {
+	return p.exts.match(name)
+}
+
+func (p plainExtractor) Extract(content []byte) (string, error) {
+	decoded := textpreview.Decode(content, false)
+	if decoded.Binary {
+		return "", ErrBinary
+	}
+	if p.clean == nil {
+		return decoded.Text, nil
+	}
+
+	return p.clean(decoded.Text), nil
+}
+
+var textExtractor = &plainExtractor{
+	exts: newExtensionSet(
+		".txt", ".text", ".log", ".csv", ".tsv", ".rst", ".org", ".tex",
+		".json", ".yaml", ".yml", ".toml", ".ini", ".conf", ".cfg", ".xml",
+		".sh", ".bash", ".zsh", ".fish", ".go", ".py", ".rb", ".pl", ".php",
+		".js", ".ts", ".css", ".c", ".h", ".cc", ".cpp", ".hpp", ".java",
+		".kt", ".rs", ".swift", ".lua", ".sql", ".r", ".scala", ".cs",
+	),
+}
+
+var markdownExtractor = &plainExtractor{
+	exts:  newExtensionSet(".md", ".markdown"),
+	clean: cleanMarkdown,
+}
+
+var htmlExtractor = &plainExtractor{
+	exts:  newExtensionSet(".html", ".htm", ".xhtml"),
+	clean: cleanHTML,
+}
+
+var mdLinkRegexp = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+var mdMarkupRegexp = regexp.MustCompile("(?m)^\\s*(#{1,6}|>|[-*+]|\\d+\\.)\\s+|[*_`~]+")
+
+// cleanMarkdown removes the markdown markup, keeping the link texts
+func cleanMarkdown(text string) string {
+	text = mdLinkRegexp.ReplaceAllString(text, "$1")
+	return mdMarkupRegexp.ReplaceAllString(text, "")
+}
+
+var scriptRegexp = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+var commentRegexp = regexp.MustCompile(`(?s)<!--.*?-->`)
+var tagRegexp = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// cleanHTML returns the text of the document, without tags, scripts and
+// styles
+func cleanHTML(text string) string {
+	text = scriptRegexp.ReplaceAllString(text, " ")
+	text = commentRegexp.ReplaceAllString(text, " ")
+	text = tagRegexp.ReplaceAllString(text, " ")
+
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
@@ -2,8 +2,13 @@ package index
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/rubiojr/rindex"
@@ -19,6 +24,141 @@ type Document struct {
 	ID    string
 	Size  string
 	BHash string
+	// Blobs is the raw list of data blob IDs of the file, as stored by
+	// rindex, see BlobIDs
+	Blobs string
+	Mtime time.Time
+	// Updated is when the file was indexed
+	Updated time.Time
+	// Mode and Owner are missing from files indexed before schema version
+	// 3, until the index is rebuilt
+	Mode  string
+	Owner string
+	// Content is the text extracted from the file, when swampd indexes
+	// file contents
+	Content string
+	// media metadata, when swampd indexes it
+	ExifDate time.Time
+	Camera   string
+	Width    int
+	Height   int
+	Artist   string
+	Album    string
+	Title    string
+	Duration time.Duration
+	// MIME is the type detected from the file content, when swampd sniffs
+	// it
+	MIME string
+}
+
+// Bytes returns the document size in bytes, or 0 if unknown
+func (d Document) Bytes() uint64 {
+	size, err := strconv.ParseUint(d.Size, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// BlobIDs returns the IDs of the data blobs the file is made of.
+//
+// The blobs field is expected to be a JSON array of hex blob IDs, falling
+// back to comma or whitespace separated IDs.
+func (d Document) BlobIDs() ([]string, error) {
+	raw := strings.TrimSpace(d.Blobs)
+	if raw == "" {
+		return []string{}, nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		ids = strings.FieldsFunc(raw, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n' || r == '\t'
+		})
+	}
+
+	for _, id := range ids {
+		if b, err := hex.DecodeString(id); err != nil || len(b) != 32 {
+			return nil, fmt.Errorf("invalid blob ID %q", id)
+		}
+	}
+
+	return ids, nil
+}
+
+// SetField sets the document field from the value stored in the index, as
+// returned by searches
+func (d *Document) SetField(field string, value []byte) {
+	switch field {
+	case "filename":
+		d.Name = string(value)
+	case "path":
+		d.Path = string(value)
+	case "_id":
+		d.ID = string(value)
+	case "size":
+		size, err := bluge.DecodeNumericFloat64(value)
+		if err != nil {
+			logger.Error(err, "error decoding file size")
+		}
+		d.Size = fmt.Sprintf("%.0f", size)
+	case "bhash":
+		d.BHash = string(value)
+	case "blobs":
+		d.Blobs = string(value)
+	case "mtime":
+		mtime, err := bluge.DecodeDateTime(value)
+		if err != nil {
+			logger.Error(err, "error decoding file mtime")
+		}
+		d.Mtime = mtime
+	case "updated":
+		updated, err := bluge.DecodeDateTime(value)
+		if err != nil {
+			logger.Error(err, "error decoding file indexing date")
+		}
+		d.Updated = updated
+	case "mode":
+		d.Mode = string(value)
+	case "owner":
+		d.Owner = string(value)
+	case "content":
+		d.Content = string(value)
+	case "exif_date":
+		date, err := bluge.DecodeDateTime(value)
+		if err != nil {
+			logger.Error(err, "error decoding EXIF date")
+		}
+		d.ExifDate = date
+	case "mime":
+		d.MIME = string(value)
+	case "camera":
+		d.Camera = string(value)
+	case "width":
+		d.Width = decodeInt(field, value)
+	case "height":
+		d.Height = decodeInt(field, value)
+	case "artist":
+		d.Artist = string(value)
+	case "album":
+		d.Album = string(value)
+	case "title":
+		d.Title = string(value)
+	case "duration":
+		seconds, err := bluge.DecodeNumericFloat64(value)
+		if err != nil {
+			logger.Error(err, "error decoding duration")
+		}
+		d.Duration = time.Duration(seconds * float64(time.Second))
+	}
+}
+
+func decodeInt(field string, value []byte) int {
+	n, err := bluge.DecodeNumericFloat64(value)
+	if err != nil {
+		logger.Errorf(err, "error decoding %s", field)
+	}
+	return int(n)
 }
 
 func GetDocument(id string) (Document, error) {
@@ -29,29 +169,135 @@ func GetDocument(id string) (Document, error) {
 	}
 
 	_, err = idx.Search(fmt.Sprintf("_id:%s", id), func(field string, value []byte) bool {
-		if field == "filename" {
-			doc.Name = string(value)
+		doc.SetField(field, value)
+		return true
+	}, func() bool { return true })
+
+	return doc, err
+}
+
+// Collect returns the documents matching the query
+func Collect(idx rindex.Indexer, query string) ([]Document, error) {
+	docs := []Document{}
+	doc := Document{}
+	_, err := idx.Search(query, func(field string, value []byte) bool {
+		doc.SetField(field, value)
+		return true
+	}, func() bool {
+		docs = append(docs, doc)
+		doc = Document{}
+		return true
+	})
+
+	return docs, err
+}
+
+// ForEach visits every document stored in the index found in indexPath.
+//
+// Iteration stops when fn returns false.
+func ForEach(indexPath string, fn func(Document) bool) error {
+	reader, err := bluge.OpenReader(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "error closing index reader")
+		}
+	}()
+
+	req := bluge.NewAllMatches(bluge.NewMatchAllQuery())
+	dmi, err := reader.Search(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	match, err := dmi.Next()
+	for err == nil && match != nil {
+		doc := Document{}
+		err = match.VisitStoredFields(func(field string, value []byte) bool {
+			doc.SetField(field, value)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if !fn(doc) {
+			return nil
+		}
+		match, err = dmi.Next()
+	}
+
+	return err
+}
+
+// lookupBatchSize is the number of IDs GetDocuments looks up per search
+const lookupBatchSize = 1024
+
+// GetDocuments returns the documents with the given IDs from the index of
+// the preferred repository, searching them in batches. IDs not found in the
+// index are skipped.
+func GetDocuments(ids []string) ([]Document, error) {
+	if config.Get().PreferredRepo() == "" {
+		return nil, fmt.Errorf("no preferred repository currently set")
+	}
+
+	return Lookup(currentIndexPath(), ids)
+}
+
+// Lookup returns the documents with the given IDs stored in the index found
+// in indexPath
+func Lookup(indexPath string, ids []string) ([]Document, error) {
+	docs := make([]Document, 0, len(ids))
+	if len(ids) == 0 {
+		return docs, nil
+	}
+
+	reader, err := bluge.OpenReader(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "error closing index reader")
+		}
+	}()
+
+	for start := 0; start < len(ids); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(ids) {
+			end = len(ids)
 		}
-		if field == "path" {
-			doc.Path = string(value)
+
+		q := bluge.NewBooleanQuery()
+		for _, id := range ids[start:end] {
+			q.AddShould(bluge.NewTermQuery(id).SetField("_id"))
 		}
-		if field == "_id" {
-			doc.ID = string(value)
+
+		dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(q))
+		if err != nil {
+			return docs, err
 		}
-		if field == "size" {
-			size, err := bluge.DecodeNumericFloat64(value)
+
+		match, err := dmi.Next()
+		for err == nil && match != nil {
+			doc := Document{}
+			err = match.VisitStoredFields(func(field string, value []byte) bool {
+				doc.SetField(field, value)
+				return true
+			})
 			if err != nil {
-				logger.Error(err, "error decoding file size")
+				return docs, err
 			}
-			doc.Size = fmt.Sprintf("%.0f", size)
+			docs = append(docs, doc)
+			match, err = dmi.Next()
 		}
-		if field == "bhash" {
-			doc.BHash = string(value)
+		if err != nil {
+			return docs, err
 		}
-		return true
-	}, func() bool { return true })
+	}
 
-	return doc, err
+	return docs, nil
 }
 
 func NeedsIndexing(id string) (bool, error) {
@@ -84,9 +330,11 @@ func Client() (rindex.Indexer, error) {
 	return rindex.NewOffline(currentIndexPath(), k.Repository, k.Password)
 }
 
-func currentIndexPath() string {
-	pr := config.Get().PreferredRepo()
-	rd := paths.RepositoriesDir()
+// PathFor returns the path to the index of the given repository
+func PathFor(repoID string) string {
+	return filepath.Join(paths.RepositoriesDir(), repoID, "index", "swamp.bluge")
+}
 
-	return filepath.Join(rd, pr, "index", "swamp.bluge")
+func currentIndexPath() string {
+	return PathFor(config.Get().PreferredRepo())
 }
@@ -0,0 +1,39 @@
+package index
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlobIDs(t *testing.T) {
+	id1 := strings.Repeat("a1", 32)
+	id2 := strings.Repeat("b2", 32)
+
+	valid := []string{
+		`["` + id1 + `","` + id2 + `"]`,
+		id1 + "," + id2,
+		id1 + " " + id2,
+		" " + id1 + ", " + id2 + "\n",
+	}
+	for _, raw := range valid {
+		ids, err := Document{Blobs: raw}.BlobIDs()
+		if err != nil {
+			t.Errorf("%q: %v", raw, err)
+			continue
+		}
+		if len(ids) != 2 || ids[0] != id1 || ids[1] != id2 {
+			t.Errorf("%q: unexpected IDs %v", raw, ids)
+		}
+	}
+
+	ids, err := Document{}.BlobIDs()
+	if err != nil || len(ids) != 0 {
+		t.Error("empty files have no blobs")
+	}
+
+	for _, raw := range []string{"foo", id1 + ",abc", `["zz"]`} {
+		if _, err := (Document{Blobs: raw}).BlobIDs(); err == nil {
+			t.Errorf("%q should be invalid", raw)
+		}
+	}
+}
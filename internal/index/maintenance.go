@@ -0,0 +1,262 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/blugelabs/bluge"
+	blugeindex "github.com/blugelabs/bluge/index"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// writeBatchSize is the number of documents updated or deleted per batch
+const writeBatchSize = 1000
+
+// Stats describes the documents stored in an index
+type Stats struct {
+	Documents int
+	// Fields maps the stored fields to the number of documents storing
+	// them
+	Fields map[string]int
+	// Size is the size of the index on disk, in bytes
+	Size int64
+}
+
+// ReadStats returns the stats of the index found in indexPath
+func ReadStats(indexPath string) (Stats, error) {
+	s := Stats{Fields: map[string]int{}}
+
+	size, err := DiskSize(indexPath)
+	if err != nil {
+		return s, err
+	}
+	s.Size = size
+
+	err = withReader(indexPath, func(reader *bluge.Reader) error {
+		return forEachStored(reader, func(fields Fields) error {
+			s.Documents++
+			for name := range fields {
+				s.Fields[name]++
+			}
+			return nil
+		})
+	})
+
+	return s, err
+}
+
+// DiskSize returns the size of the files found in path, in bytes
+func DiskSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// Delete removes the documents with the given IDs from the index found in
+// indexPath
+func Delete(indexPath string, ids []string) error {
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			logger.Error(err, "error closing index writer")
+		}
+	}()
+
+	b := &batchWriter{writer: writer}
+	for _, id := range ids {
+		if err := b.delete(id); err != nil {
+			return err
+		}
+	}
+
+	return b.flush()
+}
+
+// Check is the result of checking the files of an index against the
+// snapshots of a repository
+type Check struct {
+	Files int
+	// Stale files aren't found in any snapshot, after restic forget
+	Stale []Document
+	// Unreadable files have data blobs missing from the repository, or an
+	// invalid list of blobs
+	Unreadable []Document
+}
+
+// CheckFiles checks the files indexed in indexPath. inSnapshot reports
+// whether a snapshot has a file with the given path and data blobs, and
+// hasBlob whether the repository stores a data blob.
+func CheckFiles(indexPath string, inSnapshot func(path string, blobs []string) bool, hasBlob func(id string) bool) (Check, error) {
+	c := Check{}
+	err := ForEach(indexPath, func(doc Document) bool {
+		// documents without a path don't describe files
+		if doc.Path == "" {
+			return true
+		}
+		c.Files++
+
+		blobs, err := doc.BlobIDs()
+		if err != nil {
+			c.Unreadable = append(c.Unreadable, doc)
+			return true
+		}
+		if !inSnapshot(doc.Path, blobs) {
+			c.Stale = append(c.Stale, doc)
+			return true
+		}
+		for _, b := range blobs {
+			if !hasBlob(b) {
+				c.Unreadable = append(c.Unreadable, doc)
+				break
+			}
+		}
+		return true
+	})
+
+	return c, err
+}
+
+// Compact rewrites the index found in indexPath, leaving out deleted
+// documents and merging its segments. Like migrations, only stored fields
+// are kept.
+func Compact(indexPath string) error {
+	compacted := indexPath + ".compact"
+	if err := os.RemoveAll(compacted); err != nil {
+		return err
+	}
+
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(compacted))
+	if err != nil {
+		return err
+	}
+	b := &batchWriter{writer: writer}
+	err = withReader(indexPath, func(reader *bluge.Reader) error {
+		return forEachStored(reader, func(fields Fields) error {
+			return b.update(storedDocument(fields))
+		})
+	})
+	if err == nil {
+		err = b.flush()
+	}
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.RemoveAll(compacted)
+		return err
+	}
+
+	// the old index is only removed once replaced
+	old := indexPath + ".old"
+	if err := os.Rename(indexPath, old); err != nil {
+		return err
+	}
+	if err := os.Rename(compacted, indexPath); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(old)
+}
+
+func withReader(indexPath string, fn func(*bluge.Reader) error) error {
+	reader, err := bluge.OpenReader(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "error closing index reader")
+		}
+	}()
+
+	return fn(reader)
+}
+
+// forEachStored visits the stored fields of every document
+func forEachStored(reader *bluge.Reader, fn func(Fields) error) error {
+	req := bluge.NewAllMatches(bluge.NewMatchAllQuery())
+	dmi, err := reader.Search(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	match, err := dmi.Next()
+	for err == nil && match != nil {
+		fields := Fields{}
+		err = match.VisitStoredFields(func(field string, value []byte) bool {
+			// values are only valid while visiting them
+			fields[field] = append([]byte{}, value...)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(fields); err != nil {
+			return err
+		}
+		match, err = dmi.Next()
+	}
+
+	return err
+}
+
+// batchWriter updates and deletes documents in batches of writeBatchSize
+type batchWriter struct {
+	writer  *bluge.Writer
+	batch   *blugeindex.Batch
+	pending int
+	// written is the number of documents updated or deleted
+	written int
+}
+
+func (b *batchWriter) update(doc *bluge.Document) error {
+	b.current().Update(doc.ID(), doc)
+	return b.added()
+}
+
+func (b *batchWriter) delete(id string) error {
+	b.current().Delete(bluge.Identifier(id))
+	return b.added()
+}
+
+func (b *batchWriter) current() *blugeindex.Batch {
+	if b.batch == nil {
+		b.batch = bluge.NewBatch()
+	}
+	return b.batch
+}
+
+func (b *batchWriter) added() error {
+	b.pending++
+	if b.pending < writeBatchSize {
+		return nil
+	}
+	return b.flush()
+}
+
+func (b *batchWriter) flush() error {
+	if b.pending == 0 {
+		return nil
+	}
+	if err := b.writer.Batch(b.batch); err != nil {
+		return err
+	}
+	b.written += b.pending
+	b.pending = 0
+	b.batch.Reset()
+
+	return nil
+}
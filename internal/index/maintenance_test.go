@@ -0,0 +1,180 @@
+package index
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blugelabs/bluge"
+)
+
+var (
+	blob1 = strings.Repeat("a1", 32)
+	blob2 = strings.Repeat("b2", 32)
+)
+
+// testIndex writes the documents of three files, and one without a path, to
+// a new index, returning its path
+func testIndex(t *testing.T) string {
+	indexPath := filepath.Join(t.TempDir(), "index", "swamp.bluge")
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []struct{ id, name, blobs string }{
+		{"1", "report.pdf", blob1},
+		{"2", "notes.txt", blob2},
+		{"3", "old report.odt", blob1 + "," + blob2},
+	}
+	for _, f := range files {
+		doc := bluge.NewDocument(f.id).
+			AddField(bluge.NewTextField("filename", f.name).StoreValue()).
+			AddField(bluge.NewTextField("path", "/home/foo/"+f.name).StoreValue()).
+			AddField(bluge.NewKeywordField("blobs", f.blobs).StoreValue()).
+			AddField(bluge.NewNumericField("size", 42).StoreValue())
+		doc.AddField(bluge.NewCompositeFieldExcluding("_all", nil))
+		if err := writer.Update(doc.ID(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc := bluge.NewDocument("_meta").
+		AddField(bluge.NewKeywordField("snapshot", "abc").StoreValue())
+	if err := writer.Update(doc.ID(), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return indexPath
+}
+
+// search returns the sorted IDs of the documents matching the query
+func search(t *testing.T, indexPath string, q bluge.Query) []string {
+	ids := []string{}
+	err := withReader(indexPath, func(reader *bluge.Reader) error {
+		dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(q))
+		if err != nil {
+			return err
+		}
+		match, err := dmi.Next()
+		for err == nil && match != nil {
+			err = match.VisitStoredFields(func(field string, value []byte) bool {
+				if field == "_id" {
+					ids = append(ids, string(value))
+				}
+				return true
+			})
+			if err == nil {
+				match, err = dmi.Next()
+			}
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestCheckFiles(t *testing.T) {
+	indexPath := testIndex(t)
+
+	// report.pdf was forgotten, and blob2 is missing from the repository
+	inSnapshot := func(path string, blobs []string) bool {
+		return path != "/home/foo/report.pdf"
+	}
+	hasBlob := func(id string) bool {
+		return id != blob2
+	}
+	check, err := CheckFiles(indexPath, inSnapshot, hasBlob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Files != 3 {
+		t.Errorf("expected 3 files checked, got %d", check.Files)
+	}
+	if len(check.Stale) != 1 || check.Stale[0].ID != "1" {
+		t.Errorf("unexpected stale files %+v", check.Stale)
+	}
+	unreadable := []string{}
+	for _, doc := range check.Unreadable {
+		unreadable = append(unreadable, doc.ID)
+	}
+	sort.Strings(unreadable)
+	if !reflect.DeepEqual(unreadable, []string{"2", "3"}) {
+		t.Errorf("unexpected unreadable files %v", unreadable)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	indexPath := testIndex(t)
+
+	if err := Delete(indexPath, []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if docs, err := Lookup(indexPath, []string{"1", "2"}); err != nil || len(docs) != 1 || docs[0].ID != "2" {
+		t.Fatalf("unexpected documents %+v, %v", docs, err)
+	}
+
+	// pruned files aren't stale anymore
+	check, err := CheckFiles(indexPath, func(path string, blobs []string) bool {
+		return path != "/home/foo/report.pdf"
+	}, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Files != 2 || len(check.Stale) != 0 {
+		t.Errorf("unexpected check %+v", check)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	indexPath := testIndex(t)
+	if err := Delete(indexPath, []string{"2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// queries without a field search _all
+	queries := map[string]bluge.Query{
+		"filename:report": bluge.NewMatchQuery("report").SetField("filename"),
+		"report":          bluge.NewMatchQuery("report").SetField("_all"),
+		"notes":           bluge.NewMatchQuery("notes").SetField("_all"),
+		"size:42":         bluge.NewNumericRangeInclusiveQuery(42, 42, true, true).SetField("size"),
+	}
+	before := map[string][]string{}
+	for name, q := range queries {
+		before[name] = search(t, indexPath, q)
+	}
+	if !reflect.DeepEqual(before["report"], []string{"1", "3"}) || len(before["notes"]) != 0 {
+		t.Fatalf("unexpected results before compacting %v", before)
+	}
+
+	if err := Compact(indexPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, q := range queries {
+		if after := search(t, indexPath, q); !reflect.DeepEqual(after, before[name]) {
+			t.Errorf("%s: expected %v after compacting, got %v", name, before[name], after)
+		}
+	}
+
+	stats, err := ReadStats(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 3 || stats.Fields["filename"] != 2 || stats.Fields["snapshot"] != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.Size == 0 {
+		t.Error("compacted index size not read")
+	}
+}
@@ -0,0 +1,197 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// Fields are the stored fields of a document, keyed by field name
+type Fields map[string][]byte
+
+// Migration upgrades indices to a schema version
+type Migration struct {
+	// Version is the schema version of the migrated index
+	Version     int
+	Description string
+	// Update changes the stored fields of a document, returning true if
+	// they changed. Only stored fields survive the update.
+	Update func(fields Fields) bool
+	// Rebuild makes swampd index every file again, for changes that can't
+	// be derived from the stored fields. The index can be searched while
+	// it's rebuilt.
+	Rebuild bool
+}
+
+// migrations upgrade indices from unversionedSchema to SchemaVersion, in
+// version order
+var migrations = []Migration{
+	{
+		Version:     2,
+		Description: "derive the ext field from the file name",
+		Update: func(f Fields) bool {
+			name, ok := f["filename"]
+			if _, found := f["ext"]; found || !ok {
+				return false
+			}
+			f["ext"] = []byte(filepath.Ext(string(name)))
+			return true
+		},
+	},
+	{
+		Version:     3,
+		Description: "index the mode, owner, MIME type, media metadata and content of files",
+		// read from the files in the repository by the document pipeline
+		Rebuild: true,
+	},
+}
+
+// Migrate upgrades the index found in indexPath to SchemaVersion, updating
+// the stored documents in place, and returns its schema. Rebuild is set in
+// the schema if a migration needs the files to be indexed again.
+func Migrate(indexPath string) (Schema, error) {
+	s, err := LoadSchema(indexPath)
+	if err != nil {
+		return s, err
+	}
+	if s.Version > SchemaVersion {
+		return s, fmt.Errorf("index schema version %d is newer than the supported version %d", s.Version, SchemaVersion)
+	}
+
+	migrated := false
+	for _, m := range migrations {
+		if m.Version <= s.Version {
+			continue
+		}
+
+		logger.Infof("migrating %s to schema version %d: %s", indexPath, m.Version, m.Description)
+		if m.Update != nil {
+			n, err := update(indexPath, m.Update)
+			if err != nil {
+				return s, fmt.Errorf("error migrating to schema version %d: %w", m.Version, err)
+			}
+			logger.Infof("%d documents updated", n)
+		}
+		s.Version = m.Version
+		s.Rebuild = s.Rebuild || m.Rebuild
+		s.Migrated = time.Now()
+		migrated = true
+
+		// saved after every migration, so they're not applied twice if a
+		// later one fails
+		if err := s.Save(indexPath); err != nil {
+			return s, err
+		}
+	}
+
+	// new and unversioned indices record their version
+	if _, err := os.Stat(SchemaPath(indexPath)); !migrated && os.IsNotExist(err) {
+		return s, s.Save(indexPath)
+	}
+
+	return s, nil
+}
+
+// update rewrites the documents whose stored fields fn changes, returning
+// the number of documents updated
+func update(indexPath string, fn func(Fields) bool) (int, error) {
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			logger.Error(err, "error closing index writer")
+		}
+	}()
+
+	reader, err := writer.Reader()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			logger.Error(err, "error closing index reader")
+		}
+	}()
+
+	b := &batchWriter{writer: writer}
+	err = forEachStored(reader, func(fields Fields) error {
+		if !fn(fields) {
+			return nil
+		}
+		return b.update(storedDocument(fields))
+	})
+	if err != nil {
+		return b.written, err
+	}
+
+	return b.written, b.flush()
+}
+
+type fieldKind int
+
+const (
+	keywordField fieldKind = iota
+	textField
+	highlightedField
+	numericField
+	dateTimeField
+)
+
+// fieldKinds are the types of the fields indexed by rindex and swampd, used
+// to index the stored values again. Other fields are keywords.
+var fieldKinds = map[string]fieldKind{
+	"filename":  textField,
+	"path":      textField,
+	"size":      numericField,
+	"mtime":     dateTimeField,
+	"ext":       textField,
+	"updated":   dateTimeField,
+	"content":   highlightedField,
+	"exif_date": dateTimeField,
+	"camera":    textField,
+	"artist":    textField,
+	"album":     textField,
+	"title":     textField,
+	"width":     numericField,
+	"height":    numericField,
+	"duration":  numericField,
+}
+
+// storedDocument builds a document from its stored fields
+func storedDocument(fields Fields) *bluge.Document {
+	doc := bluge.NewDocument(string(fields["_id"]))
+	for name, value := range fields {
+		if name != "_id" {
+			doc.AddField(storedField(name, value))
+		}
+	}
+	// searched by queries without a field
+	doc.AddField(bluge.NewCompositeFieldExcluding("_all", nil))
+
+	return doc
+}
+
+func storedField(name string, value []byte) bluge.Field {
+	switch fieldKinds[name] {
+	case textField:
+		return bluge.NewTextFieldBytes(name, value).StoreValue()
+	case highlightedField:
+		return bluge.NewTextFieldBytes(name, value).StoreValue().HighlightMatches()
+	case numericField:
+		if n, err := bluge.DecodeNumericFloat64(value); err == nil {
+			return bluge.NewNumericField(name, n).StoreValue()
+		}
+	case dateTimeField:
+		if t, err := bluge.DecodeDateTime(value); err == nil {
+			return bluge.NewDateTimeField(name, t).StoreValue()
+		}
+	}
+
+	return bluge.NewKeywordFieldBytes(name, value).StoreValue()
+}
@@ -0,0 +1,84 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blugelabs/bluge"
+)
+
+func TestLoadSchema(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index", "swamp.bluge")
+
+	s, err := LoadSchema(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != SchemaVersion {
+		t.Errorf("new indices should have version %d, got %d", SchemaVersion, s.Version)
+	}
+
+	if err := os.MkdirAll(indexPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err = LoadSchema(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != unversionedSchema {
+		t.Errorf("unversioned indices should have version %d, got %d", unversionedSchema, s.Version)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index", "swamp.bluge")
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := bluge.NewDocument("1").
+		AddField(bluge.NewTextField("filename", "report.pdf").StoreValue()).
+		AddField(bluge.NewNumericField("size", 42).StoreValue())
+	if err := writer.Update(doc.ID(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Migrate(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// version 3 needs the files of the repository
+	if s.Version != SchemaVersion || !s.Rebuild {
+		t.Errorf("unexpected schema %+v", s)
+	}
+	if saved, err := LoadSchema(indexPath); err != nil || saved.Version != SchemaVersion {
+		t.Errorf("schema not saved: %+v, %v", saved, err)
+	}
+
+	docs, err := Lookup(indexPath, []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Name != "report.pdf" || docs[0].Size != "42" {
+		t.Fatalf("stored fields not kept: %+v", docs)
+	}
+
+	reader, err := bluge.OpenReader(bluge.DefaultConfig(indexPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	q := bluge.NewMatchQuery("pdf").SetField("ext")
+	dmi, err := reader.Search(context.Background(), bluge.NewAllMatches(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, err := dmi.Next(); err != nil || match == nil {
+		t.Errorf("ext field not derived from the file name")
+	}
+}
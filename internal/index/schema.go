@@ -0,0 +1,67 @@
+package index
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SchemaVersion is the version of the documents swampd indexes. It's bumped
+// adding a Migration when the documents change.
+const SchemaVersion = 3
+
+// unversionedSchema is the version of indices created before the schema was
+// recorded
+const unversionedSchema = 1
+
+// Schema describes the documents stored in an index. It's saved to
+// schema.json, next to the index.
+type Schema struct {
+	Version int
+	// Rebuild is set when a migration needs every file to be indexed
+	// again, until swampd finishes re-indexing the repository
+	Rebuild bool
+	// Migrated is when the index was last migrated
+	Migrated time.Time
+}
+
+// SchemaPath returns the path to the schema of the index found in indexPath
+func SchemaPath(indexPath string) string {
+	return filepath.Join(filepath.Dir(indexPath), "schema.json")
+}
+
+// LoadSchema returns the schema of the index found in indexPath. Indices
+// created before schemas were recorded have version 1, and new ones the
+// current version.
+func LoadSchema(indexPath string) (Schema, error) {
+	b, err := ioutil.ReadFile(SchemaPath(indexPath))
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+			return Schema{Version: SchemaVersion}, nil
+		}
+		return Schema{Version: unversionedSchema}, nil
+	}
+	if err != nil {
+		return Schema{}, err
+	}
+
+	s := Schema{}
+	err = json.Unmarshal(b, &s)
+
+	return s, err
+}
+
+// Save writes the schema of the index found in indexPath
+func (s Schema) Save(indexPath string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(SchemaPath(indexPath), b, 0644)
+}
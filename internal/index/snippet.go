@@ -0,0 +1,77 @@
+package index
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SnippetLength is the approximate length of the snippets returned by
+// Snippet
+const SnippetLength = 120
+
+// text displayed before the first match in snippets
+const snippetContext = 30
+
+// Snippet returns the part of the content around the first match of the
+// terms as Pango markup, matches in bold. Terms are matched case
+// insensitive, an empty string is returned if none matches.
+func Snippet(content string, terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		// words of phrases may be separated by any whitespace
+		words := strings.Fields(t)
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		if len(words) > 0 {
+			quoted = append(quoted, strings.Join(words, `\s+`))
+		}
+	}
+	if len(quoted) == 0 {
+		return ""
+	}
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	first := re.FindStringIndex(content)
+	if first == nil {
+		return ""
+	}
+
+	start := first[0] - snippetContext
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	end := start + SnippetLength
+	if end < first[1] {
+		end = first[1]
+	}
+	if end > len(content) {
+		end = len(content)
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+	text := strings.Join(strings.Fields(content[start:end]), " ")
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString("<b>" + html.EscapeString(text[m[0]:m[1]]) + "</b>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package index
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	content := strings.Repeat("lorem ipsum ", 10) + "the Quarterly\nreport <draft> & notes " + strings.Repeat("dolor sit ", 20)
+
+	s := Snippet(content, []string{"quarterly report", "notes"})
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") {
+		t.Errorf("expected an ellipsized snippet, got %q", s)
+	}
+	if !strings.Contains(s, "<b>Quarterly report</b> &lt;draft&gt; &amp; <b>notes</b>") {
+		t.Errorf("unexpected snippet %q", s)
+	}
+
+	if s := Snippet("short notes", []string{"NOTES"}); s != "short <b>notes</b>" {
+		t.Errorf("unexpected snippet %q", s)
+	}
+	if s := Snippet(content, []string{"missing"}); s != "" {
+		t.Errorf("unexpected snippet %q", s)
+	}
+}
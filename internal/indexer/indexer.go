@@ -3,6 +3,7 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -19,16 +20,31 @@ import (
 	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/paths"
+	"github.com/swampapp/swamp/internal/power"
+	"github.com/swampapp/swamp/internal/schedule"
 )
 
 const (
 	IndexingStartedEvent = "indexer.indexing_started"
 	IndexingStoppedEvent = "indexer.indexing_stopped"
+	// IndexingDeferredEvent is emitted with the reason why scheduled
+	// indexing was deferred or paused, or an empty reason when it resumes
+	IndexingDeferredEvent = "indexer.indexing_deferred"
 )
 
+// socketTimeout limits the requests sent to swampd's socket
+const socketTimeout = 5 * time.Second
+
 type Indexer struct {
-	running bool
-	mutex   sync.Mutex
+	running     bool
+	mutex       sync.Mutex
+	runs        schedule.State
+	power       power.Monitor
+	process     *os.Process
+	paused      bool
+	deferReason string
+	// run starts swampd, replaced in tests
+	run func(repoIDs []string, scheduled bool)
 }
 
 var clientOnce, once sync.Once
@@ -37,27 +53,34 @@ var socketClient *http.Client
 var socketPath = filepath.Join(paths.DataDir(), "indexing.sock")
 
 func New() *Indexer {
-	return &Indexer{}
+	i := &Indexer{runs: schedule.State{}, power: power.NewFakeMonitor()}
+	i.run = i.start
+
+	return i
 }
 
 func Daemon() *Indexer {
 	once.Do(func() {
-		eventbus.RegisterEvents(IndexingStartedEvent, IndexingStoppedEvent)
+		eventbus.RegisterEvents(IndexingStartedEvent, IndexingStoppedEvent, IndexingDeferredEvent)
 		instance = New()
-		go func() {
-			logger.Print("indexer: scheduling indexer to run every 60 mins")
-			ticker := time.NewTicker(60 * time.Minute)
-			for range ticker.C {
-				logger.Print("indexer: trying to start swampd")
-				instance.Start()
-			}
-		}()
+		instance.power = power.NewMonitor()
+		instance.loadRuns()
+		go instance.runScheduler()
 	})
 
 	return instance
 }
 
+// Start indexes every configured repository
 func (i *Indexer) Start() {
+	i.markRun()
+	i.start(nil, false)
+}
+
+// start indexes the given repositories, or all of them if none given.
+//
+// Scheduled runs are paused while the power policy doesn't allow indexing.
+func (i *Indexer) start(repoIDs []string, scheduled bool) {
 	eventbus.Emit(context.Background(), IndexingStartedEvent, nil)
 	go func() {
 		if !config.Exists() {
@@ -71,8 +94,6 @@ func (i *Indexer) Start() {
 		}
 
 		logger.Print("indexer: STARTED the indexing goroutine")
-		prepo := config.Get().PreferredRepo()
-		rs := credentials.New(prepo)
 
 		for {
 			if !credentials.FirstBoot() {
@@ -94,17 +115,47 @@ func (i *Indexer) Start() {
 			return
 		}
 
-		args := []string{"--debug", "--index-path", currentIndexPath(), "index"}
+		// swampd reads the credentials of every configured repository
+		// from the keyring, and the document pipeline from config.yaml
+		args := []string{"--debug", "index"}
+		if len(repoIDs) == 0 {
+			args = append(args, "--all")
+		}
+		for _, id := range repoIDs {
+			args = append(args, "--repository-id", id)
+		}
+		// the app holds the tags database of the preferred repository open
+		// and applies its tag rules when indexing stops
+		if pr := config.Get().PreferredRepo(); pr != "" {
+			args = append(args, "--skip-tag-rules", pr)
+		}
 		logger.Print("swampd command: ", args)
 		cmd := exec.Command(bin, args...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Env = os.Environ()
-		cmd.Env = append(cmd.Env, "RESTIC_REPOSITORY="+rs.Repository)
-		cmd.Env = append(cmd.Env, "RESTIC_PASSWORD="+rs.Password)
-		cmd.Env = append(cmd.Env, "AWS_ACCESS_KEY="+rs.Var1)
-		cmd.Env = append(cmd.Env, "AWS_SECRET_ACCESS_KEY="+rs.Var2)
-		err = cmd.Run()
+		err = cmd.Start()
+		if err != nil {
+			logger.Error(err, "indexer: error starting swampd")
+			eventbus.Emit(context.Background(), IndexingStoppedEvent, nil)
+			return
+		}
+
+		i.mutex.Lock()
+		i.process = cmd.Process
+		i.mutex.Unlock()
+
+		done := make(chan struct{})
+		if scheduled {
+			go i.watchPower(done)
+		}
+		err = cmd.Wait()
+		close(done)
+
+		i.mutex.Lock()
+		i.process = nil
+		i.mutex.Unlock()
+
 		if err != nil {
 			logger.Error(err, "indexer: swampd error")
 		}
@@ -113,6 +164,9 @@ func (i *Indexer) Start() {
 }
 
 func (i *Indexer) Stop() error {
+	// a stopped process can't handle the request
+	i.resume()
+
 	resp, err := Client().Post("http://localhost/kill", "text/plain", nil)
 	if err != nil {
 		return err
@@ -128,6 +182,31 @@ func (i *Indexer) Stop() error {
 	return err
 }
 
+// Cancel stops indexing the given repository, leaving the rest of the
+// queued repositories untouched.
+func (i *Indexer) Cancel(repoID string) error {
+	if i.Paused() {
+		return ErrPaused
+	}
+
+	resp, err := Client().Post("http://localhost/cancel/"+repoID, "text/plain", nil)
+	if err != nil {
+		return err
+	}
+
+	//nolint
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error(err, "unhandled error reading body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error cancelling %s: %s", repoID, string(b))
+	}
+	return err
+}
+
 func IsRunning() bool {
 	resp, err := Client().Get("http://localhost/ping")
 	if err != nil {
@@ -151,8 +230,9 @@ func Client() *http.Client {
 		tr := &http.Transport{
 			Dial: unixDial,
 		}
-		socketClient = &http.Client{Transport: tr}
-
+		// swampd answers right away, a stuck daemon shouldn't hang the
+		// UI polling it
+		socketClient = &http.Client{Transport: tr, Timeout: socketTimeout}
 	})
 
 	return socketClient
@@ -181,6 +261,42 @@ func Stats() (rindex.IndexStats, error) {
 	return p, err
 }
 
+const (
+	StateQueued    = "queued"
+	StateIndexing  = "indexing"
+	StateDone      = "done"
+	StateCancelled = "cancelled"
+	StateFailed    = "failed"
+)
+
+// RepoStats holds the indexing progress of a single repository
+type RepoStats struct {
+	Name  string
+	State string
+	Stats rindex.IndexStats
+}
+
+// ReposStats returns the indexing progress of every repository swampd
+// is indexing, keyed by repository ID.
+func ReposStats() (map[string]RepoStats, error) {
+	resp, err := Client().Get("http://localhost/stats/repos")
+	if err != nil {
+		logger.Print("error fetching stats")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := map[string]RepoStats{}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return p, err
+	}
+
+	err = json.Unmarshal(b, &p)
+
+	return p, err
+}
+
 func Pid() (int, error) {
 	resp, err := Client().Get("http://localhost/pid")
 	if err != nil {
@@ -223,10 +339,3 @@ func GetProcStats() (ProcStats, error) {
 
 	return procStats, err
 }
-
-func currentIndexPath() string {
-	pr := config.Get().PreferredRepo()
-	rd := paths.RepositoriesDir()
-
-	return filepath.Join(rd, pr, "index", "swamp.bluge")
-}
@@ -0,0 +1,138 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/eventbus"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/power"
+)
+
+// how often the power status is checked while swampd is running
+const powerCheckInterval = 30 * time.Second
+
+// ErrPaused is returned when swampd can't handle requests because it has
+// been paused
+var ErrPaused = errors.New("indexing is paused")
+
+func (i *Indexer) powerPolicy() power.Policy {
+	if !config.Exists() {
+		return power.Policy{}
+	}
+
+	return power.Policy{
+		IndexOnBattery: config.Get().IndexOnBattery,
+		MinBattery:     float64(config.Get().MinBatteryLevel),
+	}
+}
+
+// powerAllows returns true if the power status allows indexing, or false and
+// the reason why it doesn't
+func (i *Indexer) powerAllows() (bool, string) {
+	s, err := i.power.Status()
+	if err != nil {
+		logger.Error(err, "indexer: error reading power status")
+		return true, ""
+	}
+
+	return i.powerPolicy().Allows(s)
+}
+
+// watchPower pauses swampd while the power policy doesn't allow indexing,
+// until done is closed
+func (i *Indexer) watchPower(done chan struct{}) {
+	ticker := time.NewTicker(powerCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			i.mutex.Lock()
+			i.paused = false
+			i.mutex.Unlock()
+			i.setDeferReason("")
+			return
+		case <-ticker.C:
+			ok, reason := i.powerAllows()
+			if ok {
+				i.resume()
+			} else {
+				i.pause(reason)
+			}
+		}
+	}
+}
+
+func (i *Indexer) pause(reason string) {
+	i.mutex.Lock()
+	if i.paused || i.process == nil {
+		i.mutex.Unlock()
+		return
+	}
+	err := i.process.Signal(syscall.SIGSTOP)
+	if err == nil {
+		i.paused = true
+	}
+	i.mutex.Unlock()
+
+	if err != nil {
+		logger.Error(err, "indexer: error pausing swampd")
+		return
+	}
+	logger.Printf("indexer: swampd paused, %s", reason)
+	i.setDeferReason("Indexing paused, " + reason)
+}
+
+func (i *Indexer) resume() {
+	i.mutex.Lock()
+	if !i.paused || i.process == nil {
+		i.mutex.Unlock()
+		return
+	}
+	err := i.process.Signal(syscall.SIGCONT)
+	if err == nil {
+		i.paused = false
+	}
+	i.mutex.Unlock()
+
+	if err != nil {
+		logger.Error(err, "indexer: error resuming swampd")
+		return
+	}
+	logger.Print("indexer: swampd resumed")
+	i.setDeferReason("")
+}
+
+// Paused returns true if swampd has been paused because of the power status.
+//
+// A paused swampd doesn't reply to requests until resumed.
+func (i *Indexer) Paused() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.paused
+}
+
+// DeferReason returns why indexing is currently deferred or paused, or an
+// empty string if it isn't
+func (i *Indexer) DeferReason() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.deferReason
+}
+
+func (i *Indexer) setDeferReason(reason string) {
+	i.mutex.Lock()
+	changed := i.deferReason != reason
+	i.deferReason = reason
+	i.mutex.Unlock()
+
+	if changed {
+		eventbus.Emit(context.Background(), IndexingDeferredEvent, reason)
+	}
+}
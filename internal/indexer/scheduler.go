@@ -0,0 +1,166 @@
+package indexer
+
+import (
+	"time"
+
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/schedule"
+)
+
+// how often the scheduler checks if a repository is due
+const schedulerTick = time.Minute
+
+func (i *Indexer) runScheduler() {
+	started := time.Now()
+	ticker := time.NewTicker(schedulerTick)
+	for {
+		i.checkSchedule(started)
+		<-ticker.C
+	}
+}
+
+// checkSchedule starts swampd for every repository that is due.
+//
+// Repositories never indexed by the scheduler are due one interval after the
+// app started. Due repositories scheduled to run only when idle are
+// postponed until the system is idle. The state is only saved when a run
+// starts or the next runs change.
+func (i *Indexer) checkSchedule(started time.Time) {
+	if !config.Exists() {
+		return
+	}
+
+	now := time.Now()
+	schedules := map[string]*schedule.Schedule{}
+	due := []string{}
+	changed := false
+
+	i.mutex.Lock()
+	for _, r := range config.Get().ListRepositories() {
+		s, err := schedule.Parse(r.Schedule)
+		if err != nil {
+			logger.Errorf(err, "indexer: invalid schedule for repository %s", r.Name)
+			continue
+		}
+		schedules[r.ID] = s
+
+		rs, ok := i.runs[r.ID]
+		last := rs.LastRun
+		if last.IsZero() {
+			last = started
+		}
+		next := s.Next(last)
+		if !ok || !next.Equal(rs.NextRun) {
+			rs.NextRun = next
+			i.runs[r.ID] = rs
+			changed = true
+		}
+
+		if rs.NextRun.IsZero() || rs.NextRun.After(now) {
+			continue
+		}
+		if s.OnlyIdle && !schedule.SystemIdle() {
+			logger.Debugf("indexer: %s is due but the system is busy, postponing", r.Name)
+			continue
+		}
+		due = append(due, r.ID)
+	}
+	i.mutex.Unlock()
+
+	if len(due) > 0 && i.startDue(due, now, schedules) {
+		changed = true
+	}
+
+	if changed {
+		i.saveRuns()
+	}
+}
+
+// startDue starts swampd for the due repositories. Starting them is deferred
+// while the power policy doesn't allow indexing, they stay due until it does.
+// Returns true if swampd was started.
+func (i *Indexer) startDue(due []string, now time.Time, schedules map[string]*schedule.Schedule) bool {
+	if i.Paused() {
+		return false
+	}
+
+	if ok, reason := i.powerAllows(); !ok {
+		logger.Printf("indexer: scheduled indexing deferred, %s", reason)
+		i.setDeferReason("Indexing deferred, " + reason)
+		return false
+	}
+	i.setDeferReason("")
+
+	if IsRunning() {
+		logger.Print("indexer: swampd already running, postponing scheduled indexing")
+		return false
+	}
+
+	i.mutex.Lock()
+	for _, id := range due {
+		i.runs[id] = schedule.RunState{LastRun: now, NextRun: schedules[id].Next(now)}
+	}
+	i.mutex.Unlock()
+
+	logger.Printf("indexer: starting scheduled indexing of %d repositories", len(due))
+	i.run(due, true)
+
+	return true
+}
+
+func (i *Indexer) loadRuns() {
+	state, err := schedule.LoadState()
+	if err != nil {
+		logger.Error(err, "indexer: error loading scheduler state")
+		return
+	}
+
+	i.mutex.Lock()
+	i.runs = state
+	i.mutex.Unlock()
+}
+
+// markRun records a manual indexing run of every repository, so the next
+// scheduled run starts counting from now
+func (i *Indexer) markRun() {
+	if !config.Exists() {
+		return
+	}
+
+	now := time.Now()
+	i.mutex.Lock()
+	for _, r := range config.Get().ListRepositories() {
+		rs := i.runs[r.ID]
+		rs.LastRun = now
+		i.runs[r.ID] = rs
+	}
+	i.mutex.Unlock()
+
+	i.saveRuns()
+}
+
+func (i *Indexer) saveRuns() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if err := i.runs.Save(); err != nil {
+		logger.Error(err, "indexer: error saving scheduler state")
+	}
+}
+
+// NextRun returns when the repository will be indexed automatically next.
+//
+// false is returned if the repository is never indexed automatically or the
+// scheduler hasn't checked it yet.
+func (i *Indexer) NextRun(repoID string) (time.Time, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	rs, ok := i.runs[repoID]
+	if !ok || rs.NextRun.IsZero() {
+		return time.Time{}, false
+	}
+
+	return rs.NextRun, true
+}
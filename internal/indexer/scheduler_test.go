@@ -0,0 +1,67 @@
+package indexer
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swampapp/swamp/internal/power"
+	"github.com/swampapp/swamp/internal/schedule"
+)
+
+func TestStartDueFollowsPower(t *testing.T) {
+	// without configuration indexing on battery isn't allowed
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+	if IsRunning() {
+		t.Skip("swampd is running")
+	}
+
+	monitor := power.NewFakeMonitor()
+	i := New()
+	i.power = monitor
+	started := [][]string{}
+	i.run = func(repoIDs []string, scheduled bool) {
+		if !scheduled {
+			t.Error("due repositories should start a scheduled run")
+		}
+		started = append(started, repoIDs)
+	}
+
+	s, err := schedule.Parse("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedules := map[string]*schedule.Schedule{"r1": s}
+	now := time.Now()
+
+	monitor.Set(power.Status{OnBattery: true, Percentage: 80}, nil)
+	if i.startDue([]string{"r1"}, now, schedules) {
+		t.Error("deferred run reported as started")
+	}
+	if len(started) != 0 {
+		t.Fatalf("scheduled run started on battery: %v", started)
+	}
+	if i.DeferReason() != "Indexing deferred, running on battery" {
+		t.Errorf("unexpected defer reason %q", i.DeferReason())
+	}
+	if _, ok := i.NextRun("r1"); ok {
+		t.Error("deferred repositories should stay due")
+	}
+
+	monitor.Set(power.Status{Percentage: 80}, nil)
+	if !i.startDue([]string{"r1"}, now, schedules) {
+		t.Error("run not reported as started")
+	}
+	if !reflect.DeepEqual(started, [][]string{{"r1"}}) {
+		t.Fatalf("scheduled run not started on AC: %v", started)
+	}
+	if i.DeferReason() != "" {
+		t.Errorf("defer reason not cleared: %q", i.DeferReason())
+	}
+	if next, ok := i.NextRun("r1"); !ok || !next.After(now) {
+		t.Errorf("next run not scheduled: %v", next)
+	}
+}
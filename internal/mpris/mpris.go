@@ -0,0 +1,237 @@
+// Package mpris exposes the file being streamed as an MPRIS media player on
+// the session bus, so it can be controlled from desktop media controls.
+//
+// See https://specifications.freedesktop.org/mpris-spec/latest/
+package mpris
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/swampapp/swamp/internal/eventbus"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/streamer"
+	"github.com/swampapp/swamp/internal/streamserver"
+)
+
+const (
+	busName     = "org.mpris.MediaPlayer2.swamp"
+	objectPath  = "/org/mpris/MediaPlayer2"
+	rootIface   = "org.mpris.MediaPlayer2"
+	playerIface = "org.mpris.MediaPlayer2.Player"
+	trackPrefix = "/com/github/swampapp/track/"
+	noTrack     = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+)
+
+const (
+	statusPlaying = "Playing"
+	statusPaused  = "Paused"
+	statusStopped = "Stopped"
+)
+
+type server struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// Start registers the MPRIS player on the session bus
+func Start() error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	reply, err := conn.RequestName(busName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("%s already taken", busName)
+	}
+
+	s := &server{conn: conn}
+
+	if err := conn.Export(root{}, objectPath, rootIface); err != nil {
+		return err
+	}
+	if err := conn.Export(player{}, objectPath, playerIface); err != nil {
+		return err
+	}
+
+	s.props = prop.New(conn, objectPath, map[string]map[string]*prop.Prop{
+		rootIface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitTrue},
+			"CanRaise":            {Value: false, Emit: prop.EmitTrue},
+			"HasTrackList":        {Value: false, Emit: prop.EmitTrue},
+			"Identity":            {Value: "Swamp", Emit: prop.EmitTrue},
+			"DesktopEntry":        {Value: "com.github.swampapp", Emit: prop.EmitTrue},
+			"SupportedUriSchemes": {Value: []string{}, Emit: prop.EmitTrue},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitTrue},
+		},
+		playerIface: {
+			"PlaybackStatus": {Value: statusStopped, Emit: prop.EmitTrue},
+			"Rate":           {Value: 1.0, Emit: prop.EmitTrue},
+			"MinimumRate":    {Value: 1.0, Emit: prop.EmitTrue},
+			"MaximumRate":    {Value: 1.0, Emit: prop.EmitTrue},
+			"Volume":         {Value: 1.0, Emit: prop.EmitTrue},
+			"Position":       {Value: int64(0), Emit: prop.EmitFalse},
+			"Metadata":       {Value: metadata(streamer.Track{}), Emit: prop.EmitTrue},
+			"CanGoNext":      {Value: false, Emit: prop.EmitTrue},
+			"CanGoPrevious":  {Value: false, Emit: prop.EmitTrue},
+			"CanPlay":        {Value: false, Emit: prop.EmitTrue},
+			"CanPause":       {Value: false, Emit: prop.EmitTrue},
+			"CanSeek":        {Value: false, Emit: prop.EmitTrue},
+			"CanControl":     {Value: true, Emit: prop.EmitFalse},
+		},
+	})
+
+	node := &introspect.Node{
+		Name: objectPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       rootIface,
+				Methods:    introspect.Methods(root{}),
+				Properties: s.props.Introspection(rootIface),
+			},
+			{
+				Name:       playerIface,
+				Methods:    introspect.Methods(player{}),
+				Properties: s.props.Introspection(playerIface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), objectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return err
+	}
+
+	eventbus.ListenTo(streamer.StreamingStarted, func(evt *eventbus.Event) {
+		track, _ := evt.Data.(streamer.Track)
+		s.update(statusPlaying, track)
+	})
+	eventbus.ListenTo(streamer.StreamingResumed, func(evt *eventbus.Event) {
+		track, _ := evt.Data.(streamer.Track)
+		s.update(statusPlaying, track)
+	})
+	eventbus.ListenTo(streamer.StreamingTrackChanged, func(evt *eventbus.Event) {
+		track, _ := evt.Data.(streamer.Track)
+		status := statusPlaying
+		if streamer.IsPaused() {
+			status = statusPaused
+		}
+		s.update(status, track)
+	})
+	eventbus.ListenTo(streamer.StreamingPaused, func(evt *eventbus.Event) {
+		track, _ := evt.Data.(streamer.Track)
+		s.update(statusPaused, track)
+	})
+	eventbus.ListenTo(streamer.StreamingStopped, func(evt *eventbus.Event) {
+		// a new stream may have started already
+		if streamer.IsStreaming() {
+			return
+		}
+		s.update(statusStopped, streamer.Track{})
+	})
+
+	logger.Printf("mpris: registered %s", busName)
+
+	return nil
+}
+
+func (s *server) update(status string, track streamer.Track) {
+	playing := status != statusStopped
+	s.props.SetMust(playerIface, "PlaybackStatus", status)
+	s.props.SetMust(playerIface, "Metadata", metadata(track))
+	s.props.SetMust(playerIface, "CanPlay", playing)
+	s.props.SetMust(playerIface, "CanPause", playing)
+	s.props.SetMust(playerIface, "CanSeek", playing && streamer.CanSeek())
+	s.props.SetMust(playerIface, "CanGoNext", playing && streamer.CanGoNext())
+	s.props.SetMust(playerIface, "CanGoPrevious", playing && streamer.CanGoPrevious())
+}
+
+func metadata(track streamer.Track) map[string]dbus.Variant {
+	if track.ID == "" {
+		return map[string]dbus.Variant{
+			"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(noTrack)),
+		}
+	}
+
+	m := map[string]dbus.Variant{
+		// file IDs are hex strings, valid object path elements
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(trackPrefix + track.ID)),
+		"xesam:title":   dbus.MakeVariant(track.Name),
+		"swamp:path":    dbus.MakeVariant(track.Path),
+	}
+	// the path is the one in the repository, the track is only available
+	// locally from the stream server
+	if url, err := streamserver.URL(track.ID, track.Name); err == nil {
+		m["xesam:url"] = dbus.MakeVariant(url)
+	}
+
+	return m
+}
+
+// root implements org.mpris.MediaPlayer2
+type root struct{}
+
+func (root) Raise() *dbus.Error {
+	return nil
+}
+
+func (root) Quit() *dbus.Error {
+	return nil
+}
+
+// player implements org.mpris.MediaPlayer2.Player
+type player struct{}
+
+func (player) Next() *dbus.Error {
+	return dbusError(streamer.Next())
+}
+
+func (player) Previous() *dbus.Error {
+	return dbusError(streamer.Previous())
+}
+
+func (player) Pause() *dbus.Error {
+	return dbusError(streamer.Pause())
+}
+
+func (player) PlayPause() *dbus.Error {
+	return dbusError(streamer.PlayPause())
+}
+
+func (player) Stop() *dbus.Error {
+	streamer.Stop()
+	return nil
+}
+
+func (player) Play() *dbus.Error {
+	return dbusError(streamer.Play())
+}
+
+// Seek offset is in microseconds
+func (player) Seek(offset int64) *dbus.Error {
+	return dbusError(streamer.Seek(float64(offset) / 1e6))
+}
+
+func (player) SetPosition(trackID dbus.ObjectPath, position int64) *dbus.Error {
+	return nil
+}
+
+func (player) OpenUri(uri string) *dbus.Error {
+	return nil
+}
+
+// the spec asks to ignore calls that can't be fulfilled
+func dbusError(err error) *dbus.Error {
+	if err == nil || err == streamer.ErrNotStreaming || err == streamer.ErrNotSupported {
+		return nil
+	}
+
+	logger.Error(err, "mpris: error controlling the player")
+	return dbus.MakeFailedError(err)
+}
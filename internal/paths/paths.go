@@ -29,6 +29,10 @@ func Initialize() error {
 		logger.Error(err, "error creating downloads directory")
 	}
 
+	if err = os.MkdirAll(CacheDir(), 0755); err != nil {
+		logger.Error(err, "error creating cache directory")
+	}
+
 	return err
 }
 
@@ -51,3 +55,9 @@ func RepositoriesDir() string {
 func ConfigPath() string {
 	return filepath.Join(os.Getenv("HOME"), ".config/com.github.swampapp", "config.yaml")
 }
+
+// CacheDir returns the directory where generated data that can be safely
+// removed is stored, like thumbnails
+func CacheDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".cache/com.github.swampapp")
+}
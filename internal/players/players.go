@@ -0,0 +1,229 @@
+// Package players finds the external program used to play or open a file,
+// based on the file type and the command templates configured.
+//
+// Templates are command lines with placeholders:
+//
+//	{url}       stream URL of the file
+//	{file}      path to the downloaded file
+//	{playlist}  path to an M3U playlist of stream URLs
+//	{ipc}       path to an mpv JSON IPC socket, to control playback.
+//	            Arguments using it are left out when there's no socket.
+//	-           the file contents are written to the program's stdin
+package players
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/swampapp/swamp/internal/config"
+)
+
+type Kind string
+
+const (
+	Video Kind = "video"
+	Audio Kind = "audio"
+	Image Kind = "image"
+	PDF   Kind = "pdf"
+	Other Kind = "other"
+)
+
+// Kinds lists the file types a player can be configured for
+var Kinds = []Kind{Video, Audio, Image, PDF, Other}
+
+// Defaults are the templates tried, in order, when none are configured
+var Defaults = map[Kind][]string{
+	Video: {
+		"mpv --player-operation-mode=pseudo-gui --force-window --input-ipc-server={ipc} {playlist}",
+		"vlc {playlist}",
+		"xdg-open {file}",
+	},
+	Audio: {
+		"mpv --player-operation-mode=pseudo-gui --force-window --input-ipc-server={ipc} {playlist}",
+		"vlc {playlist}",
+		"xdg-open {file}",
+	},
+	Image: {
+		"eog {file}",
+		"xdg-open {file}",
+	},
+	PDF: {
+		"evince {file}",
+		"xdg-open {file}",
+	},
+	Other: {
+		"xdg-open {file}",
+	},
+}
+
+// ErrNoPlayer is returned when none of the templates can be used
+var ErrNoPlayer = errors.New("no player found")
+
+// KindOf returns the kind of file, based on its extension
+func KindOf(name string) Kind {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp4", ".avi", ".mkv", ".m4v", ".webm", ".mpeg", ".mpg", ".mov", ".gif":
+		return Video
+	case ".mp3", ".wav", ".ogg", ".flac", ".m4a", ".opus", ".aac":
+		return Audio
+	case ".jpg", ".jpeg", ".png", ".bmp", ".webp", ".tiff", ".svg":
+		return Image
+	case ".pdf":
+		return PDF
+	default:
+		return Other
+	}
+}
+
+// Templates returns the configured templates for the kind of file, or the
+// defaults if none have been configured
+func Templates(kind Kind) []string {
+	if config.Exists() {
+		if t := config.Get().Players[string(kind)]; len(t) > 0 {
+			return t
+		}
+	}
+
+	return Defaults[kind]
+}
+
+// Args are the values available to replace template placeholders.
+//
+// Playlist is used for {playlist} when set, URL or File otherwise, so single
+// files, streamed or downloaded, can be played by templates expecting a
+// playlist.
+type Args struct {
+	URL      string
+	File     string
+	Playlist string
+	IPC      string
+}
+
+// Command is a resolved template
+type Command struct {
+	*exec.Cmd
+	// Stdin is true when the program expects the file contents in stdin
+	Stdin bool
+	// IPC is true when the program was given an IPC socket
+	IPC bool
+}
+
+// Find returns a command for the first template of the kind that can be
+// used: its program is installed and every placeholder it uses has a
+// value in args.
+func Find(kind Kind, args Args) (*Command, error) {
+	for _, t := range Templates(kind) {
+		cmd, err := Resolve(t, args)
+		if err != nil {
+			continue
+		}
+		return cmd, nil
+	}
+
+	return nil, fmt.Errorf("%w for %s files", ErrNoPlayer, kind)
+}
+
+// Resolve replaces the placeholders of the template
+func Resolve(template string, args Args) (*Command, error) {
+	fields, err := splitArgs(template)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	playlist := args.Playlist
+	if playlist == "" {
+		playlist = args.URL
+	}
+	if playlist == "" {
+		playlist = args.File
+	}
+
+	c := &Command{}
+	values := map[string]string{
+		"{url}":      args.URL,
+		"{file}":     args.File,
+		"{playlist}": playlist,
+		"{ipc}":      args.IPC,
+	}
+	resolved := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f == "-" {
+			c.Stdin = true
+			resolved = append(resolved, f)
+			continue
+		}
+		// playback control is optional
+		if strings.Contains(f, "{ipc}") && args.IPC == "" {
+			continue
+		}
+		for ph, v := range values {
+			if !strings.Contains(f, ph) {
+				continue
+			}
+			if v == "" {
+				return nil, fmt.Errorf("%s not available for %q", ph, template)
+			}
+			if ph == "{ipc}" {
+				c.IPC = true
+			}
+			f = strings.ReplaceAll(f, ph, v)
+		}
+		resolved = append(resolved, f)
+	}
+
+	if len(resolved) == 0 {
+		return nil, errors.New("empty command")
+	}
+	bin, err := exec.LookPath(resolved[0])
+	if err != nil {
+		return nil, err
+	}
+	c.Cmd = exec.Command(bin, resolved[1:]...)
+
+	return c, nil
+}
+
+// splitArgs splits a command line in arguments, honoring single and double
+// quotes
+func splitArgs(s string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,142 @@
+package players
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		"mpv {url}":                     {"mpv", "{url}"},
+		"  mpv   --force-window  - ":    {"mpv", "--force-window", "-"},
+		`vlc --meta-title="my movie" x`: {"vlc", "--meta-title=my movie", "x"},
+		`feh '{file}' ""`:               {"feh", "{file}", ""},
+		"":                              {},
+	}
+
+	for in, expected := range tests {
+		args, err := splitArgs(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("%q: expected %q, got %q", in, expected, args)
+		}
+	}
+
+	if _, err := splitArgs(`mpv "foo`); err == nil {
+		t.Error("unterminated quotes should fail")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	args := Args{URL: "http://127.0.0.1/files/x/y", IPC: "/tmp/mpv.sock"}
+
+	cmd, err := Resolve("sh -c true {url} --input-ipc-server={ipc}", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"-c", "true", args.URL, "--input-ipc-server=" + args.IPC}
+	if !reflect.DeepEqual(cmd.Args[1:], expected) {
+		t.Errorf("unexpected args %q", cmd.Args)
+	}
+	if !cmd.IPC || cmd.Stdin {
+		t.Error("IPC expected, no stdin")
+	}
+
+	// single files are used as playlists
+	cmd, err = Resolve("sh {playlist}", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Args[1] != args.URL {
+		t.Errorf("expected the URL as playlist, got %q", cmd.Args[1])
+	}
+
+	cmd, err = Resolve("sh -", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the program needs - to read from stdin
+	if !cmd.Stdin || len(cmd.Args) != 2 || cmd.Args[1] != "-" {
+		t.Errorf("stdin expected, got %q", cmd.Args)
+	}
+
+	if _, err := Resolve("sh {file}", args); err == nil {
+		t.Error("{file} isn't available")
+	}
+
+	if _, err := Resolve("this-program-does-not-exist {url}", args); err == nil {
+		t.Error("missing programs should fail")
+	}
+}
+
+// fakePath makes the given programs the only ones found, and no
+// configuration, until the returned function is called
+func fakePath(t *testing.T, programs ...string) func() {
+	dir := t.TempDir()
+	for _, p := range programs {
+		if err := ioutil.WriteFile(filepath.Join(dir, p), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, home := os.Getenv("PATH"), os.Getenv("HOME")
+	os.Setenv("PATH", dir)
+	os.Setenv("HOME", dir)
+	return func() {
+		os.Setenv("PATH", path)
+		os.Setenv("HOME", home)
+	}
+}
+
+func TestFindDownloaded(t *testing.T) {
+	file := "/tmp/downloads/ab/abcd"
+	tests := []struct {
+		programs []string
+		expected []string
+	}{
+		{[]string{"mpv", "vlc", "xdg-open"}, []string{"--player-operation-mode=pseudo-gui", "--force-window", file}},
+		{[]string{"vlc", "xdg-open"}, []string{file}},
+		{[]string{"xdg-open"}, []string{file}},
+	}
+
+	for _, kind := range []Kind{Video, Audio} {
+		for _, test := range tests {
+			restore := fakePath(t, test.programs...)
+			cmd, err := Find(kind, Args{File: file})
+			restore()
+			if err != nil {
+				t.Errorf("%s %v: %v", kind, test.programs, err)
+				continue
+			}
+			if filepath.Base(cmd.Path) != test.programs[0] || !reflect.DeepEqual(cmd.Args[1:], test.expected) {
+				t.Errorf("%s %v: unexpected command %q", kind, test.programs, cmd.Args)
+			}
+			if cmd.IPC {
+				t.Errorf("%s %v: no IPC socket was given", kind, test.programs)
+			}
+		}
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := map[string]Kind{
+		"movie.MKV":    Video,
+		"song.flac":    Audio,
+		"photo.jpeg":   Image,
+		"paper.pdf":    PDF,
+		"notes.txt":    Other,
+		"no-extension": Other,
+	}
+
+	for name, kind := range tests {
+		if k := KindOf(name); k != kind {
+			t.Errorf("%s: expected %s, got %s", name, kind, k)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package power tells the indexer if it's a good moment to index, based on
+// the power source and battery level reported by UPower.
+package power
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DefaultMinBattery is the battery percentage below which indexing is
+// deferred, when indexing on battery is allowed.
+const DefaultMinBattery = 30
+
+// Status is the power status of the system
+type Status struct {
+	OnBattery bool
+	// Percentage is the battery level, from 0 to 100. Meaningless when the
+	// system has no battery.
+	Percentage float64
+}
+
+// Monitor reports the power status of the system
+type Monitor interface {
+	Status() (Status, error)
+}
+
+// Policy decides if indexing can run given a power status
+type Policy struct {
+	// IndexOnBattery allows indexing on battery while the battery level is
+	// above MinBattery
+	IndexOnBattery bool
+	MinBattery     float64
+}
+
+// Allows returns true if indexing can run, or false and the reason why it
+// can't.
+func (p Policy) Allows(s Status) (bool, string) {
+	if !s.OnBattery {
+		return true, ""
+	}
+
+	if !p.IndexOnBattery {
+		return false, "running on battery"
+	}
+
+	min := p.MinBattery
+	if min <= 0 {
+		min = DefaultMinBattery
+	}
+	if s.Percentage < min {
+		return false, fmt.Sprintf("battery at %.0f%%, below %.0f%%", s.Percentage, min)
+	}
+
+	return true, ""
+}
+
+// FakeMonitor is a Monitor returning the status it's been told to, used in
+// tests and when UPower isn't available.
+type FakeMonitor struct {
+	mutex  sync.Mutex
+	status Status
+	err    error
+}
+
+// NewFakeMonitor returns a monitor reporting the system is on AC power
+func NewFakeMonitor() *FakeMonitor {
+	return &FakeMonitor{}
+}
+
+func (f *FakeMonitor) Status() (Status, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.status, f.err
+}
+
+// Set changes the status and error the monitor returns
+func (f *FakeMonitor) Set(s Status, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.status = s
+	f.err = err
+}
@@ -0,0 +1,51 @@
+package power
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		status  Status
+		allowed bool
+	}{
+		{Policy{}, Status{OnBattery: false}, true},
+		{Policy{}, Status{OnBattery: true, Percentage: 100}, false},
+		{Policy{IndexOnBattery: true}, Status{OnBattery: true, Percentage: 80}, true},
+		{Policy{IndexOnBattery: true}, Status{OnBattery: true, Percentage: 20}, false},
+		{Policy{IndexOnBattery: true, MinBattery: 10}, Status{OnBattery: true, Percentage: 20}, true},
+		{Policy{IndexOnBattery: true, MinBattery: 50}, Status{OnBattery: false, Percentage: 5}, true},
+	}
+
+	for _, test := range tests {
+		allowed, reason := test.policy.Allows(test.status)
+		if allowed != test.allowed {
+			t.Errorf("%+v with %+v: expected %v, got %v", test.policy, test.status, test.allowed, allowed)
+		}
+		if !allowed && reason == "" {
+			t.Errorf("%+v with %+v: a reason is expected", test.policy, test.status)
+		}
+	}
+}
+
+func TestFakeMonitor(t *testing.T) {
+	var m Monitor = NewFakeMonitor()
+
+	s, err := m.Status()
+	if err != nil || s.OnBattery {
+		t.Error("fake monitor should report AC power by default")
+	}
+
+	m.(*FakeMonitor).Set(Status{OnBattery: true, Percentage: 12}, nil)
+	s, _ = m.Status()
+	if allowed, _ := (Policy{IndexOnBattery: true}).Allows(s); allowed {
+		t.Error("indexing should be deferred with 12% battery")
+	}
+
+	m.(*FakeMonitor).Set(Status{}, errors.New("boom"))
+	if _, err := m.Status(); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,68 @@
+package power
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+const (
+	upowerDest          = "org.freedesktop.UPower"
+	upowerPath          = "/org/freedesktop/UPower"
+	upowerDisplayDevice = "/org/freedesktop/UPower/devices/DisplayDevice"
+)
+
+// UPower reads the power status from UPower over the system bus
+type UPower struct {
+	conn *dbus.Conn
+}
+
+// NewUPower connects to the system bus
+func NewUPower() (*UPower, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UPower{conn: conn}, nil
+}
+
+// NewMonitor returns a UPower monitor, or a monitor always reporting AC
+// power if UPower isn't available.
+func NewMonitor() Monitor {
+	u, err := NewUPower()
+	if err != nil {
+		logger.Error(err, "power: UPower not available, assuming AC power")
+		return NewFakeMonitor()
+	}
+
+	return u
+}
+
+func (u *UPower) Status() (Status, error) {
+	var s Status
+
+	v, err := u.conn.Object(upowerDest, upowerPath).GetProperty(upowerDest + ".OnBattery")
+	if err != nil {
+		return s, err
+	}
+	onBattery, ok := v.Value().(bool)
+	if !ok {
+		return s, fmt.Errorf("unexpected OnBattery value %v", v)
+	}
+	s.OnBattery = onBattery
+
+	// the display device aggregates all the batteries
+	v, err = u.conn.Object(upowerDest, upowerDisplayDevice).GetProperty(upowerDest + ".Device.Percentage")
+	if err != nil {
+		return s, err
+	}
+	percentage, ok := v.Value().(float64)
+	if !ok {
+		return s, fmt.Errorf("unexpected Percentage value %v", v)
+	}
+	s.Percentage = percentage
+
+	return s, nil
+}
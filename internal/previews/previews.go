@@ -0,0 +1,303 @@
+// Package previews generates thumbnails of indexed images and PDF documents
+// in the background, caching them in the user cache directory.
+//
+// Files not downloaded yet are fetched from the repository using the index
+// client, so files larger than MaxImageSize or MaxPDFSize are skipped.
+// PDF thumbnails require pdftoppm (poppler-utils).
+package previews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the GIF decoder
+	_ "image/jpeg" // register the JPEG decoder
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swampapp/swamp/internal/downloader"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/paths"
+)
+
+// Size is the maximum width and height of the thumbnails generated
+const Size = 256
+
+const (
+	// MaxImageSize is the size of the largest image previewed, in bytes
+	MaxImageSize = 20 * 1024 * 1024
+	// MaxPDFSize is the size of the largest PDF previewed, in bytes
+	MaxPDFSize = 50 * 1024 * 1024
+)
+
+const workers = 2
+const queueSize = 256
+const fetchTimeout = 2 * time.Minute
+
+// ErrNotSupported is returned when a preview can't be generated for a file
+var ErrNotSupported = errors.New("preview not supported")
+
+type request struct {
+	fileID    string
+	name      string
+	callbacks []func(string)
+}
+
+var once sync.Once
+var queue chan string
+var mutex sync.Mutex
+var pending = map[string]*request{}
+
+// files a thumbnail couldn't be generated for, not retried until restart
+var failed = map[string]struct{}{}
+
+// Path returns the path to the cached thumbnail of the file
+func Path(fileID string) string {
+	// thumbnails are spread in directories named after the first two
+	// characters of the ID
+	dir := fileID
+	if len(dir) > 2 {
+		dir = dir[:2]
+	}
+
+	return filepath.Join(paths.CacheDir(), "thumbnails", dir, fileID+".png")
+}
+
+// Cached returns the path to the thumbnail of the file, if it's been
+// generated already
+func Cached(fileID string) (string, bool) {
+	path := Path(fileID)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+
+	return path, true
+}
+
+// Supported returns true if a preview can be generated for a file with the
+// given name and size
+func Supported(name string, size uint64) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return size > 0 && size <= MaxImageSize
+	case ".pdf":
+		if _, err := exec.LookPath("pdftoppm"); err != nil {
+			return false
+		}
+		return size > 0 && size <= MaxPDFSize
+	default:
+		return false
+	}
+}
+
+// Request generates the thumbnail of the file in the background, calling fn
+// with its path when ready.
+//
+// fn is called right away if the thumbnail is cached, and never if the file
+// isn't supported or generating the thumbnail fails. Requests are dropped
+// when too many are queued.
+func Request(fileID, name string, size uint64, fn func(path string)) {
+	if path, ok := Cached(fileID); ok {
+		fn(path)
+		return
+	}
+
+	if !Supported(name, size) {
+		return
+	}
+
+	once.Do(start)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := failed[fileID]; ok {
+		return
+	}
+
+	if r, ok := pending[fileID]; ok {
+		r.callbacks = append(r.callbacks, fn)
+		return
+	}
+
+	select {
+	case queue <- fileID:
+		pending[fileID] = &request{fileID: fileID, name: name, callbacks: []func(string){fn}}
+	default:
+		logger.Debugf("previews: queue full, skipping %s", name)
+	}
+}
+
+func start() {
+	queue = make(chan string, queueSize)
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+}
+
+func worker() {
+	for id := range queue {
+		mutex.Lock()
+		r := pending[id]
+		mutex.Unlock()
+
+		err := generate(r)
+
+		mutex.Lock()
+		delete(pending, id)
+		if err != nil {
+			failed[id] = struct{}{}
+		}
+		mutex.Unlock()
+
+		if err != nil {
+			logger.Errorf(err, "previews: error generating thumbnail for %s", r.name)
+			continue
+		}
+
+		for _, fn := range r.callbacks {
+			fn(Path(id))
+		}
+	}
+}
+
+func generate(r *request) error {
+	src := downloader.PathFromID(r.fileID)
+	if _, err := os.Stat(src); err != nil {
+		tmp, err := fetch(r.fileID)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		src = tmp
+	}
+
+	dst := Path(r.fileID)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(r.name)) {
+	case ".pdf":
+		return thumbnailPDF(src, dst)
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return thumbnailImage(src, dst)
+	default:
+		return ErrNotSupported
+	}
+}
+
+// fetch retrieves the file from the repository into a temporary file
+func fetch(fileID string) (string, error) {
+	idx, err := index.Client()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile("", "swamp-preview-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	if err := idx.Fetch(ctx, fileID, f); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// thumbnailImage writes a PNG thumbnail of the image in src to dst
+func thumbnailImage(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+
+	out, err := os.Create(dst + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(out, scale(img, Size))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+
+	return os.Rename(out.Name(), dst)
+}
+
+// thumbnailPDF writes a PNG thumbnail of the first page of the PDF in src
+// to dst
+func thumbnailPDF(src, dst string) error {
+	prefix := dst + ".tmp"
+	cmd := exec.Command(
+		"pdftoppm",
+		"-png",
+		"-singlefile",
+		"-f", "1",
+		"-l", "1",
+		"-scale-to", fmt.Sprint(Size),
+		src,
+		prefix,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("pdftoppm failed: %w: %s", err, out)
+	}
+
+	return os.Rename(prefix+".png", dst)
+}
+
+// scale returns the image resized to fit in a max x max square, keeping the
+// aspect ratio. Smaller images are returned unchanged.
+func scale(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+
+	dw, dh := max, h*max/w
+	if h > w {
+		dw, dh = w*max/h, max
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	// nearest neighbour is good enough for thumbnails
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy := b.Min.Y + y*h/dh
+		for x := 0; x < dw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*w/dw, sy))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,106 @@
+package previews
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     uint64
+		expected bool
+	}{
+		{"photo.JPG", 1024, true},
+		{"photo.png", MaxImageSize, true},
+		{"photo.png", MaxImageSize + 1, false},
+		{"photo.png", 0, false},
+		{"photo.webp", 1024, false},
+		{"movie.mkv", 1024, false},
+	}
+
+	for _, test := range tests {
+		if s := Supported(test.name, test.size); s != test.expected {
+			t.Errorf("%s (%d bytes): expected %v, got %v", test.name, test.size, test.expected, s)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := map[string]string{
+		"abcdef": filepath.Join("ab", "abcdef.png"),
+		"ab":     filepath.Join("ab", "ab.png"),
+		"a":      filepath.Join("a", "a.png"),
+	}
+
+	for id, expected := range tests {
+		if p := Path(id); !strings.HasSuffix(p, filepath.Join("thumbnails", expected)) {
+			t.Errorf("%q: unexpected path %s", id, p)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		w, h   int
+		dw, dh int
+	}{
+		{100, 50, 100, 50},
+		{1024, 512, 256, 128},
+		{512, 1024, 128, 256},
+		{4000, 2, 256, 1},
+	}
+
+	for _, test := range tests {
+		img := scale(image.NewRGBA(image.Rect(0, 0, test.w, test.h)), 256)
+		b := img.Bounds()
+		if b.Dx() != test.dw || b.Dy() != test.dh {
+			t.Errorf("%dx%d: expected %dx%d, got %dx%d", test.w, test.h, test.dw, test.dh, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestThumbnailImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swamp-previews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 600, 300))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dst := filepath.Join(dir, "thumb.png")
+	if err := thumbnailImage(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != Size || cfg.Height != Size/2 {
+		t.Errorf("unexpected thumbnail size %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if err := thumbnailImage(filepath.Join(dir, "thumb.png.tmp"), dst); err == nil {
+		t.Error("missing source should fail")
+	}
+}
@@ -11,12 +11,15 @@ import (
 	"code.cloudfoundry.org/bytefmt"
 )
 
+// types match the file extension and the MIME type detected when indexing
 const (
-	TYPE_AUDIO = "ext:wav ext:mp3 ext:ogg ext:flac"
-	TYPE_VIDEO = "ext:mp4 ext:mkv ext:avi ext:webm ext:mov"
-	TYPE_DOC   = "ext:doc ext:docm ext:pdf ext:docx ext:odf ext:pages ext:rtf ext:html ext:webarchive"
-	TYPE_IMAGE = "ext:jpg ext:jpeg ext:png ext:gif ext:tiff ext:eps ext:raw"
-	TYPE_EBOOK = "ext:fb2 ext:ibook ext:cbr ext:djvu ext:epub ext:mobi"
+	TYPE_AUDIO = `ext:wav ext:mp3 ext:ogg ext:flac mime:audio\/*`
+	TYPE_VIDEO = `ext:mp4 ext:mkv ext:avi ext:webm ext:mov mime:video\/*`
+	TYPE_DOC   = `ext:doc ext:docm ext:pdf ext:docx ext:odf ext:pages ext:rtf ext:html ext:webarchive ` +
+		`mime:application\/pdf mime:application\/msword mime:text\/rtf mime:text\/html ` +
+		`mime:application\/vnd.oasis.opendocument.*`
+	TYPE_IMAGE = `ext:jpg ext:jpeg ext:png ext:gif ext:tiff ext:eps ext:raw mime:image\/*`
+	TYPE_EBOOK = `ext:fb2 ext:ibook ext:cbr ext:djvu ext:epub ext:mobi mime:application\/epub\+zip`
 )
 
 var sizeRegexp = regexp.MustCompile(`(?i)(\+?size):([<>=]{0,2})(\d+)(b|kb|mb|gb|tb)?`)
@@ -26,6 +29,9 @@ var myesterdayRegexp = regexp.MustCompile(`(?i)modified:yesterday`)
 var utodayRegexp = regexp.MustCompile(`(?i)updated:today`)
 var urecentlyRegexp = regexp.MustCompile(`(?i)updated:recently`)
 var uyesterdayRegexp = regexp.MustCompile(`(?i)updated:yesterday`)
+var durationRegexp = regexp.MustCompile(`(?i)^\+?duration:([<>=]{0,2})(\d+)(s|m|h)?$`)
+var takenRegexp = regexp.MustCompile(`(?i)^\+?taken:(\d{4})(?:-(\d{2}))?$`)
+var contentRegexp = regexp.MustCompile(`(?i)(?:^|\s)\+?content:("[^"]*"|\S+)`)
 
 // Parser represents a parser.
 type Parser struct {
@@ -41,6 +47,28 @@ func ParseQuery(q string) (string, error) {
 	return NewParser(strings.NewReader(q)).Parse()
 }
 
+// quoteEscaper escapes the runes ending or escaping a quoted phrase
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Quote returns the value as a quoted phrase, so it can contain whitespace,
+// quotes and backslashes, i.e. `path:` + Quote(dir)
+func Quote(value string) string {
+	return `"` + quoteEscaper.Replace(value) + `"`
+}
+
+// ContentTerms returns the words and phrases searched in the file contents
+// with content:word or content:"some words"
+func ContentTerms(q string) []string {
+	terms := []string{}
+	for _, m := range contentRegexp.FindAllStringSubmatch(q, -1) {
+		if term := strings.Trim(m[1], `"`); term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
 // NewParser returns a new instance of Parser.
 func NewParser(r io.Reader) *Parser {
 	return &Parser{s: NewScanner(r)}
@@ -81,6 +109,26 @@ func (p *Parser) Parse() (string, error) {
 			elements = append(elements, p.parseModified(lit))
 		}
 
+		if tok == DURATION {
+			lit, err := p.parseDuration(lit)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, lit)
+		}
+
+		if tok == TAKEN {
+			lit, err := p.parseTaken(lit)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, lit)
+		}
+
+		if tok == MIME {
+			elements = append(elements, p.parseMIME(lit))
+		}
+
 		if tok == UPDATED {
 			lit, err := p.parseUpdated(lit)
 			if err != nil {
@@ -167,6 +215,64 @@ func (p *Parser) parseSize(lit string) (string, error) {
 	return lit, nil
 }
 
+// parseDuration converts durations in seconds, minutes or hours to the
+// seconds stored in the duration field of audio and video files
+func (p *Parser) parseDuration(lit string) (string, error) {
+	m := durationRegexp.FindStringSubmatch(lit)
+	if m == nil {
+		return "", fmt.Errorf("invalid duration '%s' specified", lit)
+	}
+
+	n, err := strconv.ParseUint(m[2], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration '%s' specified", lit)
+	}
+	switch strings.ToLower(m[3]) {
+	case "m":
+		n *= 60
+	case "h":
+		n *= 3600
+	}
+
+	return fmt.Sprintf("+duration:%s%d", m[1], n), nil
+}
+
+// parseTaken converts taken:YYYY and taken:YYYY-MM to a range of the EXIF
+// date of photos
+func (p *Parser) parseTaken(lit string) (string, error) {
+	m := takenRegexp.FindStringSubmatch(lit)
+	if m == nil {
+		return "", fmt.Errorf("invalid date '%s' specified, expected taken:YYYY or taken:YYYY-MM", lit)
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(1, 0, 0)
+	if m[2] != "" {
+		month, _ := strconv.Atoi(m[2])
+		if month < 1 || month > 12 {
+			return "", fmt.Errorf("invalid month '%s' specified", m[2])
+		}
+		start = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+		end = start.AddDate(0, 1, 0)
+	}
+
+	return fmt.Sprintf("+exif_date:>=\"%s\" +exif_date:<\"%s\"", start.Format(time.RFC3339), end.Format(time.RFC3339)), nil
+}
+
+var mimeEscaper = strings.NewReplacer("/", `\/`, "+", `\+`, "-", `\-`)
+
+// parseMIME escapes MIME types for the query string syntax. mime:image
+// matches every image/ subtype.
+func (p *Parser) parseMIME(lit string) string {
+	value := lit[strings.Index(lit, ":")+1:]
+	if !strings.Contains(value, "/") {
+		value += "/*"
+	}
+
+	return "+mime:" + mimeEscaper.Replace(strings.ToLower(value))
+}
+
 func (p *Parser) parseType(lit string) string {
 	t := strings.Split(lit, ":")
 	if len(t) > 1 {
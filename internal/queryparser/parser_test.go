@@ -40,6 +40,11 @@ func TestParser_ParseStatement(t *testing.T) {
 			e: `+bar~2`,
 		},
 
+		{
+			q: `path:"/home/foo bar" ext:mp3`,
+			e: `+path:"/home/foo bar" +ext:mp3`,
+		},
+
 		{
 			q: `type:audio`,
 			e: queryparser.TYPE_AUDIO,
@@ -116,8 +121,45 @@ func TestParser_ParseStatement(t *testing.T) {
 			e: fmt.Sprintf(`+updated:>="%s" +updated:<="%s"`, ybod, yeod),
 		},
 
+		{
+			q: `content:"quarterly report" ext:md`,
+			e: `+content:"quarterly report" +ext:md`,
+		},
+
+		{
+			q: `duration:>5m type:audio`,
+			e: `+duration:>300 ` + queryparser.TYPE_AUDIO,
+		},
+		{
+			q: `duration:<=90`,
+			e: `+duration:<=90`,
+		},
+		{
+			q: `taken:2021 camera:canon`,
+			e: fmt.Sprintf(`+exif_date:>="%s" +exif_date:<"%s" +camera:canon`,
+				time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339),
+				time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)),
+		},
+		{
+			q: `taken:2021-12`,
+			e: fmt.Sprintf(`+exif_date:>="%s" +exif_date:<"%s"`,
+				time.Date(2021, 12, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339),
+				time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local).Format(time.RFC3339)),
+		},
+
+		{
+			q: `mime:application/epub+zip`,
+			e: `+mime:application\/epub\+zip`,
+		},
+		{
+			q: `+mime:image report-2021`,
+			e: `+mime:image\/* +report +2021`,
+		},
+
 		{q: `size:bMB`, err: `invalid size 'size:bMB' specified`},
 		{q: `size:cc`, err: `invalid size 'size:cc' specified`},
+		{q: `duration:long`, err: `invalid duration 'duration:long' specified`},
+		{q: `taken:2021-13`, err: `invalid month '13' specified`},
 	}
 
 	for i, tt := range tests {
@@ -130,9 +172,33 @@ func TestParser_ParseStatement(t *testing.T) {
 	}
 }
 
+func TestContentTerms(t *testing.T) {
+	terms := queryparser.ContentTerms(`report content:budget +content:"next year" ext:md`)
+	if !reflect.DeepEqual(terms, []string{"budget", "next year"}) {
+		t.Errorf("unexpected terms %v", terms)
+	}
+	if terms := queryparser.ContentTerms(`report`); len(terms) != 0 {
+		t.Errorf("unexpected terms %v", terms)
+	}
+}
+
 func errstring(err error) string {
 	if err != nil {
 		return err.Error()
 	}
 	return ""
 }
+
+func TestQuote(t *testing.T) {
+	for _, path := range []string{`/home/foo bar`, `/home/"quoted"`, `C:\dir\`, "/tabs\tand\nlines"} {
+		q := "path:" + queryparser.Quote(path) + " ext:mp3"
+		e := "+path:" + queryparser.Quote(path) + " +ext:mp3"
+		if parsed, err := queryparser.ParseQuery(q); err != nil || parsed != e {
+			t.Errorf("%q: expected %s, got %s (%v)", path, e, parsed, err)
+		}
+	}
+
+	if q := queryparser.Quote(`a "b" c\`); q != `"a \"b\" c\\"` {
+		t.Errorf("unexpected quoted value %s", q)
+	}
+}
@@ -74,6 +74,9 @@ func (s *Scanner) scanIdent() (tok Token, lit string) {
 		isRequired = true
 	} else {
 		buf.WriteRune(ch)
+		if ch == '"' {
+			s.scanQuoted(&buf)
+		}
 	}
 
 	// Read every subsequent ident character into the buffer.
@@ -81,11 +84,14 @@ func (s *Scanner) scanIdent() (tok Token, lit string) {
 	for {
 		if ch := s.read(); ch == eof {
 			break
-		} else if !isAllowed(ch) {
+		} else if !isAllowed(ch) && !isFieldRune(ch, &buf) {
 			s.unread()
 			break
 		} else {
 			_, _ = buf.WriteRune(ch)
+			if ch == '"' {
+				s.scanQuoted(&buf)
+			}
 		}
 	}
 
@@ -110,11 +116,62 @@ func (s *Scanner) scanIdent() (tok Token, lit string) {
 	if strings.HasPrefix(ls, "updated:") {
 		return UPDATED, finalKey()
 	}
+	if strings.HasPrefix(ls, "duration:") {
+		return DURATION, finalKey()
+	}
+	if strings.HasPrefix(ls, "taken:") {
+		return TAKEN, finalKey()
+	}
+	if strings.HasPrefix(ls, "mime:") {
+		return MIME, finalKey()
+	}
 
 	// Otherwise return as a regular identifier.
 	return IDENT, buf.String()
 }
 
+// scanQuoted consumes every rune until the closing quote, so quoted
+// phrases may contain whitespace and any other character. A backslash
+// escapes the next rune, like in Bleve queries, see Quote.
+func (s *Scanner) scanQuoted(buf *bytes.Buffer) {
+	escaped := false
+	for {
+		ch := s.read()
+		if ch == eof {
+			return
+		}
+		_, _ = buf.WriteRune(ch)
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '"':
+			return
+		}
+	}
+}
+
+// fieldRunes are the runes only allowed in the values of some fields
+var fieldRunes = map[string]string{
+	// year and month separator
+	"taken:": "-",
+	"mime:":  "/.-+",
+}
+
+// isFieldRune returns true if the rune is allowed in the value of the field
+// being scanned
+func isFieldRune(ch rune, buf *bytes.Buffer) bool {
+	ls := strings.ToLower(buf.String())
+	for field, runes := range fieldRunes {
+		if strings.HasPrefix(ls, field) {
+			return strings.ContainsRune(runes, ch)
+		}
+	}
+
+	return false
+}
+
 // read reads the next rune from the buffered reader.
 // Returns the rune(0) if an error occurs (or io.EOF is returned).
 func (s *Scanner) read() rune {
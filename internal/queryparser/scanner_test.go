@@ -27,6 +27,9 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `"foo"`, tok: parser.IDENT, lit: `"foo"`},
 		{s: `bar~2`, tok: parser.IDENT, lit: `bar~2`},
 		{s: `ext:mp3`, tok: parser.IDENT, lit: "ext:mp3"},
+		{s: `path:"/home/foo bar" baz`, tok: parser.IDENT, lit: `path:"/home/foo bar"`},
+		{s: `"foo bar`, tok: parser.IDENT, lit: `"foo bar`},
+		{s: `path:"a \"b\" c\\" baz`, tok: parser.IDENT, lit: `path:"a \"b\" c\\"`},
 
 		// Keywords
 		{s: `type:audio`, tok: parser.TYPE, lit: "type:audio"},
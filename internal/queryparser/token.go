@@ -17,4 +17,7 @@ const (
 	UPDATED
 	MODIFIED
 	SIZE
+	DURATION
+	TAKEN
+	MIME
 )
@@ -0,0 +1,48 @@
+// Package repocache opens restic repositories once and keeps them open,
+// loading the repository index is expensive.
+package repocache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/swampapp/swamp/internal/credentials"
+)
+
+var mutex sync.Mutex
+var repos = map[string]*repository.Repository{}
+
+// Open returns the repository with its index loaded, opening it the first
+// time it's requested
+func Open(repoID string) (*repository.Repository, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if repo, ok := repos[repoID]; ok {
+		return repo, nil
+	}
+
+	rs := credentials.New(repoID)
+	var repo *repository.Repository
+	err := credentials.WithS3Env(rs.Var1, rs.Var2, func() error {
+		opts := rapi.DefaultOptions
+		opts.Repo = rs.Repository
+		opts.Password = rs.Password
+
+		var err error
+		repo, err = rapi.OpenRepository(opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.LoadIndex(context.Background()); err != nil {
+		return nil, err
+	}
+
+	repos[repoID] = repo
+	return repo, nil
+}
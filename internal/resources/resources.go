@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"embed"
 
@@ -86,6 +87,42 @@ func ImageForDoc(name string) *gdk.Pixbuf {
 	}
 }
 
+// ImageForFile returns the icon for the file, from the MIME type detected
+// when indexing if known, from the file extension otherwise
+func ImageForFile(name, mime string) *gdk.Pixbuf {
+	if img := imageForMIME(mime); img != nil {
+		return img
+	}
+
+	return ImageForDoc(name)
+}
+
+func imageForMIME(mime string) *gdk.Pixbuf {
+	kind := strings.SplitN(mime, "/", 2)[0]
+	switch {
+	case kind == "image":
+		return imageImage
+	case kind == "video":
+		return imageVideo
+	case kind == "audio":
+		return imageAudio
+	case kind == "text",
+		mime == "application/pdf",
+		mime == "application/msword",
+		mime == "application/epub+zip",
+		strings.HasPrefix(mime, "application/vnd.oasis.opendocument."):
+		return imageDoc
+	}
+
+	switch mime {
+	case "application/zip", "application/x-gzip", "application/x-rar-compressed",
+		"application/x-7z-compressed", "application/x-xz", "application/x-bzip2":
+		return imageCompressed
+	}
+
+	return nil
+}
+
 // If this ever fails, we should crash hard
 func Pixbuf(path string) *gdk.Pixbuf {
 	// FIXME: hack, how do we detect a dark theme reliably?
@@ -0,0 +1,30 @@
+package schedule
+
+import (
+	"runtime"
+
+	"github.com/prometheus/procfs"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// the system is considered idle when the 1 minute load average per CPU is
+// below this
+const idleLoadPerCPU = 0.3
+
+// SystemIdle returns true if the system load is low enough to index
+// repositories scheduled to run only when idle.
+func SystemIdle() bool {
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		logger.Error(err, "schedule: error opening procfs")
+		return false
+	}
+
+	load, err := fs.LoadAvg()
+	if err != nil {
+		logger.Error(err, "schedule: error reading load average")
+		return false
+	}
+
+	return load.Load1 < idleLoadPerCPU*float64(runtime.NumCPU())
+}
@@ -0,0 +1,120 @@
+// Package schedule decides when repositories are indexed automatically.
+//
+// Schedules are stored per repository in the configuration file using one of
+// the following formats:
+//
+//	""                 every 60 minutes, the default
+//	"never"            never index automatically
+//	"every 2h"         every 2 hours (any Go duration)
+//	"cron 0 3 * * *"   using a 5 field cron expression
+//	"idle"             every 60 minutes, only when the system is idle
+//	"idle every 6h"    idle can be combined with any of the above
+//	"idle cron 0 * * * *"
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultInterval is used when a repository has no schedule configured
+const DefaultInterval = 60 * time.Minute
+
+// MinInterval prevents hammering the repository with indexing runs
+const MinInterval = 5 * time.Minute
+
+type Kind int
+
+const (
+	Interval Kind = iota
+	Cron
+	Never
+)
+
+type Schedule struct {
+	Kind     Kind
+	Interval time.Duration
+	// OnlyIdle postpones due runs until the system is idle
+	OnlyIdle bool
+	cron     *cronSpec
+	expr     string
+}
+
+// Parse parses a schedule spec, see the package documentation for the
+// supported formats.
+func Parse(spec string) (*Schedule, error) {
+	s := &Schedule{Kind: Interval, Interval: DefaultInterval}
+	spec = strings.TrimSpace(spec)
+
+	rest := spec
+	if rest == "idle" || strings.HasPrefix(rest, "idle ") {
+		s.OnlyIdle = true
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "idle"))
+	}
+
+	switch {
+	case rest == "":
+	case rest == "never":
+		if s.OnlyIdle {
+			return nil, fmt.Errorf("invalid schedule %q: idle can't be used with never", spec)
+		}
+		s.Kind = Never
+	case strings.HasPrefix(rest, "every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(rest, "every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if d < MinInterval {
+			return nil, fmt.Errorf("invalid schedule %q: interval shorter than %s", spec, MinInterval)
+		}
+		s.Interval = d
+	case strings.HasPrefix(rest, "cron "):
+		s.expr = strings.TrimSpace(strings.TrimPrefix(rest, "cron "))
+		c, err := parseCron(s.expr)
+		if err != nil {
+			return nil, err
+		}
+		s.Kind = Cron
+		s.Interval = 0
+		s.cron = c
+	default:
+		return nil, fmt.Errorf("invalid schedule %q", spec)
+	}
+
+	return s, nil
+}
+
+// Next returns when the repository should be indexed next, given the last
+// time it was indexed.
+//
+// The zero time is returned when the repository is never indexed
+// automatically.
+func (s *Schedule) Next(last time.Time) time.Time {
+	switch s.Kind {
+	case Never:
+		return time.Time{}
+	case Cron:
+		return s.cron.next(last)
+	default:
+		return last.Add(s.Interval)
+	}
+}
+
+func (s *Schedule) String() string {
+	var desc string
+	switch s.Kind {
+	case Never:
+		return "never"
+	case Cron:
+		desc = "cron " + s.expr
+	default:
+		desc = "every " + s.Interval.String()
+	}
+
+	if s.OnlyIdle {
+		desc += ", only when idle"
+	}
+
+	return desc
+}
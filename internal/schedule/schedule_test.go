@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := map[string]string{
+		"":                         "every 1h0m0s",
+		"never":                    "never",
+		"every 2h":                 "every 2h0m0s",
+		"idle":                     "every 1h0m0s, only when idle",
+		"idle every 30m":           "every 30m0s, only when idle",
+		"cron 0 3 * * *":           "cron 0 3 * * *",
+		"idle cron */15 * * * 1-5": "cron */15 * * * 1-5, only when idle",
+	}
+
+	for spec, desc := range valid {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Errorf("%q should be valid: %v", spec, err)
+			continue
+		}
+		if s.String() != desc {
+			t.Errorf("%q: expected %q, got %q", spec, desc, s.String())
+		}
+	}
+
+	invalid := []string{
+		"sometimes",
+		"every 1m",
+		"every day",
+		"idle never",
+		"cron * * *",
+		"cron 60 * * * *",
+		"cron 5-1 * * * *",
+		"cron */0 * * * *",
+	}
+
+	for _, spec := range invalid {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q should be invalid", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a Wednesday
+	last := time.Date(2021, time.June, 2, 10, 30, 15, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"every 2h":              time.Date(2021, time.June, 2, 12, 30, 15, 0, time.UTC),
+		"cron 0 3 * * *":        time.Date(2021, time.June, 3, 3, 0, 0, 0, time.UTC),
+		"cron */20 * * * *":     time.Date(2021, time.June, 2, 10, 40, 0, 0, time.UTC),
+		"cron 0 12 * * 0":       time.Date(2021, time.June, 6, 12, 0, 0, 0, time.UTC),
+		"cron 0 12 * * 7":       time.Date(2021, time.June, 6, 12, 0, 0, 0, time.UTC),
+		"cron 0 0 1 * *":        time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC),
+		"cron 0 0 29 2 *":       time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"cron 0 0 15 * 5":       time.Date(2021, time.June, 4, 0, 0, 0, 0, time.UTC),
+		"cron 5,35 10-11 * * *": time.Date(2021, time.June, 2, 10, 35, 0, 0, time.UTC),
+		"never":                 {},
+	}
+
+	for spec, expected := range tests {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.Next(last); !next.Equal(expected) {
+			t.Errorf("%q: expected %s, got %s", spec, expected, next)
+		}
+	}
+}
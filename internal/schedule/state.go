@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/swampapp/swamp/internal/paths"
+)
+
+// RunState records the last and next automatic indexing runs of a
+// repository
+type RunState struct {
+	LastRun time.Time
+	NextRun time.Time
+}
+
+// State is the scheduler state keyed by repository ID, saved by the app so
+// `swampd schedule` can inspect it.
+type State map[string]RunState
+
+func statePath() string {
+	return filepath.Join(paths.DataDir(), "schedule.json")
+}
+
+// LoadState returns the saved scheduler state, or an empty state if it
+// hasn't been saved yet.
+func LoadState() (State, error) {
+	s := State{}
+	b, err := ioutil.ReadFile(statePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, err
+	}
+
+	err = json.Unmarshal(b, &s)
+	return s, err
+}
+
+func (s State) Save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(statePath(), b, 0644)
+}
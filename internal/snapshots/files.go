@@ -0,0 +1,91 @@
+package snapshots
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// Files is the set of files stored in the snapshots of a repository,
+// identified by their path and content
+type Files struct {
+	// Snapshots is the number of snapshots walked
+	Snapshots int
+	files     map[string]bool
+	// visited holds the trees already walked, by tree ID and path, so the
+	// trees shared by snapshots are only walked once
+	visited map[string]bool
+}
+
+// AllFiles walks the tree of every snapshot in the repository, returning
+// the files found
+func AllFiles(ctx context.Context, repo *repository.Repository) (*Files, error) {
+	f := newFiles()
+	err := repo.List(ctx, restic.SnapshotFile, func(id restic.ID, size int64) error {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		f.Snapshots++
+		if sn.Tree == nil {
+			return nil
+		}
+
+		return f.walk(ctx, repo.LoadTree, *sn.Tree, "")
+	})
+
+	return f, err
+}
+
+func newFiles() *Files {
+	return &Files{files: map[string]bool{}, visited: map[string]bool{}}
+}
+
+// Contains returns true if a snapshot holds the file found in path with the
+// given content blobs
+func (f *Files) Contains(path string, blobs []string) bool {
+	return f.files[fileKey(path, blobs)]
+}
+
+// Len returns the number of distinct files found
+func (f *Files) Len() int {
+	return len(f.files)
+}
+
+func (f *Files) walk(ctx context.Context, load treeLoader, id restic.ID, dir string) error {
+	key := id.String() + ":" + dir
+	if f.visited[key] {
+		return nil
+	}
+	f.visited[key] = true
+
+	tree, err := load(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range tree.Nodes {
+		p := path.Join(dir, node.Name)
+		switch {
+		case node.Type == "file":
+			blobs := make([]string, len(node.Content))
+			for i, id := range node.Content {
+				blobs[i] = id.String()
+			}
+			f.files[fileKey(p, blobs)] = true
+		case node.Type == "dir" && node.Subtree != nil:
+			if err := f.walk(ctx, load, *node.Subtree, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func fileKey(path string, blobs []string) string {
+	return strings.Trim(path, "/") + "\x00" + strings.Join(blobs, ",")
+}
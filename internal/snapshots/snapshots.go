@@ -0,0 +1,137 @@
+// Package snapshots finds the restic snapshots a file is stored in.
+//
+// The index doesn't record snapshots, so they're found walking the tree of
+// every snapshot along the path of the file. Trees are shared by most
+// snapshots and only loaded once.
+package snapshots
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// Snapshot describes a snapshot containing a file
+type Snapshot struct {
+	ID       string
+	Time     time.Time
+	Hostname string
+}
+
+// ShortID returns the abbreviated snapshot ID restic displays
+func (s Snapshot) ShortID() string {
+	if len(s.ID) < 8 {
+		return s.ID
+	}
+	return s.ID[:8]
+}
+
+type treeLoader func(ctx context.Context, id restic.ID) (*restic.Tree, error)
+
+// Containing returns the snapshots holding the file found in path with the
+// given content blobs, oldest first.
+//
+// A file with the same path but different content is a different version
+// of the file, so it doesn't count.
+func Containing(ctx context.Context, repo *repository.Repository, path string, blobs []string) ([]Snapshot, error) {
+	trees := map[restic.ID]*restic.Tree{}
+	load := func(ctx context.Context, id restic.ID) (*restic.Tree, error) {
+		if t, ok := trees[id]; ok {
+			return t, nil
+		}
+		t, err := repo.LoadTree(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		trees[id] = t
+		return t, nil
+	}
+
+	found := []Snapshot{}
+	err := repo.List(ctx, restic.SnapshotFile, func(id restic.ID, size int64) error {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		if sn.Tree == nil {
+			return nil
+		}
+
+		ok, err := contains(ctx, load, *sn.Tree, path, blobs)
+		if err != nil || !ok {
+			return err
+		}
+
+		found = append(found, Snapshot{ID: id.String(), Time: sn.Time, Hostname: sn.Hostname})
+		return nil
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Time.Before(found[j].Time)
+	})
+
+	return found, err
+}
+
+// contains returns true if the tree has a file in path with the given
+// content. Any content matches if blobs is empty.
+func contains(ctx context.Context, load treeLoader, root restic.ID, path string, blobs []string) (bool, error) {
+	dir := strings.Trim(filepath.Dir(path), "/")
+	id := root
+
+	if dir != "" && dir != "." {
+		for _, name := range strings.Split(dir, "/") {
+			tree, err := load(ctx, id)
+			if err != nil {
+				return false, err
+			}
+			node := find(tree, name, "dir")
+			if node == nil || node.Subtree == nil {
+				return false, nil
+			}
+			id = *node.Subtree
+		}
+	}
+
+	tree, err := load(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	node := find(tree, filepath.Base(path), "file")
+	if node == nil {
+		return false, nil
+	}
+
+	return sameContent(node.Content, blobs), nil
+}
+
+func find(tree *restic.Tree, name, nodeType string) *restic.Node {
+	for _, n := range tree.Nodes {
+		if n.Name == name && n.Type == nodeType {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func sameContent(content restic.IDs, blobs []string) bool {
+	if len(blobs) == 0 {
+		return true
+	}
+	if len(content) != len(blobs) {
+		return false
+	}
+	for i, id := range content {
+		if id.String() != blobs[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,115 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestContains(t *testing.T) {
+	blob := restic.NewRandomID()
+	other := restic.NewRandomID()
+
+	trees := map[restic.ID]*restic.Tree{}
+	add := func(nodes ...*restic.Node) restic.ID {
+		id := restic.NewRandomID()
+		trees[id] = &restic.Tree{Nodes: nodes}
+		return id
+	}
+	load := func(ctx context.Context, id restic.ID) (*restic.Tree, error) {
+		t, ok := trees[id]
+		if !ok {
+			return nil, fmt.Errorf("tree %s not found", id)
+		}
+		return t, nil
+	}
+
+	docs := add(&restic.Node{Name: "notes.txt", Type: "file", Content: restic.IDs{blob}})
+	home := add(&restic.Node{Name: "docs", Type: "dir", Subtree: &docs})
+	root := add(
+		&restic.Node{Name: "home", Type: "dir", Subtree: &home},
+		&restic.Node{Name: "top.txt", Type: "file", Content: restic.IDs{other}},
+	)
+
+	tests := []struct {
+		path     string
+		blobs    []string
+		expected bool
+	}{
+		{"/home/docs/notes.txt", []string{blob.String()}, true},
+		{"/home/docs/notes.txt", nil, true},
+		{"/home/docs/notes.txt", []string{other.String()}, false},
+		{"/home/docs/missing.txt", nil, false},
+		{"/home/missing/notes.txt", nil, false},
+		{"/top.txt", []string{other.String()}, true},
+		// directories aren't files
+		{"/home/docs", nil, false},
+	}
+
+	for _, test := range tests {
+		ok, err := contains(context.Background(), load, root, test.path, test.blobs)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		if ok != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, ok)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	blob := restic.NewRandomID()
+	other := restic.NewRandomID()
+
+	trees := map[restic.ID]*restic.Tree{}
+	loaded := 0
+	add := func(nodes ...*restic.Node) restic.ID {
+		id := restic.NewRandomID()
+		trees[id] = &restic.Tree{Nodes: nodes}
+		return id
+	}
+	load := func(ctx context.Context, id restic.ID) (*restic.Tree, error) {
+		loaded++
+		return trees[id], nil
+	}
+
+	docs := add(&restic.Node{Name: "notes.txt", Type: "file", Content: restic.IDs{blob, other}})
+	home := add(
+		&restic.Node{Name: "docs", Type: "dir", Subtree: &docs},
+		&restic.Node{Name: "empty", Type: "file"},
+	)
+	root := add(&restic.Node{Name: "home", Type: "dir", Subtree: &home})
+
+	f := newFiles()
+	// two snapshots sharing the same tree
+	for i := 0; i < 2; i++ {
+		if err := f.walk(context.Background(), load, root, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loaded != 3 {
+		t.Errorf("shared trees loaded %d times", loaded)
+	}
+	if f.Len() != 2 {
+		t.Errorf("expected 2 files, got %d", f.Len())
+	}
+
+	tests := []struct {
+		path     string
+		blobs    []string
+		expected bool
+	}{
+		{"/home/docs/notes.txt", []string{blob.String(), other.String()}, true},
+		{"/home/docs/notes.txt", []string{blob.String()}, false},
+		{"/home/empty", []string{}, true},
+		{"/home/docs", nil, false},
+	}
+	for _, test := range tests {
+		if ok := f.Contains(test.path, test.blobs); ok != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.path, test.expected, ok)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package streamer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// player wraps the media player process.
+//
+// Players given an IPC socket are assumed to be mpv and controlled using its
+// JSON IPC protocol. Other players are paused and resumed with SIGSTOP and
+// SIGCONT and can't seek.
+type player struct {
+	cmd     *exec.Cmd
+	ipcPath string
+	// the M3U playlist file, if playing more than one file
+	playlist string
+	// the player's stdin, if it reads the file from it
+	stdin io.WriteCloser
+}
+
+// feed writes the file served from url to the player's stdin
+func (p *player) feed(ctx context.Context, url string) {
+	defer p.stdin.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		logger.Error(err, "error creating stream request")
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Error(err, "error streaming file")
+		return
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(p.stdin, resp.Body); err != nil && ctx.Err() == nil {
+		logger.Error(err, "error streaming file")
+	}
+}
+
+func (p *player) setPaused(paused bool) error {
+	if p.ipcPath != "" {
+		return p.command("set_property", "pause", paused)
+	}
+
+	if paused {
+		p.signal(syscall.SIGSTOP)
+	} else {
+		p.signal(syscall.SIGCONT)
+	}
+	return nil
+}
+
+func (p *player) seek(offset float64) error {
+	if p.ipcPath == "" {
+		return ErrNotSupported
+	}
+
+	return p.command("seek", offset, "relative")
+}
+
+// command sends a command to mpv, see
+// https://mpv.io/manual/stable/#json-ipc
+func (p *player) command(args ...interface{}) error {
+	_, err := p.request(args...)
+	return err
+}
+
+type mpvReply struct {
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	Event     string          `json:"event"`
+}
+
+// the request ID used to tell replies from events
+const mpvRequestID = 1
+
+func (p *player) request(args ...interface{}) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("unix", p.ipcPath, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		return nil, err
+	}
+
+	cmd, err := json.Marshal(map[string]interface{}{"command": args, "request_id": mpvRequestID})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Write(append(cmd, '\n')); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var reply mpvReply
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			return nil, err
+		}
+		if reply.Event != "" || reply.RequestID != mpvRequestID {
+			continue
+		}
+		if reply.Error != "success" {
+			return nil, fmt.Errorf("mpv: %s", reply.Error)
+		}
+		return reply.Data, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("mpv: no reply")
+}
+
+func (p *player) playlistPos() (int, error) {
+	data, err := p.request("get_property", "playlist-pos")
+	if err != nil {
+		return -1, err
+	}
+
+	var pos int
+	err = json.Unmarshal(data, &pos)
+	return pos, err
+}
+
+func (p *player) signal(sig os.Signal) {
+	if p.cmd.Process == nil {
+		return
+	}
+
+	if err := p.cmd.Process.Signal(sig); err != nil {
+		logger.Errorf(err, "error sending %s to the player", sig)
+	}
+}
+
+func (p *player) cleanup() {
+	for _, f := range []string{p.ipcPath, p.playlist} {
+		if f == "" {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			logger.Errorf(err, "error removing %s", f)
+		}
+	}
+}
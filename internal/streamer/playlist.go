@@ -0,0 +1,49 @@
+package streamer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/swampapp/swamp/internal/streamserver"
+)
+
+// WritePlaylist writes an M3U playlist with the stream URLs of the tracks.
+//
+// URLs are only valid while the stream server of this process is running.
+func WritePlaylist(w io.Writer, tracks []Track) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	for _, t := range tracks {
+		url, err := streamserver.URL(t.ID, t.Name)
+		if err != nil {
+			return err
+		}
+		// titles can't span multiple lines
+		title := strings.ReplaceAll(t.Name, "\n", " ")
+		fmt.Fprintf(bw, "#EXTINF:-1,%s\n%s\n", title, url)
+	}
+
+	return bw.Flush()
+}
+
+// writePlaylistFile writes the playlist to a temporary file only the user
+// can read, the stream URLs include the stream server token
+func writePlaylistFile(tracks []Track) (string, error) {
+	f, err := ioutil.TempFile("", "swamp-playlist-*.m3u")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	path := f.Name()
+
+	if err := WritePlaylist(f, tracks); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
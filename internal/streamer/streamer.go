@@ -2,39 +2,126 @@ package streamer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gotk3/gotk3/glib"
+	"github.com/rs/xid"
 	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/index"
 	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/players"
+	"github.com/swampapp/swamp/internal/streamserver"
 )
 
 var (
 	StreamingStarted = "streamer.starteds"
 	StreamingStopped = "streamer.stopped"
+	StreamingPaused  = "streamer.paused"
+	StreamingResumed = "streamer.resumed"
+	// StreamingTrackChanged is emitted when the player moves to another
+	// track of the playlist
+	StreamingTrackChanged = "streamer.track_changed"
 )
 
+// ErrNotStreaming is returned by the playback controls when nothing is
+// being streamed
+var ErrNotStreaming = errors.New("not streaming")
+
+// ErrNotSupported is returned when the player can't do what it's been
+// asked to
+var ErrNotSupported = errors.New("not supported by the player")
+
+// how often the playlist position is checked
+const playlistPollInterval = time.Second
+
+// Track is a file being streamed, sent with the streaming events
+type Track struct {
+	ID   string
+	Name string
+	Path string
+}
+
+type stream struct {
+	tracks []Track
+	pos    int
+	player *player
+	cancel context.CancelFunc
+	paused bool
+}
+
+func (s *stream) track() Track {
+	return s.tracks[s.pos]
+}
+
+var mutex sync.Mutex
+var current *stream
+
 func init() {
-	eventbus.RegisterEvents(StreamingStarted, StreamingStopped)
+	eventbus.RegisterEvents(
+		StreamingStarted,
+		StreamingStopped,
+		StreamingPaused,
+		StreamingResumed,
+		StreamingTrackChanged,
+	)
 }
 
+// IsStreamable returns true if the file looks like something a media player
+// can play
+func IsStreamable(name string) bool {
+	kind := players.KindOf(name)
+	return kind == players.Video || kind == players.Audio
+}
+
+// Stream plays the file using the player configured for its kind, blocking
+// until the player exits.
 func Stream(fileID string) error {
-	idx, err := index.Client()
+	doc, err := index.GetDocument(fileID)
 	if err != nil {
-		logger.Error(err, "error initializing the index")
+		logger.Errorf(err, "error retrieving document %s", fileID)
 		return err
 	}
 
-	logger.Print("streaming ", fileID)
+	return StreamPlaylist([]Track{{ID: fileID, Name: doc.Name, Path: doc.Path}})
+}
 
-	cmd, err := findPlayer()
-	if err != nil {
-		return err
+// StreamPlaylist plays the tracks in order using the player configured for
+// the kind of the first track, blocking until the player exits.
+//
+// Players stream the files from the local stream server, so they can seek.
+// Only one playlist is streamed at a time, streaming a new one stops the
+// current one.
+func StreamPlaylist(tracks []Track) error {
+	if len(tracks) == 0 {
+		return errors.New("nothing to stream")
 	}
 
-	stdin, err := cmd.StdinPipe()
+	logger.Printf("streaming %d files", len(tracks))
+
+	var target, playlist string
+	if len(tracks) == 1 {
+		url, err := streamserver.URL(tracks[0].ID, tracks[0].Name)
+		if err != nil {
+			logger.Error(err, "error starting the stream server")
+			return err
+		}
+		target = url
+	} else {
+		var err error
+		playlist, err = writePlaylistFile(tracks)
+		if err != nil {
+			logger.Error(err, "error writing the playlist")
+			return err
+		}
+		target = playlist
+	}
+
+	p, err := findPlayer(tracks, target, playlist)
 	if err != nil {
 		return err
 	}
@@ -42,41 +129,263 @@ func Stream(fileID string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go func() {
-		glib.IdleAdd(func() {
-			eventbus.Emit(context.Background(), StreamingStarted, nil)
-		})
+	Stop()
+	s := &stream{tracks: tracks, player: p, cancel: cancel}
+
+	err = p.cmd.Start()
+	if err != nil {
+		p.cleanup()
+		logger.Error(err, "error starting the player")
+		return err
+	}
 
-		defer glib.IdleAdd(func() {
-			eventbus.Emit(context.Background(), StreamingStopped, nil)
-		})
+	if p.stdin != nil {
+		go p.feed(ctx, target)
+	}
 
-		err = idx.Fetch(ctx, fileID, stdin)
-		if err != nil && err != context.Canceled {
-			logger.Error(err, "error streaming file")
+	mutex.Lock()
+	current = s
+	mutex.Unlock()
+
+	eventbus.Emit(context.Background(), StreamingStarted, s.track())
+
+	if len(tracks) > 1 && p.ipcPath != "" {
+		go watchPlaylist(ctx, s)
+	}
+
+	err = p.cmd.Wait()
+	// the player is killed when the stream is stopped
+	stopped := ctx.Err() != nil
+	cancel()
+	p.cleanup()
+
+	mutex.Lock()
+	if current == s {
+		current = nil
+	}
+	last := s.track()
+	mutex.Unlock()
+
+	eventbus.Emit(context.Background(), StreamingStopped, last)
+
+	if err != nil && !stopped {
+		logger.Error(err, "error playing files")
+		return err
+	}
+	logger.Info("streaming finished")
+	return nil
+}
+
+// watchPlaylist follows the player moving through the playlist
+func watchPlaylist(ctx context.Context, s *stream) {
+	ticker := time.NewTicker(playlistPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ticker.C:
+			pos, err := s.player.playlistPos()
+			if err != nil {
+				continue
+			}
+
+			mutex.Lock()
+			changed := pos != s.pos && pos >= 0 && pos < len(s.tracks)
+			if changed {
+				s.pos = pos
+			}
+			track := s.track()
+			mutex.Unlock()
+
+			if changed {
+				eventbus.Emit(context.Background(), StreamingTrackChanged, track)
+			}
 		}
-		logger.Info("streaming finished")
-	}()
+	}
+}
 
-	err = cmd.Run()
-	if err != nil {
-		logger.Errorf(err, "error playing file %s", fileID)
+// Current returns the file being streamed
+func Current() (Track, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if current == nil {
+		return Track{}, false
+	}
+	return current.track(), true
+}
+
+// IsStreaming returns true if a file is being streamed
+func IsStreaming() bool {
+	_, ok := Current()
+	return ok
+}
+
+// IsPaused returns true if the current stream is paused
+func IsPaused() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return current != nil && current.paused
+}
+
+// CanSeek returns true if the player can seek the current stream
+func CanSeek() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return current != nil && current.player.ipcPath != ""
+}
+
+// CanGoNext returns true if there's a track after the current one
+func CanGoNext() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return current != nil && current.player.ipcPath != "" && current.pos < len(current.tracks)-1
+}
+
+// CanGoPrevious returns true if there's a track before the current one
+func CanGoPrevious() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return current != nil && current.player.ipcPath != "" && current.pos > 0
+}
+
+// Stop stops streaming, closing the player
+func Stop() {
+	mutex.Lock()
+	s := current
+	current = nil
+	paused := s != nil && s.paused
+	mutex.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	logger.Print("stopping stream")
+	s.cancel()
+	if paused {
+		// a stopped process ignores SIGTERM until continued
+		s.player.signal(syscall.SIGCONT)
+	}
+	s.player.signal(syscall.SIGTERM)
+}
+
+// Pause pauses the current stream
+func Pause() error {
+	return setPaused(true)
+}
+
+// Play resumes the current stream
+func Play() error {
+	return setPaused(false)
+}
+
+// PlayPause toggles pause
+func PlayPause() error {
+	return setPaused(!IsPaused())
+}
+
+func setPaused(paused bool) error {
+	mutex.Lock()
+	s := current
+	mutex.Unlock()
+
+	if s == nil {
+		return ErrNotStreaming
+	}
+	if s.paused == paused {
+		return nil
+	}
+
+	if err := s.player.setPaused(paused); err != nil {
 		return err
 	}
-	return err
+
+	mutex.Lock()
+	s.paused = paused
+	track := s.track()
+	mutex.Unlock()
+
+	evt := StreamingResumed
+	if paused {
+		evt = StreamingPaused
+	}
+	eventbus.Emit(context.Background(), evt, track)
+
+	return nil
+}
+
+// Seek moves the playback position by offset seconds, backwards if negative
+func Seek(offset float64) error {
+	mutex.Lock()
+	s := current
+	mutex.Unlock()
+
+	if s == nil {
+		return ErrNotStreaming
+	}
+
+	return s.player.seek(offset)
+}
+
+// Next skips to the next track of the playlist
+func Next() error {
+	return playlistCommand("playlist-next")
+}
+
+// Previous goes back to the previous track of the playlist
+func Previous() error {
+	return playlistCommand("playlist-prev")
 }
 
-func findPlayer() (*exec.Cmd, error) {
-	cmd := exec.Command("mpv", "--player-operation-mode=pseudo-gui", "--force-window", "-")
-	_, err := exec.LookPath("mpv")
+func playlistCommand(cmd string) error {
+	mutex.Lock()
+	s := current
+	mutex.Unlock()
+
+	if s == nil {
+		return ErrNotStreaming
+	}
+	if s.player.ipcPath == "" {
+		return ErrNotSupported
+	}
+
+	return s.player.command(cmd)
+}
+
+// findPlayer returns the player configured for the kind of the first track
+func findPlayer(tracks []Track, target, playlist string) (*player, error) {
+	kind := players.KindOf(tracks[0].Name)
+	ipcPath := filepath.Join(os.TempDir(), fmt.Sprintf("swamp-mpv-%s.sock", xid.New().String()))
+	args := players.Args{URL: target, Playlist: playlist, IPC: ipcPath}
+	if playlist != "" {
+		args.URL = ""
+	}
+
+	c, err := players.Find(kind, args)
 	if err != nil {
-		_, err := exec.LookPath("vlc")
-		if err != nil {
-			return cmd, fmt.Errorf("mpv or vlc not found in PATH")
+		return nil, err
+	}
+	logger.Print("player command: ", c.Args)
+
+	p := &player{cmd: c.Cmd, playlist: playlist}
+	if c.IPC {
+		p.ipcPath = ipcPath
+	}
+	if c.Stdin {
+		if playlist != "" {
+			return nil, fmt.Errorf("%w: playlists can't be streamed to stdin", ErrNotSupported)
+		}
+		if p.stdin, err = c.StdinPipe(); err != nil {
+			return nil, err
 		}
-		cmd = exec.Command("vlc", "-")
 	}
 
-	return cmd, nil
+	return p, nil
 }
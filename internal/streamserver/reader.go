@@ -0,0 +1,87 @@
+package streamserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// blobLoader returns the plaintext contents of the i-th blob of a file
+type blobLoader func(ctx context.Context, i int) ([]byte, error)
+
+// blobReader reads a file stored as a sequence of restic data blobs,
+// loading only the blobs needed to satisfy each read, so HTTP range
+// requests don't need to fetch the whole file.
+type blobReader struct {
+	ctx     context.Context
+	load    blobLoader
+	offsets []int64
+	size    int64
+	pos     int64
+	// the last blob loaded, reads are mostly sequential
+	cached     int
+	cachedData []byte
+}
+
+// newBlobReader returns a reader over blobs with the given plaintext sizes
+func newBlobReader(ctx context.Context, sizes []int64, load blobLoader) *blobReader {
+	offsets := make([]int64, len(sizes))
+	var size int64
+	for i, s := range sizes {
+		offsets[i] = size
+		size += s
+	}
+
+	return &blobReader{ctx: ctx, load: load, offsets: offsets, size: size, cached: -1}
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	if b.pos >= b.size {
+		return 0, io.EOF
+	}
+
+	// the blob containing the current position
+	i := sort.Search(len(b.offsets), func(i int) bool { return b.offsets[i] > b.pos }) - 1
+
+	if i != b.cached {
+		data, err := b.load(b.ctx, i)
+		if err != nil {
+			return 0, err
+		}
+		b.cached = i
+		b.cachedData = data
+	}
+
+	off := b.pos - b.offsets[i]
+	if off >= int64(len(b.cachedData)) {
+		return 0, fmt.Errorf("blob %d shorter than expected", i)
+	}
+
+	n := copy(p, b.cachedData[off:])
+	b.pos += int64(n)
+
+	return n, nil
+}
+
+func (b *blobReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = b.pos + offset
+	case io.SeekEnd:
+		pos = b.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.pos = pos
+
+	return pos, nil
+}
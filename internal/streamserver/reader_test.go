@@ -0,0 +1,99 @@
+package streamserver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testReader(blobs ...string) (*blobReader, *int) {
+	loads := 0
+	sizes := make([]int64, len(blobs))
+	for i, b := range blobs {
+		sizes[i] = int64(len(b))
+	}
+
+	load := func(ctx context.Context, i int) ([]byte, error) {
+		loads++
+		return []byte(blobs[i]), nil
+	}
+
+	return newBlobReader(context.Background(), sizes, load), &loads
+}
+
+func TestBlobReader(t *testing.T) {
+	r, loads := testReader("hello ", "blob ", "world")
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello blob world" {
+		t.Errorf("unexpected content %q", b)
+	}
+	if *loads != 3 {
+		t.Errorf("expected 3 blob loads, got %d", *loads)
+	}
+
+	if _, err := r.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	*loads = 0
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "wor" {
+		t.Errorf("unexpected content %q", buf)
+	}
+	if *loads != 0 {
+		t.Error("the last blob should have been cached")
+	}
+
+	if _, err := r.Seek(7, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "lob" {
+		t.Errorf("unexpected content %q", buf)
+	}
+	if *loads != 1 {
+		t.Errorf("only the second blob should have been loaded, got %d loads", *loads)
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("negative positions should fail")
+	}
+}
+
+func TestBlobReaderRange(t *testing.T) {
+	r, loads := testReader("hello ", "blob ", "world")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(w, req, "test.txt", time.Time{}, r)
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=11-")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), []byte("world")) {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if rec.Header().Get("Content-Range") != "bytes 11-15/16" {
+		t.Errorf("unexpected Content-Range %s", rec.Header().Get("Content-Range"))
+	}
+	if *loads != 1 {
+		t.Errorf("only the last blob should have been loaded, got %d loads", *loads)
+	}
+}
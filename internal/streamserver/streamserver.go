@@ -0,0 +1,139 @@
+// Package streamserver serves indexed files over HTTP on the loopback
+// interface, so media players and browsers can stream and seek them.
+//
+// Range requests are mapped onto blob reads from the restic repository, only
+// the blobs covering the requested range are fetched.
+package streamserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/repocache"
+)
+
+var once sync.Once
+var startErr error
+var baseURL string
+
+// every URL includes a random token, the server is reachable by every local
+// user
+var token string
+
+// Start starts the server, if it isn't running yet
+func Start() error {
+	once.Do(func() {
+		b := make([]byte, 16)
+		if _, startErr = rand.Read(b); startErr != nil {
+			return
+		}
+		token = hex.EncodeToString(b)
+
+		var l net.Listener
+		l, startErr = net.Listen("tcp", "127.0.0.1:0")
+		if startErr != nil {
+			return
+		}
+		baseURL = "http://" + l.Addr().String()
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/files/", serveFile)
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		logger.Printf("streamserver: listening on %s", baseURL)
+		go func() {
+			if err := srv.Serve(l); err != nil {
+				logger.Error(err, "streamserver: server stopped")
+			}
+		}()
+	})
+
+	return startErr
+}
+
+// URL returns the URL streaming the file, starting the server if needed.
+//
+// The file name is appended so players can guess the file type.
+func URL(fileID, name string) (string, error) {
+	if err := Start(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/files/%s/%s/%s", baseURL, token, fileID, url.PathEscape(name)), nil
+}
+
+// serveFile serves /files/<token>/<file ID>/<name>
+func serveFile(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/files/"), "/")
+	if len(parts) < 2 || parts[0] != token {
+		http.NotFound(w, r)
+		return
+	}
+	fileID := parts[1]
+
+	doc, err := index.GetDocument(fileID)
+	if err != nil || doc.ID == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	ids, err := doc.BlobIDs()
+	if err != nil {
+		logger.Errorf(err, "streamserver: invalid blobs for %s", fileID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	repo, err := repocache.Open(config.Get().PreferredRepo())
+	if err != nil {
+		logger.Error(err, "streamserver: error opening repository")
+		http.Error(w, "error opening repository", http.StatusInternalServerError)
+		return
+	}
+
+	reader, err := newRepoReader(r.Context(), repo, ids)
+	if err != nil {
+		logger.Errorf(err, "streamserver: error reading %s", fileID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Debugf("streamserver: %s %s %s", r.Method, doc.Name, r.Header.Get("Range"))
+	http.ServeContent(w, r, doc.Name, time.Time{}, reader)
+}
+
+func newRepoReader(ctx context.Context, repo *repository.Repository, hexIDs []string) (*blobReader, error) {
+	ids := make([]restic.ID, len(hexIDs))
+	sizes := make([]int64, len(hexIDs))
+	for i, h := range hexIDs {
+		id, err := restic.ParseID(h)
+		if err != nil {
+			return nil, err
+		}
+		size, found := repo.LookupBlobSize(id, restic.DataBlob)
+		if !found {
+			return nil, fmt.Errorf("blob %s not found in the repository", h)
+		}
+		ids[i] = id
+		sizes[i] = int64(size)
+	}
+
+	load := func(ctx context.Context, i int) ([]byte, error) {
+		return repo.LoadBlob(ctx, restic.DataBlob, ids[i], nil)
+	}
+
+	return newBlobReader(ctx, sizes, load), nil
+}
@@ -0,0 +1,136 @@
+package tags
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/swampapp/swamp/internal/paths"
+)
+
+const (
+	// BackupInterval is how often BackupIfDue backs up the tags
+	BackupInterval = 24 * time.Hour
+	// KeepBackups is the number of backups kept per repository
+	KeepBackups = 14
+)
+
+const backupTimeFormat = "20060102T150405"
+
+// BackupDir returns the directory where the tag backups of the repository
+// are stored
+func BackupDir(repoID string) string {
+	return filepath.Join(paths.RepositoriesDir(), repoID, "tag-backups")
+}
+
+// Backups returns the paths of the backups of the repository tags, newest
+// first
+func Backups(repoID string) ([]string, error) {
+	entries, err := ioutil.ReadDir(BackupDir(repoID))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []string{}
+	for _, e := range entries {
+		if isBackup(e.Name()) {
+			backups = append(backups, filepath.Join(BackupDir(repoID), e.Name()))
+		}
+	}
+	// names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	return backups, nil
+}
+
+func isBackup(name string) bool {
+	return strings.HasPrefix(name, "tags-") && strings.HasSuffix(name, ".json")
+}
+
+// Backup writes a JSON export of the repository tags to its backup dir,
+// removing the oldest backups. Returns the path to the backup.
+func Backup(repoID string) (string, error) {
+	e, err := NewExport(repoID)
+	if err != nil {
+		return "", err
+	}
+
+	return writeBackup(repoID, e)
+}
+
+// BackupIfDue backs up the repository tags if the last backup is older than
+// BackupInterval. Nothing is backed up when there are no tags.
+func BackupIfDue(repoID string) (bool, error) {
+	backups, err := Backups(repoID)
+	if err != nil {
+		return false, err
+	}
+	if len(backups) > 0 {
+		t, err := backupTime(backups[0])
+		if err == nil && time.Since(t) < BackupInterval {
+			return false, nil
+		}
+	}
+
+	e, err := NewExport(repoID)
+	if err != nil || len(e.Files) == 0 {
+		return false, err
+	}
+
+	_, err = writeBackup(repoID, e)
+	return err == nil, err
+}
+
+func backupTime(path string) (time.Time, error) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "tags-"), ".json")
+	return time.ParseInLocation(backupTimeFormat, name, time.Local)
+}
+
+func writeBackup(repoID string, e *Export) (string, error) {
+	dir := BackupDir(repoID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "tags-"+e.Created.Format(backupTimeFormat)+".json")
+	tmp, err := ioutil.TempFile(dir, ".tags-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := e.WriteJSON(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, prune(repoID)
+}
+
+// prune removes the oldest backups, keeping KeepBackups
+func prune(repoID string) error {
+	backups, err := Backups(repoID)
+	if err != nil {
+		return err
+	}
+
+	for i := KeepBackups; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package tags
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidColor is returned when setting a color other than #rrggbb or a
+// color name Pango knows
+var ErrInvalidColor = errors.New("invalid tag color, use #rrggbb or a color name")
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// colorNames are the X11 color names Pango accepts, lowercase and without
+// spaces, as Pango compares them
+var colorNames = words(`
+	aliceblue antiquewhite aquamarine azure beige bisque black
+	blanchedalmond blue blueviolet brown burlywood cadetblue chartreuse
+	chocolate coral cornflowerblue cornsilk cyan darkblue darkcyan
+	darkgoldenrod darkgray darkgreen darkgrey darkkhaki darkmagenta
+	darkolivegreen darkorange darkorchid darkred darksalmon darkseagreen
+	darkslateblue darkslategray darkslategrey darkturquoise darkviolet
+	deeppink deepskyblue dimgray dimgrey dodgerblue firebrick floralwhite
+	forestgreen gainsboro ghostwhite gold goldenrod gray green greenyellow
+	grey honeydew hotpink indianred ivory khaki lavender lavenderblush
+	lawngreen lemonchiffon lightblue lightcoral lightcyan lightgoldenrod
+	lightgoldenrodyellow lightgray lightgreen lightgrey lightpink
+	lightsalmon lightseagreen lightskyblue lightslateblue lightslategray
+	lightslategrey lightsteelblue lightyellow limegreen linen magenta
+	maroon mediumaquamarine mediumblue mediumorchid mediumpurple
+	mediumseagreen mediumslateblue mediumspringgreen mediumturquoise
+	mediumvioletred midnightblue mintcream mistyrose moccasin navajowhite
+	navy navyblue oldlace olivedrab orange orangered orchid palegoldenrod
+	palegreen paleturquoise palevioletred papayawhip peachpuff peru pink
+	plum powderblue purple red rosybrown royalblue saddlebrown salmon
+	sandybrown seagreen seashell sienna skyblue slateblue slategray
+	slategrey snow springgreen steelblue tan thistle tomato turquoise
+	violet violetred wheat white whitesmoke yellow yellowgreen
+`)
+
+// shadedColors have four shades, named like orange1 to orange4
+var shadedColors = words(`
+	antiquewhite aquamarine azure bisque blue brown burlywood cadetblue
+	chartreuse chocolate coral cornsilk cyan darkgoldenrod darkolivegreen
+	darkorange darkorchid darkseagreen darkslategray deeppink deepskyblue
+	dodgerblue firebrick gold goldenrod green honeydew hotpink indianred
+	ivory khaki lavenderblush lemonchiffon lightblue lightcyan
+	lightgoldenrod lightpink lightsalmon lightskyblue lightsteelblue
+	lightyellow magenta maroon mediumorchid mediumpurple mistyrose
+	navajowhite olivedrab orange orangered orchid palegreen paleturquoise
+	palevioletred peachpuff pink plum purple red rosybrown royalblue
+	salmon seagreen seashell sienna skyblue slateblue slategray snow
+	springgreen steelblue tan thistle tomato turquoise violetred wheat
+	yellow
+`)
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+
+	return m
+}
+
+// validColor returns true if Pango can parse the color used in the tag
+// markup
+func validColor(color string) bool {
+	if hexColor.MatchString(color) {
+		return true
+	}
+
+	name := strings.ToLower(strings.ReplaceAll(color, " ", ""))
+	if colorNames[name] {
+		return true
+	}
+
+	// gray0 to gray100 and the shades
+	base := strings.TrimRight(name, "0123456789")
+	n, err := strconv.Atoi(name[len(base):])
+	if err != nil || strconv.Itoa(n) != name[len(base):] {
+		return false
+	}
+	if base == "gray" || base == "grey" {
+		return n <= 100
+	}
+
+	return shadedColors[base] && n >= 1 && n <= 4
+}
@@ -0,0 +1,19 @@
+package tags
+
+import "testing"
+
+func TestValidColor(t *testing.T) {
+	valid := []string{"#ff0000", "#A0b1C2", "red", "Light Blue", "DarkSlateGray3", "grey0", "gray100"}
+	for _, c := range valid {
+		if !validColor(c) {
+			t.Errorf("%q should be valid", c)
+		}
+	}
+
+	invalid := []string{"", "#ff00", "#ff00001", "ff0000", "#gg0000", "blurple", "red5", "black1", "gray101", "gray07", `red" foreground="blue`}
+	for _, c := range invalid {
+		if validColor(c) {
+			t.Errorf("%q should be invalid", c)
+		}
+	}
+}
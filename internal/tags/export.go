@@ -0,0 +1,342 @@
+package tags
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swampapp/swamp/internal/annotations"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// Entry is a tagged file, as exported
+type Entry struct {
+	FileID string     `json:"file_id"`
+	BHash  string     `json:"bhash,omitempty"`
+	Path   string     `json:"path,omitempty"`
+	Tags   []EntryTag `json:"tags"`
+	Note   string     `json:"note,omitempty"`
+	Rating int        `json:"rating,omitempty"`
+}
+
+func (e Entry) annotated() bool {
+	return e.Note != "" || e.Rating != 0
+}
+
+type EntryTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// Export holds the tags, notes and ratings of every file of a repository
+type Export struct {
+	RepositoryID string    `json:"repository_id"`
+	Created      time.Time `json:"created"`
+	Files        []Entry   `json:"files"`
+}
+
+// ImportResult summarizes how the imported files were matched to the
+// indexed ones
+type ImportResult struct {
+	// Matched files were found in the index by ID
+	Matched int
+	// Relinked files were found in the index by content hash and path,
+	// under a different ID
+	Relinked int
+	// Unmatched files weren't found in the index, their tags are imported
+	// with the original file ID
+	Unmatched []Entry
+}
+
+// NewExport returns the tags, notes and ratings of every file of the
+// repository.
+//
+// The content hash and path of the files found in the index are included,
+// so they can be matched again if the file IDs change.
+func NewExport(repoID string) (*Export, error) {
+	e := &Export{RepositoryID: repoID, Created: time.Now(), Files: []Entry{}}
+	err := withStore(DBPath(repoID), func(s *store) error {
+		return s.files(func(id string, tags []Tag) bool {
+			entry := Entry{FileID: id, Tags: []EntryTag{}}
+			for _, t := range tags {
+				entry.Tags = append(entry.Tags, EntryTag{Name: t.Name, Color: t.Color})
+			}
+			e.Files = append(e.Files, entry)
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := annotations.All(repoID)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range e.Files {
+		if a, ok := notes[f.FileID]; ok {
+			e.Files[i].Note, e.Files[i].Rating = a.Note, a.Rating
+			delete(notes, f.FileID)
+		}
+	}
+	// annotated files without tags
+	for id, a := range notes {
+		e.Files = append(e.Files, Entry{FileID: id, Tags: []EntryTag{}, Note: a.Note, Rating: a.Rating})
+	}
+
+	ids := make([]string, 0, len(e.Files))
+	for _, f := range e.Files {
+		ids = append(ids, f.FileID)
+	}
+	docs, err := index.Lookup(index.PathFor(repoID), ids)
+	if err != nil {
+		// tags are worth exporting even if the index can't be read
+		logger.Errorf(err, "error looking up the tagged files of %s", repoID)
+	}
+	byID := map[string]index.Document{}
+	for _, d := range docs {
+		byID[d.ID] = d
+	}
+	for i, f := range e.Files {
+		if d, ok := byID[f.FileID]; ok {
+			e.Files[i].BHash = d.BHash
+			e.Files[i].Path = d.Path
+		}
+	}
+
+	sort.Slice(e.Files, func(i, j int) bool {
+		return e.Files[i].FileID < e.Files[j].FileID
+	})
+
+	return e, nil
+}
+
+func (e *Export) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// WriteCSV writes one row per file tag, repeating the file note and rating.
+// Files without tags get a single row with an empty tag.
+func (e *Export) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, f := range e.Files {
+		tags := f.Tags
+		if len(tags) == 0 {
+			tags = []EntryTag{{}}
+		}
+		for _, t := range tags {
+			row := []string{f.FileID, f.BHash, f.Path, t.Name, t.Color, f.Note, strconv.Itoa(f.Rating)}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+var csvHeader = []string{"file_id", "bhash", "path", "tag", "color", "note", "rating"}
+
+// exports written before notes were added only have the first columns
+const minCSVFields = 5
+
+func ReadJSON(r io.Reader) (*Export, error) {
+	e := &Export{}
+	if err := json.NewDecoder(r).Decode(e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// ReadCSV reads the tags written by WriteCSV
+func ReadCSV(r io.Reader) (*Export, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || rows[0][0] != csvHeader[0] {
+		return nil, fmt.Errorf("missing CSV header")
+	}
+
+	e := &Export{Files: []Entry{}}
+	files := map[string]int{}
+	for n, row := range rows[1:] {
+		if len(row) < minCSVFields {
+			return nil, fmt.Errorf("line %d: expected at least %d fields", n+2, minCSVFields)
+		}
+		i, ok := files[row[0]]
+		if !ok {
+			i = len(e.Files)
+			files[row[0]] = i
+			e.Files = append(e.Files, Entry{FileID: row[0], BHash: row[1], Path: row[2], Tags: []EntryTag{}})
+		}
+		if row[3] != "" {
+			e.Files[i].Tags = append(e.Files[i].Tags, EntryTag{Name: row[3], Color: row[4]})
+		}
+		if len(row) == len(csvHeader) {
+			e.Files[i].Note = row[5]
+			e.Files[i].Rating, _ = strconv.Atoi(row[6])
+		}
+	}
+
+	return e, nil
+}
+
+// ReadFile reads a CSV or JSON export, depending on the file extension
+func ReadFile(path string) (*Export, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		return ReadCSV(f)
+	}
+
+	return ReadJSON(f)
+}
+
+// Import adds the exported tags to the files of the repository, keeping
+// the tags they already have. Notes and ratings are imported for files
+// without one.
+//
+// Files not found in the index by ID are matched by content hash and path.
+func Import(repoID string, e *Export) (ImportResult, error) {
+	resolved, result, err := resolve(index.PathFor(repoID), e.Files)
+	if err != nil {
+		return result, err
+	}
+
+	tagged := map[string][]Tag{}
+	ids := []string{}
+	for i, f := range e.Files {
+		id := resolved[i]
+		if _, ok := tagged[id]; !ok && len(f.Tags) > 0 {
+			ids = append(ids, id)
+		}
+		for _, t := range f.Tags {
+			tagged[id] = append(tagged[id], Tag{Name: t.Name, Color: t.Color})
+		}
+	}
+
+	err = withStore(DBPath(repoID), func(s *store) error {
+		return s.updateEach(ids, func(id string, tags []Tag) []Tag {
+			for _, t := range tagged[id] {
+				if !hasTag(tags, t.Name) {
+					tags = append(tags, t)
+				}
+			}
+			return tags
+		})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for i, f := range e.Files {
+		if !f.annotated() {
+			continue
+		}
+		current, err := annotations.Get(repoID, resolved[i])
+		if err != nil {
+			return result, err
+		}
+		if !current.IsZero() {
+			continue
+		}
+		err = annotations.Set(repoID, resolved[i], annotations.Annotation{Note: f.Note, Rating: f.Rating})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// resolve finds the current ID of every entry in the index found in
+// indexPath
+func resolve(indexPath string, entries []Entry) ([]string, ImportResult, error) {
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.FileID)
+	}
+
+	known := map[string]bool{}
+	docs, err := index.Lookup(indexPath, ids)
+	if err != nil {
+		logger.Errorf(err, "error looking up the imported files")
+	}
+	for _, d := range docs {
+		known[d.ID] = true
+	}
+
+	byContent := map[string]string{}
+	if len(known) < len(entries) && err == nil {
+		err = index.ForEach(indexPath, func(d index.Document) bool {
+			byContent[contentKey(d.BHash, d.Path)] = d.ID
+			return true
+		})
+		if err != nil {
+			return nil, ImportResult{}, err
+		}
+	}
+
+	ids, result := relink(entries, known, byContent)
+	return ids, result, nil
+}
+
+// relink returns the ID of the indexed file of every entry, known being the
+// IDs found in the index and byContent the IDs indexed by contentKey.
+// Entries not found keep their ID.
+func relink(entries []Entry, known map[string]bool, byContent map[string]string) ([]string, ImportResult) {
+	result := ImportResult{Unmatched: []Entry{}}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		id := e.FileID
+		if known[id] {
+			result.Matched++
+		} else if to, ok := match(e, byContent); ok {
+			id = to
+			result.Relinked++
+		} else {
+			result.Unmatched = append(result.Unmatched, e)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, result
+}
+
+// match returns the ID of the indexed file with the content hash and path
+// of the entry
+func match(e Entry, byContent map[string]string) (string, bool) {
+	if e.BHash == "" {
+		return "", false
+	}
+	id, ok := byContent[contentKey(e.BHash, e.Path)]
+
+	return id, ok
+}
+
+func contentKey(bhash, path string) string {
+	return bhash + "\x00" + path
+}
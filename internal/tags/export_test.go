@@ -0,0 +1,65 @@
+package tags
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	e := &Export{Files: []Entry{
+		{FileID: "a", BHash: "h1", Path: "/docs/a.txt", Tags: []EntryTag{{Name: "x", Color: "#ff0000"}, {Name: "y"}}},
+		{FileID: "b", Tags: []EntryTag{{Name: "x", Color: "#ff0000"}}, Note: "kept, \"quoted\"", Rating: 4},
+		// notes without tags
+		{FileID: "c", Tags: []EntryTag{}, Note: "note", Rating: 0},
+	}}
+
+	var buf bytes.Buffer
+	if err := e.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read.Files, e.Files) {
+		t.Errorf("unexpected files %v", read.Files)
+	}
+}
+
+func TestRelink(t *testing.T) {
+	entries := []Entry{
+		{FileID: "a", Tags: []EntryTag{{Name: "x"}}},
+		{FileID: "old", BHash: "h1", Path: "/b", Tags: []EntryTag{{Name: "y"}}},
+		{FileID: "gone", BHash: "h2", Path: "/c", Tags: []EntryTag{{Name: "z"}}},
+	}
+	known := map[string]bool{"a": true}
+	byContent := map[string]string{
+		contentKey("h1", "/b"): "new",
+		// same content, different path
+		contentKey("h2", "/d"): "other",
+	}
+
+	ids, result := relink(entries, known, byContent)
+
+	if !reflect.DeepEqual(ids, []string{"a", "new", "gone"}) {
+		t.Errorf("unexpected IDs %v", ids)
+	}
+	if result.Matched != 1 || result.Relinked != 1 || len(result.Unmatched) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestReadOldCSV(t *testing.T) {
+	e, err := ReadCSV(strings.NewReader("file_id,bhash,path,tag,color\na,h,/a,x,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Entry{{FileID: "a", BHash: "h", Path: "/a", Tags: []EntryTag{{Name: "x"}}}}
+	if !reflect.DeepEqual(e.Files, expected) {
+		t.Errorf("unexpected files %v", e.Files)
+	}
+}
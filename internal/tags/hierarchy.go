@@ -0,0 +1,58 @@
+package tags
+
+import "strings"
+
+// Separator separates the levels of hierarchical tag names, like
+// project/alpha/specs
+const Separator = "/"
+
+// subtreeSuffix selects a tag and every tag below it in queries, like
+// tag:project/*
+const subtreeSuffix = Separator + "*"
+
+// Normalize trims the spaces and empty levels of the tag name
+func Normalize(name string) string {
+	levels := []string{}
+	for _, l := range strings.Split(name, Separator) {
+		if l = strings.TrimSpace(l); l != "" {
+			levels = append(levels, l)
+		}
+	}
+
+	return strings.Join(levels, Separator)
+}
+
+// Parent returns the name of the tag above name, or an empty string for top
+// level tags
+func Parent(name string) string {
+	i := strings.LastIndex(name, Separator)
+	if i < 0 {
+		return ""
+	}
+
+	return name[:i]
+}
+
+// Base returns the last level of the tag name
+func Base(name string) string {
+	return name[strings.LastIndex(name, Separator)+1:]
+}
+
+// Subtree returns the tag a subtree query like project/* selects
+func Subtree(query string) (string, bool) {
+	if !strings.HasSuffix(query, subtreeSuffix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(query, subtreeSuffix), true
+}
+
+// SubtreeQuery returns the query selecting the tag and every tag below it
+func SubtreeQuery(name string) string {
+	return name + subtreeSuffix
+}
+
+// InSubtree returns true if name is root or a tag below it
+func InSubtree(name, root string) bool {
+	return name == root || strings.HasPrefix(name, root+Separator)
+}
@@ -0,0 +1,42 @@
+package tags
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for name, expected := range map[string]string{
+		"project":               "project",
+		" project / alpha/ ":    "project/alpha",
+		"/project//alpha/specs": "project/alpha/specs",
+		"//":                    "",
+	} {
+		if n := Normalize(name); n != expected {
+			t.Errorf("%q normalized to %q, expected %q", name, n, expected)
+		}
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	if p := Parent("project/alpha/specs"); p != "project/alpha" {
+		t.Errorf("unexpected parent %q", p)
+	}
+	if p := Parent("project"); p != "" {
+		t.Errorf("unexpected parent %q", p)
+	}
+	if b := Base("project/alpha"); b != "alpha" {
+		t.Errorf("unexpected base %q", b)
+	}
+
+	if root, ok := Subtree("project/*"); !ok || root != "project" {
+		t.Errorf("unexpected subtree %q", root)
+	}
+	if _, ok := Subtree("project"); ok {
+		t.Error("project isn't a subtree query")
+	}
+
+	if !InSubtree("project/alpha", "project") || !InSubtree("project", "project") {
+		t.Error("expected tags in the project subtree")
+	}
+	if InSubtree("projects", "project") {
+		t.Error("projects isn't below project")
+	}
+}
@@ -0,0 +1,173 @@
+package tags
+
+import (
+	"sort"
+
+	"github.com/swampapp/swamp/internal/annotations"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+)
+
+// Relinked is an orphaned file whose tags were moved to a new file ID
+type Relinked struct {
+	From string
+	To   string
+	Path string
+}
+
+// RelinkReport lists the orphaned files recovered by Relink and the ones
+// that couldn't be matched to an indexed file
+type RelinkReport struct {
+	Recovered []Relinked
+	Dangling  []Entry
+}
+
+// Orphans returns the tagged files not found in the repository index,
+// usually because the file IDs changed after indexing it again.
+//
+// The content hash and path of every orphan are included when known, as
+// recorded when tagging the file or found in the tag backups.
+func Orphans(repoID string) ([]Entry, error) {
+	var orphans []Entry
+	err := withStore(DBPath(repoID), func(s *store) error {
+		var err error
+		orphans, err = s.orphans(index.PathFor(repoID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := identifyFromBackups(repoID, orphans); err != nil {
+		logger.Errorf(err, "error reading the tag backups of %s", repoID)
+	}
+
+	return orphans, nil
+}
+
+// Relink moves the tags, notes and ratings of the orphaned files to the
+// indexed files with the same content hash and path
+func Relink(repoID string) (RelinkReport, error) {
+	report := RelinkReport{Recovered: []Relinked{}, Dangling: []Entry{}}
+	orphans, err := Orphans(repoID)
+	if err != nil || len(orphans) == 0 {
+		return report, err
+	}
+
+	byContent := map[string]string{}
+	err = index.ForEach(index.PathFor(repoID), func(d index.Document) bool {
+		byContent[contentKey(d.BHash, d.Path)] = d.ID
+		return true
+	})
+	if err != nil {
+		return report, err
+	}
+
+	moves := map[string]string{}
+	for _, o := range orphans {
+		to, ok := match(o, byContent)
+		if !ok {
+			report.Dangling = append(report.Dangling, o)
+			continue
+		}
+		moves[o.FileID] = to
+		report.Recovered = append(report.Recovered, Relinked{From: o.FileID, To: to, Path: o.Path})
+	}
+	if len(moves) == 0 {
+		return report, nil
+	}
+
+	err = withStore(DBPath(repoID), func(s *store) error {
+		return s.move(moves)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, annotations.Move(repoID, moves)
+}
+
+// orphans returns the tagged files not found in the index found in
+// indexPath, recording the identity of the ones found
+func (s *store) orphans(indexPath string) ([]Entry, error) {
+	tagged := map[string][]Tag{}
+	ids := []string{}
+	err := s.files(func(id string, tags []Tag) bool {
+		tagged[id] = tags
+		ids = append(ids, id)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := index.Lookup(indexPath, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.remember(docs); err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		delete(tagged, d.ID)
+	}
+
+	orphans := []Entry{}
+	for id, tags := range tagged {
+		e := Entry{FileID: id, Tags: []EntryTag{}}
+		for _, t := range tags {
+			e.Tags = append(e.Tags, EntryTag{Name: t.Name, Color: t.Color})
+		}
+		ident, _, err := s.identity(id)
+		if err != nil {
+			return nil, err
+		}
+		e.BHash, e.Path = ident.BHash, ident.Path
+		orphans = append(orphans, e)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].FileID < orphans[j].FileID
+	})
+
+	return orphans, nil
+}
+
+// identifyFromBackups fills the content hash and path of the orphans with
+// unknown identity from the newest backup that has them
+func identifyFromBackups(repoID string, orphans []Entry) error {
+	missing := map[string]int{}
+	for i, o := range orphans {
+		if o.BHash == "" {
+			missing[o.FileID] = i
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	backups, err := Backups(repoID)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range backups {
+		e, err := ReadFile(b)
+		if err != nil {
+			return err
+		}
+		for _, f := range e.Files {
+			i, ok := missing[f.FileID]
+			if !ok || f.BHash == "" {
+				continue
+			}
+			orphans[i].BHash, orphans[i].Path = f.BHash, f.Path
+			delete(missing, f.FileID)
+		}
+		if len(missing) == 0 {
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package tags
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rubiojr/rindex"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/paths"
+	"github.com/swampapp/swamp/internal/queryparser"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule tags the files matching Query with Tag
+type Rule struct {
+	Query string
+	Tag   string
+	// LastApplied is when the rule was last applied. The files it was
+	// applied to are recorded in the tags database, and only tagged once,
+	// so files untagged by the user aren't tagged again.
+	LastApplied time.Time
+}
+
+func rulesPath(repoID string) string {
+	return filepath.Join(paths.RepositoriesDir(), repoID, "tag_rules.yaml")
+}
+
+// Rules returns the tag rules of the repository
+func Rules(repoID string) ([]Rule, error) {
+	rules := []Rule{}
+
+	f, err := ioutil.ReadFile(rulesPath(repoID))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(f, &rules)
+	return rules, err
+}
+
+// SaveRules replaces the tag rules of the repository
+func SaveRules(repoID string, rules []Rule) error {
+	d, err := yaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(rulesPath(repoID), d, 0600)
+}
+
+// AddRule saves a new tag rule, applied to every matching file the next
+// time rules are applied
+func AddRule(repoID, query, tag string) error {
+	if _, err := queryparser.ParseQuery(query); err != nil {
+		return err
+	}
+
+	rules, err := Rules(repoID)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if r.Query == query && r.Tag == tag {
+			return nil
+		}
+	}
+
+	return SaveRules(repoID, append(rules, Rule{Query: query, Tag: tag}))
+}
+
+// RemoveRule deletes the rule tagging files matching query with tag. The
+// tags it added are kept.
+func RemoveRule(repoID, query, tag string) error {
+	rules, err := Rules(repoID)
+	if err != nil {
+		return err
+	}
+
+	// added again, the rule applies to every matching file
+	err = withStore(DBPath(repoID), func(s *store) error {
+		return s.forgetRule(Rule{Query: query, Tag: tag})
+	})
+	if err != nil {
+		return err
+	}
+
+	kept := []Rule{}
+	for _, r := range rules {
+		if r.Query != query || r.Tag != tag {
+			kept = append(kept, r)
+		}
+	}
+
+	return SaveRules(repoID, kept)
+}
+
+// ApplyRules tags the files of the repository matching each rule that the
+// rule wasn't applied to before, returning the number of files tagged
+func ApplyRules(repoID string, idx rindex.Indexer) (int, error) {
+	rules, err := Rules(repoID)
+	if err != nil || len(rules) == 0 {
+		return 0, err
+	}
+
+	tagged := 0
+	err = withStore(DBPath(repoID), func(s *store) error {
+		for i, r := range rules {
+			start := time.Now()
+			q, err := queryparser.ParseQuery(r.Query)
+			if err != nil {
+				return err
+			}
+
+			docs, err := index.Collect(idx, q)
+			if err != nil {
+				return err
+			}
+
+			ids, err := s.applyRule(r, docs)
+			if err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				if err := s.remember(docs); err != nil {
+					return err
+				}
+			}
+
+			tagged += len(ids)
+			rules[i].LastApplied = start
+		}
+		return nil
+	})
+	if err != nil {
+		return tagged, err
+	}
+
+	return tagged, SaveRules(repoID, rules)
+}
+
+// applyRule tags the documents the rule wasn't applied to before, recording
+// them, and returns their IDs
+func (s *store) applyRule(r Rule, docs []index.Document) ([]string, error) {
+	applied, err := s.ruleFiles(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// rules applied before their files were recorded only tag files
+	// indexed afterwards. Indices rebuilt since then have every file
+	// updated, so they're recorded without tagging them.
+	_, recorded := applied[""]
+	legacy := !recorded && !r.LastApplied.IsZero()
+
+	ids := []string{}
+	batch := new(leveldb.Batch)
+	// marks the rule as recorded, even if it matches no files
+	batch.Put(ruleKey(r, ""), nil)
+	for _, d := range docs {
+		if applied[d.ID] {
+			continue
+		}
+		batch.Put(ruleKey(r, d.ID), nil)
+		if !legacy {
+			ids = append(ids, d.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		if err := s.add(ids, Tag{Name: r.Tag}); err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, s.db.Write(batch, nil)
+}
+
+// ruleFiles returns the IDs of the files the rule was applied to, and an
+// empty ID if the rule is recorded
+func (s *store) ruleFiles(r Rule) (map[string]bool, error) {
+	ids := map[string]bool{}
+	prefix := rulePrefix(r)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		ids[string(iter.Key()[len(prefix):])] = true
+	}
+
+	return ids, iter.Error()
+}
+
+// forgetRule removes the record of the files the rule was applied to
+func (s *store) forgetRule(r Rule) error {
+	batch := new(leveldb.Batch)
+	iter := s.db.NewIterator(util.BytesPrefix(rulePrefix(r)), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return s.db.Write(batch, nil)
+}
@@ -0,0 +1,70 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/swampapp/swamp/internal/index"
+)
+
+func TestApplyRule(t *testing.T) {
+	s := testDB(t, map[string][]Tag{})
+	r := Rule{Query: "ext:pdf", Tag: "docs"}
+
+	docs := []index.Document{{ID: "a"}, {ID: "b"}}
+	if ids, err := s.applyRule(r, docs); err != nil || !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Fatalf("unexpected files tagged %v, %v", ids, err)
+	}
+
+	// untagged files aren't tagged again, even if indexed again
+	if err := s.remove([]string{"a"}, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	docs = []index.Document{{ID: "a", Updated: now}, {ID: "b", Updated: now}, {ID: "c", Updated: now}}
+	if ids, err := s.applyRule(r, docs); err != nil || !reflect.DeepEqual(ids, []string{"c"}) {
+		t.Fatalf("unexpected files tagged %v, %v", ids, err)
+	}
+	expected := map[string][]Tag{
+		"b": {{Name: "docs"}},
+		"c": {{Name: "docs"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+
+	// removed rules apply to every file when added again
+	if err := s.forgetRule(r); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := s.applyRule(r, docs); err != nil || len(ids) != 3 {
+		t.Errorf("unexpected files tagged %v, %v", ids, err)
+	}
+}
+
+func TestApplyLegacyRule(t *testing.T) {
+	s := testDB(t, map[string][]Tag{})
+
+	// applied before the files were recorded
+	r := Rule{Query: "ext:pdf", Tag: "docs", LastApplied: time.Now().Add(-time.Hour)}
+	docs := []index.Document{{ID: "a", Updated: time.Now()}}
+	if ids, err := s.applyRule(r, docs); err != nil || len(ids) != 0 {
+		t.Fatalf("legacy rule tagged files %v, %v", ids, err)
+	}
+
+	docs = append(docs, index.Document{ID: "b"})
+	if ids, err := s.applyRule(r, docs); err != nil || !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("unexpected files tagged %v, %v", ids, err)
+	}
+
+	// rules matching no files are recorded too
+	r = Rule{Query: "ext:odt", Tag: "docs"}
+	if ids, err := s.applyRule(r, nil); err != nil || len(ids) != 0 {
+		t.Fatalf("unexpected files tagged %v, %v", ids, err)
+	}
+	r.LastApplied = time.Now()
+	if ids, err := s.applyRule(r, []index.Document{{ID: "c"}}); err != nil || !reflect.DeepEqual(ids, []string{"c"}) {
+		t.Errorf("unexpected files tagged %v, %v", ids, err)
+	}
+}
@@ -0,0 +1,588 @@
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Keys stored in the database:
+//
+//	f/<file ID>            msgpack encoded []Tag of the file
+//	t/<tag name>\x00<file> empty, indexes the files tagged with a tag
+//	c/<tag name>           msgpack encoded tagInfo
+//	i/<file ID>            msgpack encoded identity of a tagged file
+//	r/<tag>\x00<query>\x00<file>
+//	                       empty, the tag rule was applied to the file, or
+//	                       is recorded if the file ID is empty
+//	meta/version           version of the layout
+//
+// Databases created before the layout was versioned store the tags of each
+// file under the bare file ID, they're migrated when opened.
+var (
+	fileKeyPrefix = []byte("f/")
+	tagKeyPrefix  = []byte("t/")
+	infoKeyPrefix = []byte("c/")
+	idKeyPrefix   = []byte("i/")
+	ruleKeyPrefix = []byte("r/")
+	versionKey    = []byte("meta/version")
+)
+
+const layoutVersion = "2"
+
+// tagInfo is kept up to date on every write, so listing tags doesn't need
+// to visit every file
+type tagInfo struct {
+	Color string
+	Files int
+}
+
+// identity is what identifies a tagged file besides its ID, recorded so its
+// tags can be moved to the new ID if the file is indexed again
+type identity struct {
+	BHash string
+	Path  string
+}
+
+type store struct {
+	db   *leveldb.DB
+	path string
+	// serializes read-modify-write updates
+	mutex sync.Mutex
+}
+
+func openStore(path string) (*store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{db: db, path: path}
+	if err := s.migrate(); err != nil {
+		if err := db.Close(); err != nil {
+			logger.Error(err, "error closing tags database")
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+func fileKey(id string) []byte {
+	return append(append([]byte{}, fileKeyPrefix...), id...)
+}
+
+func tagPrefix(name string) []byte {
+	k := append(append([]byte{}, tagKeyPrefix...), name...)
+	return append(k, 0)
+}
+
+func tagKey(name, id string) []byte {
+	return append(tagPrefix(name), id...)
+}
+
+func infoKey(name string) []byte {
+	return append(append([]byte{}, infoKeyPrefix...), name...)
+}
+
+func idKey(id string) []byte {
+	return append(append([]byte{}, idKeyPrefix...), id...)
+}
+
+func rulePrefix(r Rule) []byte {
+	k := append(append([]byte{}, ruleKeyPrefix...), r.Tag...)
+	k = append(append(k, 0), r.Query...)
+	return append(k, 0)
+}
+
+func ruleKey(r Rule, id string) []byte {
+	return append(rulePrefix(r), id...)
+}
+
+// migrate moves the tags stored under bare file IDs to the current layout,
+// building the tag index and counts
+func (s *store) migrate() error {
+	v, err := s.db.Get(versionKey, nil)
+	if err == nil && string(v) == layoutVersion {
+		return nil
+	}
+	if err != nil && err != leveldb.ErrNotFound {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	w := newWriter(s)
+	iter := s.db.NewIterator(nil, nil)
+	for iter.Next() {
+		key := iter.Key()
+		if bytes.HasPrefix(key, fileKeyPrefix) || bytes.HasPrefix(key, tagKeyPrefix) ||
+			bytes.HasPrefix(key, infoKeyPrefix) || bytes.HasPrefix(key, idKeyPrefix) ||
+			bytes.HasPrefix(key, ruleKeyPrefix) || bytes.Equal(key, versionKey) {
+			continue
+		}
+
+		var tags []Tag
+		if err := msgpack.Unmarshal(iter.Value(), &tags); err != nil {
+			iter.Release()
+			return err
+		}
+		w.batch.Delete(append([]byte{}, key...))
+		if err := w.set(string(key), nil, tags); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	w.batch.Put(versionKey, []byte(layoutVersion))
+
+	return w.commit()
+}
+
+func (s *store) tags(fileID string) ([]Tag, error) {
+	tagsblob, err := s.db.Get(fileKey(fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	err = msgpack.Unmarshal(tagsblob, &tags)
+
+	return tags, err
+}
+
+// files visits every tagged file, stopping when fn returns false
+func (s *store) files(fn func(string, []Tag) bool) error {
+	iter := s.db.NewIterator(util.BytesPrefix(fileKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var tags []Tag
+		if err := msgpack.Unmarshal(iter.Value(), &tags); err != nil {
+			return err
+		}
+		if !fn(string(iter.Key()[len(fileKeyPrefix):]), tags) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func (s *store) identity(fileID string) (identity, bool, error) {
+	var id identity
+	b, err := s.db.Get(idKey(fileID), nil)
+	if err == leveldb.ErrNotFound {
+		return id, false, nil
+	}
+	if err != nil {
+		return id, false, err
+	}
+
+	return id, true, msgpack.Unmarshal(b, &id)
+}
+
+// remember records the identity of the tagged documents
+func (s *store) remember(docs []index.Document) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	batch := new(leveldb.Batch)
+	for _, d := range docs {
+		if _, err := s.db.Get(fileKey(d.ID), nil); err == leveldb.ErrNotFound {
+			continue
+		}
+		b, err := msgpack.Marshal(identity{BHash: d.BHash, Path: d.Path})
+		if err != nil {
+			return err
+		}
+		batch.Put(idKey(d.ID), b)
+	}
+
+	return s.db.Write(batch, nil)
+}
+
+// move moves the tags of every file to a new file ID, keeping the tags the
+// new file already has
+func (s *store) move(moves map[string]string) error {
+	moved := map[string][]Tag{}
+	ids := []string{}
+	for from, to := range moves {
+		tags, err := s.tags(from)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		moved[to] = append(moved[to], tags...)
+		ids = append(ids, from, to)
+	}
+
+	return s.updateEach(ids, func(id string, tags []Tag) []Tag {
+		if _, ok := moves[id]; ok {
+			return nil
+		}
+		for _, t := range moved[id] {
+			if !hasTag(tags, t.Name) {
+				tags = append(tags, t)
+			}
+		}
+		return tags
+	})
+}
+
+func (s *store) info(name string) (tagInfo, bool, error) {
+	var info tagInfo
+	b, err := s.db.Get(infoKey(name), nil)
+	if err == leveldb.ErrNotFound {
+		return info, false, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+
+	return info, true, msgpack.Unmarshal(b, &info)
+}
+
+func (s *store) counts() ([]TagCount, error) {
+	counts := []TagCount{}
+	iter := s.db.NewIterator(util.BytesPrefix(infoKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var info tagInfo
+		if err := msgpack.Unmarshal(iter.Value(), &info); err != nil {
+			return nil, err
+		}
+		name := string(iter.Key()[len(infoKeyPrefix):])
+		counts = append(counts, TagCount{Tag: Tag{Name: name, Color: info.Color}, Files: info.Files})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Name < counts[j].Name
+	})
+
+	return counts, iter.Error()
+}
+
+// fileIDs returns the files tagged with name. Subtree queries like
+// project/* return the files tagged with project or any tag below it.
+func (s *store) fileIDs(name string) ([]string, error) {
+	root, ok := Subtree(name)
+	if !ok {
+		return s.tagFiles(name)
+	}
+
+	ids, err := s.tagFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	// t/<root>/<tag below>\x00<file ID>
+	prefix := append(append([]byte{}, tagKeyPrefix...), root+Separator...)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		id := string(key[bytes.IndexByte(key, 0)+1:])
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, iter.Error()
+}
+
+// tagFiles returns the files tagged with name
+func (s *store) tagFiles(name string) ([]string, error) {
+	ids := []string{}
+	prefix := tagPrefix(name)
+	iter := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		ids = append(ids, string(iter.Key()[len(prefix):]))
+	}
+
+	return ids, iter.Error()
+}
+
+func (s *store) add(fileIDs []string, tag Tag) error {
+	tag.Name = Normalize(tag.Name)
+	if tag.Name == "" {
+		return ErrInvalidName
+	}
+	// rules and bulk tagging only give the name, the tag keeps its color
+	if tag.Color == "" {
+		info, found, err := s.info(tag.Name)
+		if err != nil {
+			return err
+		}
+		if found {
+			tag.Color = info.Color
+		}
+	}
+
+	return s.update(fileIDs, func(tags []Tag) []Tag {
+		if hasTag(tags, tag.Name) {
+			return tags
+		}
+		return append(tags, tag)
+	})
+}
+
+func (s *store) remove(fileIDs []string, name string) error {
+	return s.update(fileIDs, func(tags []Tag) []Tag {
+		kept := []Tag{}
+		for _, t := range tags {
+			if t.Name != name {
+				kept = append(kept, t)
+			}
+		}
+		return kept
+	})
+}
+
+func (s *store) delete(name string) error {
+	ids, err := s.tagFiles(name)
+	if err != nil {
+		return err
+	}
+
+	return s.remove(ids, name)
+}
+
+// rename renames the tag and the tags below it, so renaming project to work
+// renames project/alpha to work/alpha
+func (s *store) rename(from, to string) error {
+	to = Normalize(to)
+	if to == "" {
+		return ErrInvalidName
+	}
+	if from == to {
+		return nil
+	}
+	if InSubtree(to, from) {
+		return fmt.Errorf("can't move tag %s below itself", from)
+	}
+
+	counts, err := s.counts()
+	if err != nil {
+		return err
+	}
+	for _, c := range counts {
+		if c.Name == from || !InSubtree(c.Name, from) {
+			continue
+		}
+		if err := s.renameTag(c.Name, to+strings.TrimPrefix(c.Name, from)); err != nil {
+			return err
+		}
+	}
+
+	return s.renameTag(from, to)
+}
+
+func (s *store) renameTag(from, to string) error {
+
+	// files tagged with to already keep its color
+	info, found, err := s.info(to)
+	if err != nil {
+		return err
+	}
+
+	ids, err := s.tagFiles(from)
+	if err != nil {
+		return err
+	}
+
+	return s.update(ids, func(tags []Tag) []Tag {
+		renamed := []Tag{}
+		for _, t := range tags {
+			switch t.Name {
+			case to:
+				continue
+			case from:
+				t.Name = to
+				if found {
+					t.Color = info.Color
+				}
+			}
+			renamed = append(renamed, t)
+		}
+		return renamed
+	})
+}
+
+func (s *store) setColor(name, color string) error {
+	if color != "" && !validColor(color) {
+		return ErrInvalidColor
+	}
+
+	ids, err := s.tagFiles(name)
+	if err != nil {
+		return err
+	}
+
+	return s.update(ids, func(tags []Tag) []Tag {
+		for i := range tags {
+			if tags[i].Name == name {
+				tags[i].Color = color
+			}
+		}
+		return tags
+	})
+}
+
+// update replaces the tags of every file with the ones returned by fn, in a
+// single batch. Files left without tags are removed from the database.
+func (s *store) update(fileIDs []string, fn func([]Tag) []Tag) error {
+	return s.updateEach(fileIDs, func(_ string, tags []Tag) []Tag {
+		return fn(tags)
+	})
+}
+
+// updateEach is update with fn receiving the ID of each file
+func (s *store) updateEach(fileIDs []string, fn func(string, []Tag) []Tag) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	w := newWriter(s)
+	seen := map[string]bool{}
+	for _, id := range fileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		tags, err := s.tags(id)
+		if err != nil && err != leveldb.ErrNotFound {
+			return err
+		}
+
+		// fn may modify the slice it's given
+		old := append([]Tag{}, tags...)
+		if err := w.set(id, old, fn(id, tags)); err != nil {
+			return err
+		}
+	}
+
+	return w.commit()
+}
+
+// writer batches file updates along with the changes to the tag index and
+// counts they require
+type writer struct {
+	s      *store
+	batch  *leveldb.Batch
+	deltas map[string]int
+	colors map[string]string
+}
+
+func newWriter(s *store) *writer {
+	return &writer{
+		s:      s,
+		batch:  new(leveldb.Batch),
+		deltas: map[string]int{},
+		colors: map[string]string{},
+	}
+}
+
+// set replaces the tags of the file, old being the ones currently stored
+func (w *writer) set(id string, old, tags []Tag) error {
+	before := map[string]Tag{}
+	for _, t := range old {
+		before[t.Name] = t
+	}
+	after := map[string]bool{}
+	for _, t := range tags {
+		after[t.Name] = true
+		prev, ok := before[t.Name]
+		if !ok {
+			w.batch.Put(tagKey(t.Name, id), nil)
+			w.deltas[t.Name]++
+		}
+		if (!ok && t.Color != "") || (ok && prev.Color != t.Color) {
+			w.colors[t.Name] = t.Color
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			w.batch.Delete(tagKey(name, id))
+			w.deltas[name]--
+		}
+	}
+
+	if len(tags) == 0 {
+		w.batch.Delete(fileKey(id))
+		w.batch.Delete(idKey(id))
+		return nil
+	}
+
+	mtags, err := msgpack.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	w.batch.Put(fileKey(id), mtags)
+
+	return nil
+}
+
+// commit updates the counts and colors of the tags changed and writes the
+// batch
+func (w *writer) commit() error {
+	names := map[string]bool{}
+	for name := range w.deltas {
+		names[name] = true
+	}
+	for name := range w.colors {
+		names[name] = true
+	}
+
+	for name := range names {
+		info, _, err := w.s.info(name)
+		if err != nil {
+			return err
+		}
+		info.Files += w.deltas[name]
+		if c, ok := w.colors[name]; ok {
+			info.Color = c
+		}
+
+		if info.Files <= 0 {
+			w.batch.Delete(infoKey(name))
+			continue
+		}
+		b, err := msgpack.Marshal(info)
+		if err != nil {
+			return err
+		}
+		w.batch.Put(infoKey(name), b)
+	}
+
+	return w.s.db.Write(w.batch, nil)
+}
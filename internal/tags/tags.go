@@ -1,119 +1,138 @@
 package tags
 
 import (
+	"errors"
 	"path/filepath"
+	"sync"
 
 	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/index"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/paths"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/vmihailenco/msgpack/v5"
 )
 
+// ErrInvalidName is returned when adding or renaming to an empty tag name
+var ErrInvalidName = errors.New("invalid tag name")
+
+// ErrLocked is returned when the tags database is held open by another
+// process, the app keeps it open while running
+var ErrLocked = errors.New("the tags database is in use, close Swamp first")
+
 type Tag struct {
 	Name  string
 	Color string
 }
 
+// TagCount is a tag and the number of files tagged with it
+type TagCount struct {
+	Tag
+	Files int
+}
+
 func dbPath() string {
 	pr := config.Get().PreferredRepo()
 	if pr == "" {
 		panic("preferred repo not set")
 	}
 
-	return filepath.Join(paths.RepositoriesDir(), pr, "tags.db")
+	return DBPath(pr)
+}
+
+// DBPath returns the path to the tags database of the repository
+func DBPath(repoID string) string {
+	return filepath.Join(paths.RepositoriesDir(), repoID, "tags.db")
 }
 
 func For(fileID string) ([]Tag, error) {
-	db, err := leveldb.OpenFile(dbPath(), nil)
+	s, err := open()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.Error(err, "")
-		}
-	}()
 
-	tagsblob, err := db.Get([]byte(fileID), nil)
+	return s.tags(fileID)
+}
+
+// Delete removes the tag from every file
+func Delete(tag string) error {
+	s, err := open()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var tags []Tag
-	err = msgpack.Unmarshal(tagsblob, &tags)
+	return s.delete(tag)
+}
+
+// Rename renames the tag in every file, along with the tags below it.
+// Renaming to an existing tag merges both.
+func Rename(from, to string) error {
+	s, err := open()
+	if err != nil {
+		return err
+	}
 
-	return tags, err
+	return s.rename(from, to)
 }
 
-func Delete(tag string) {
+// Merge replaces the tag from with into in every file, keeping the color
+// of into
+func Merge(from, into string) error {
+	return Rename(from, into)
 }
 
-func All() ([]Tag, error) {
-	var tags []Tag
-	tmap := map[string]Tag{}
-	db, err := leveldb.OpenFile(dbPath(), nil)
+// SetColor changes the color of the tag in every file, to #rrggbb or a
+// color name. An empty color resets it to the default one.
+func SetColor(tag, color string) error {
+	s, err := open()
 	if err != nil {
-		return tags, err
+		return err
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.Error(err, "")
-		}
-	}()
 
-	iter := db.NewIterator(nil, nil)
-	for iter.Next() {
-		var tags []Tag
-		err := msgpack.Unmarshal(iter.Value(), &tags)
-		if err != nil {
-			return tags, err
-		}
-		for _, t := range tags {
-			tmap[t.Name] = t
-		}
+	return s.setColor(tag, color)
+}
+
+func All() ([]Tag, error) {
+	counts, err := Counts()
+	if err != nil {
+		return nil, err
 	}
-	iter.Release()
 
-	for _, v := range tmap {
-		tags = append(tags, v)
+	tags := make([]Tag, 0, len(counts))
+	for _, c := range counts {
+		tags = append(tags, c.Tag)
 	}
 
 	return tags, nil
 }
 
+// Counts returns every tag with the number of files tagged with it, sorted
+// by name
+func Counts() ([]TagCount, error) {
+	s, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.counts()
+}
+
+// GetDocuments returns the indexed documents tagged with tag
 func GetDocuments(tag string) ([]index.Document, error) {
-	var documents []index.Document
-	db, err := leveldb.OpenFile(dbPath(), nil)
+	ids, err := FileIDs(tag)
 	if err != nil {
-		return documents, err
+		return nil, err
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.Error(err, "")
-		}
-	}()
 
-	iter := db.NewIterator(nil, nil)
-	for iter.Next() {
-		var tags []Tag
-		fileID := string(iter.Key())
-		err := msgpack.Unmarshal(iter.Value(), &tags)
-		if err != nil {
-			return documents, err
-		}
-		if hasTag(tags, tag) {
-			doc, err := index.GetDocument(fileID)
-			if err != nil {
-				return documents, err
-			}
-			documents = append(documents, doc)
-		}
+	return index.GetDocuments(ids)
+}
+
+// FileIDs returns the IDs of the files tagged with tag
+func FileIDs(tag string) ([]string, error) {
+	s, err := open()
+	if err != nil {
+		return nil, err
 	}
-	iter.Release()
 
-	return documents, nil
+	return s.fileIDs(tag)
 }
 
 func hasTag(tags []Tag, name string) bool {
@@ -127,19 +146,125 @@ func hasTag(tags []Tag, name string) bool {
 }
 
 func Save(fileID string, tags []Tag) error {
-	db, err := leveldb.OpenFile(dbPath(), nil)
+	s, err := open()
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			logger.Error(err, "")
+
+	return s.update([]string{fileID}, func([]Tag) []Tag {
+		return tags
+	})
+}
+
+// Add adds the tag to every file given, keeping the tags they already have
+func Add(fileIDs []string, tag Tag) error {
+	s, err := open()
+	if err != nil {
+		return err
+	}
+
+	if err := s.add(fileIDs, tag); err != nil {
+		return err
+	}
+
+	// so the tags can be relinked if the file IDs change
+	docs, err := index.GetDocuments(fileIDs)
+	if err != nil {
+		logger.Error(err, "error looking up the tagged files")
+		return nil
+	}
+
+	return s.remember(docs)
+}
+
+// Remove removes the tag from every file given
+func Remove(fileIDs []string, name string) error {
+	s, err := open()
+	if err != nil {
+		return err
+	}
+
+	return s.remove(fileIDs, name)
+}
+
+// Close closes the open tags databases
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var err error
+	for path, s := range stores {
+		if cerr := s.close(); cerr != nil {
+			err = cerr
 		}
-	}()
+		delete(stores, path)
+	}
+
+	return err
+}
+
+var mutex sync.Mutex
+var stores = map[string]*store{}
+
+// open returns the database of the preferred repository, closing the ones
+// of other repositories so they aren't locked while not in use
+func open() (*store, error) {
+	path := dbPath()
+
+	mutex.Lock()
+	for p, s := range stores {
+		if p == path {
+			continue
+		}
+		if err := s.close(); err != nil {
+			logger.Error(err, "error closing tags database")
+		}
+		delete(stores, p)
+	}
+	mutex.Unlock()
+
+	return openPath(path)
+}
+
+// openPath returns the database found in path, opening it the first time
+func openPath(path string) (*store, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if s, ok := stores[path]; ok {
+		return s, nil
+	}
 
-	mtags, err := msgpack.Marshal(tags)
+	s, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	stores[path] = s
+
+	return s, nil
+}
+
+// withStore calls fn with the database found in path, using the open handle
+// if there's one. Otherwise the database is only kept open while fn runs,
+// fn must not open other databases.
+func withStore(path string, fn func(*store) error) error {
+	mutex.Lock()
+	if s, ok := stores[path]; ok {
+		mutex.Unlock()
+		return fn(s)
+	}
+	// keep the database from being opened twice meanwhile
+	defer mutex.Unlock()
+
+	s, err := openStore(path)
 	if err != nil {
 		return err
 	}
-	return db.Put([]byte(fileID), mtags, nil)
+	defer func() {
+		if err := s.close(); err != nil {
+			logger.Error(err, "error closing tags database")
+		}
+	}()
+
+	return fn(s)
 }
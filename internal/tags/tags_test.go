@@ -0,0 +1,291 @@
+package tags
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// testDB returns a store with the files given, written with the legacy
+// layout so they're migrated when opened
+func testDB(t *testing.T, files map[string][]Tag) *store {
+	dir, err := ioutil.TempDir("", "swamp-tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "tags.db")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, tags := range files {
+		b, err := msgpack.Marshal(tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Put([]byte(id), b, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.close() })
+
+	return s
+}
+
+func readDB(t *testing.T, s *store) map[string][]Tag {
+	files := map[string][]Tag{}
+	iter := s.db.NewIterator(util.BytesPrefix(fileKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var tags []Tag
+		if err := msgpack.Unmarshal(iter.Value(), &tags); err != nil {
+			t.Fatal(err)
+		}
+		files[string(iter.Key()[len(fileKeyPrefix):])] = tags
+	}
+
+	return files
+}
+
+func counts(t *testing.T, s *store) map[string]TagCount {
+	all, err := s.counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]TagCount{}
+	for _, c := range all {
+		counts[c.Name] = c
+	}
+
+	return counts
+}
+
+func TestRename(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "old", Color: "red"}, {Name: "other"}},
+		"b": {{Name: "old", Color: "red"}, {Name: "new", Color: "blue"}},
+		"c": {{Name: "new", Color: "blue"}},
+	})
+
+	if err := s.rename("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]Tag{
+		// the existing tag's color is kept
+		"a": {{Name: "new", Color: "blue"}, {Name: "other"}},
+		"b": {{Name: "new", Color: "blue"}},
+		"c": {{Name: "new", Color: "blue"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+}
+
+func TestDeleteAndSetColor(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "gone"}},
+		"b": {{Name: "gone"}, {Name: "kept"}},
+	})
+
+	if err := s.delete("gone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setColor("kept", "#ff0000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setColor("kept", "#ff00"); err != ErrInvalidColor {
+		t.Errorf("invalid color accepted: %v", err)
+	}
+
+	// files without tags are removed
+	expected := map[string][]Tag{
+		"b": {{Name: "kept", Color: "#ff0000"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "x"}},
+	})
+
+	if err := s.add([]string{"a", "b"}, Tag{Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]Tag{
+		"a": {{Name: "x"}},
+		"b": {{Name: "x"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+
+	if err := s.remove([]string{"a", "c"}, "x"); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string][]Tag{
+		"b": {{Name: "x"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+}
+
+func TestAddKeepsColor(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "x", Color: "red"}},
+	})
+
+	if err := s.add([]string{"b"}, Tag{Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add([]string{"c"}, Tag{Name: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]Tag{
+		"a": {{Name: "x", Color: "red"}},
+		"b": {{Name: "x", Color: "red"}},
+		"c": {{Name: "new"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "x", Color: "red"}, {Name: "y"}},
+		"b": {{Name: "x", Color: "red"}},
+	})
+
+	expected := map[string]TagCount{
+		"x": {Tag: Tag{Name: "x", Color: "red"}, Files: 2},
+		"y": {Tag: Tag{Name: "y"}, Files: 1},
+	}
+	if c := counts(t, s); !reflect.DeepEqual(c, expected) {
+		t.Errorf("unexpected counts %v", c)
+	}
+
+	ids, err := s.fileIDs("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("unexpected files %v", ids)
+	}
+
+	if err := s.remove([]string{"a"}, "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.add([]string{"c", "c"}, Tag{Name: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setColor("x", "blue"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]TagCount{
+		"x": {Tag: Tag{Name: "x", Color: "blue"}, Files: 3},
+	}
+	if c := counts(t, s); !reflect.DeepEqual(c, expected) {
+		t.Errorf("unexpected counts %v", c)
+	}
+	if ids, _ := s.fileIDs("y"); len(ids) != 0 {
+		t.Errorf("y still indexed for %v", ids)
+	}
+}
+
+func TestRememberAndMove(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"old":  {{Name: "x"}},
+		"new":  {{Name: "y"}},
+		"kept": {{Name: "x"}},
+	})
+
+	docs := []index.Document{
+		{ID: "old", BHash: "h", Path: "/a"},
+		// untagged files aren't remembered
+		{ID: "untagged", BHash: "h2", Path: "/b"},
+	}
+	if err := s.remember(docs); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok, _ := s.identity("old"); !ok || id.BHash != "h" || id.Path != "/a" {
+		t.Errorf("unexpected identity %v", id)
+	}
+	if _, ok, _ := s.identity("untagged"); ok {
+		t.Error("untagged file remembered")
+	}
+
+	if err := s.move(map[string]string{"old": "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]Tag{
+		"new":  {{Name: "y"}, {Name: "x"}},
+		"kept": {{Name: "x"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+	if _, ok, _ := s.identity("old"); ok {
+		t.Error("identity of the moved file kept")
+	}
+	if c := counts(t, s); c["x"].Files != 2 {
+		t.Errorf("unexpected counts %v", c)
+	}
+}
+
+func TestSubtree(t *testing.T) {
+	s := testDB(t, map[string][]Tag{
+		"a": {{Name: "project"}},
+		"b": {{Name: "project/alpha"}, {Name: "project/alpha/specs"}},
+		"c": {{Name: "projects"}},
+	})
+
+	ids, err := s.fileIDs("project/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("unexpected files %v", ids)
+	}
+
+	if err := s.rename("project", "work"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]Tag{
+		"a": {{Name: "work"}},
+		"b": {{Name: "work/alpha"}, {Name: "work/alpha/specs"}},
+		"c": {{Name: "projects"}},
+	}
+	if files := readDB(t, s); !reflect.DeepEqual(files, expected) {
+		t.Errorf("unexpected tags %v", files)
+	}
+
+	if err := s.rename("work", "work/alpha"); err == nil {
+		t.Error("expected error moving a tag below itself")
+	}
+}
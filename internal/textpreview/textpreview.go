@@ -0,0 +1,162 @@
+// Package textpreview retrieves the beginning of indexed files to preview
+// them as text, without downloading the whole file.
+package textpreview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/swampapp/swamp/internal/index"
+)
+
+// DefaultMaxBytes is the amount of data previewed by default
+const DefaultMaxBytes = 64 * 1024
+
+// files with a larger proportion of control characters are considered
+// binary
+const maxControlRatio = 0.1
+
+// Preview is the decoded beginning of a file
+type Preview struct {
+	Text string
+	// Encoding detected, empty for binary files
+	Encoding string
+	// Binary is true when the content doesn't look like text
+	Binary bool
+	// Truncated is true when the file is larger than the preview
+	Truncated bool
+}
+
+var errLimit = errors.New("preview limit reached")
+
+// limitWriter buffers up to max bytes, failing writes after that so the
+// fetch is aborted
+type limitWriter struct {
+	buf  bytes.Buffer
+	max  int
+	full bool
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	remaining := w.max - w.buf.Len()
+	if len(p) <= remaining {
+		return w.buf.Write(p)
+	}
+
+	w.full = true
+	n, _ := w.buf.Write(p[:remaining])
+	return n, errLimit
+}
+
+// Fetch retrieves up to max bytes of the file from the repository and
+// decodes them
+func Fetch(ctx context.Context, fileID string, max int) (*Preview, error) {
+	idx, err := index.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &limitWriter{max: max}
+	err = idx.Fetch(ctx, fileID, w)
+	if err != nil && !w.full {
+		return nil, err
+	}
+
+	return Decode(w.buf.Bytes(), w.full), nil
+}
+
+// Decode detects the encoding of data and decodes it.
+//
+// UTF-16 is only detected when the data starts with a byte order mark.
+// Data that isn't valid UTF-8 and doesn't look binary is decoded as
+// ISO-8859-1. truncated indicates data may end in the middle of a
+// character.
+func Decode(data []byte, truncated bool) *Preview {
+	p := &Preview{Truncated: truncated}
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		p.Encoding = "UTF-16LE"
+		p.Text = decodeUTF16(data[2:], false)
+		return p
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		p.Encoding = "UTF-16BE"
+		p.Text = decodeUTF16(data[2:], true)
+		return p
+	}
+
+	if truncated {
+		data = trimIncompleteRune(data)
+	}
+
+	if isBinary(data) {
+		p.Binary = true
+		return p
+	}
+
+	if utf8.Valid(data) {
+		p.Encoding = "UTF-8"
+		p.Text = string(data)
+		return p
+	}
+
+	p.Encoding = "ISO-8859-1"
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	p.Text = string(runes)
+
+	return p
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			u[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+
+	return string(utf16.Decode(u))
+}
+
+// trimIncompleteRune removes a partial UTF-8 sequence at the end of data
+func trimIncompleteRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+
+	return data
+}
+
+func isBinary(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	control := 0
+	for _, b := range data {
+		switch {
+		case b == 0:
+			return true
+		case b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0x1b:
+		case b < 0x20 || b == 0x7f:
+			control++
+		}
+	}
+
+	return float64(control)/float64(len(data)) > maxControlRatio
+}
@@ -0,0 +1,57 @@
+package textpreview
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		data      []byte
+		truncated bool
+		text      string
+		encoding  string
+		binary    bool
+	}{
+		{[]byte("hello\nworld\n"), false, "hello\nworld\n", "UTF-8", false},
+		{[]byte("\xef\xbb\xbfbom"), false, "bom", "UTF-8", false},
+		{[]byte("caf\xc3\xa9"), false, "café", "UTF-8", false},
+		// truncated in the middle of é
+		{[]byte("caf\xc3"), true, "caf", "UTF-8", false},
+		{[]byte("caf\xe9"), false, "café", "ISO-8859-1", false},
+		{[]byte("\xff\xfeh\x00i\x00"), false, "hi", "UTF-16LE", false},
+		{[]byte("\xfe\xff\x00h\x00i"), false, "hi", "UTF-16BE", false},
+		{[]byte("ELF\x00\x01\x02"), false, "", "", true},
+		{[]byte("\x01\x02\x03\x04abcd"), false, "", "", true},
+		{[]byte{}, false, "", "UTF-8", false},
+	}
+
+	for _, test := range tests {
+		p := Decode(test.data, test.truncated)
+		if p.Text != test.text || p.Encoding != test.encoding || p.Binary != test.binary {
+			t.Errorf("%q: unexpected preview %+v", test.data, p)
+		}
+		if p.Truncated != test.truncated {
+			t.Errorf("%q: expected truncated to be %v", test.data, test.truncated)
+		}
+	}
+}
+
+func TestLimitWriter(t *testing.T) {
+	w := &limitWriter{max: 5}
+
+	if n, err := w.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("unexpected write result %d, %v", n, err)
+	}
+	if w.full {
+		t.Error("the writer isn't full yet")
+	}
+
+	n, err := w.Write([]byte("defgh"))
+	if n != 2 || err != errLimit {
+		t.Errorf("unexpected write result %d, %v", n, err)
+	}
+	if !w.full || !bytes.Equal(w.buf.Bytes(), []byte("abcde")) {
+		t.Errorf("unexpected buffer %q", w.buf.Bytes())
+	}
+}
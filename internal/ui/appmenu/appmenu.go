@@ -114,7 +114,7 @@ func (a *AppMenu) setup() {
 	selection.Connect("changed", a.selectionChanged)
 
 	scaleFactor := a.treeView.GetScaleFactor()
-	var imageTags, imageSearch, imageSettings, imageStatus, imageDownloaded, imageInProgress *gdk.Pixbuf
+	var imageTags, imageSearch, imageSettings, imageStatus, imageDownloaded, imageInProgress, imageDupes *gdk.Pixbuf
 
 	// HiDPI hack while the required cairo stuff is missing in gotk3
 	// See https://gitlab.gnome.org/GNOME/gtk/-/issues/613
@@ -125,6 +125,7 @@ func (a *AppMenu) setup() {
 		imageSearch = resources.ScaledPixbuf(48, 48, "ui/appmenu/search.svg")
 		imageDownloaded = resources.ScaledPixbuf(48, 48, "ui/appmenu/downloads.svg")
 		imageInProgress = resources.ScaledPixbuf(48, 48, "ui/appmenu/in-progress.svg")
+		imageDupes = resources.ScaledPixbuf(48, 48, "action-dupes")
 	} else {
 		imageTags = resources.ScaledPixbuf(42, 42, "ui/appmenu/tags.svg")
 		imageSettings = resources.ScaledPixbuf(42, 42, "ui/appmenu/settings.svg")
@@ -132,6 +133,7 @@ func (a *AppMenu) setup() {
 		imageSearch = resources.ScaledPixbuf(42, 42, "ui/appmenu/search.svg")
 		imageDownloaded = resources.ScaledPixbuf(42, 42, "ui/appmenu/downloads.svg")
 		imageInProgress = resources.ScaledPixbuf(42, 42, "ui/appmenu/in-progress.svg")
+		imageDupes = resources.ScaledPixbuf(42, 42, "action-dupes")
 	}
 
 	// Add some rows to the list store
@@ -140,6 +142,7 @@ func (a *AppMenu) setup() {
 	a.addRowWithImage(imageDownloaded, "Downloaded")
 	a.addRowWithImage(imageInProgress, "In Progress")
 	a.addRowWithImage(imageStatus, "Indexer")
+	a.addRowWithImage(imageDupes, "Duplicates")
 	a.addRowWithImage(imageSettings, "Settings")
 }
 
@@ -157,7 +157,7 @@ func (d *DownloadList) updateFileList(query string) {
 	for _, doc := range docs {
 		match, _ := filepath.Match(fmt.Sprintf("*%s*", strings.ToLower(query)), strings.ToLower(doc.Name))
 		if query == "*" || match {
-			d.treeView.AddRow(resources.ImageForDoc(doc.Name), doc.Name, doc.Path, doc.Size, doc.ID, doc.BHash)
+			d.treeView.AddRow(resources.ImageForFile(doc.Name, doc.MIME), doc.Name, doc.Path, doc.Size, doc.ID, doc.BHash)
 		}
 	}
 }
@@ -173,15 +173,17 @@ func (f *DownloadList) showInfo() {
 }
 
 func (d *DownloadList) tagSelected() {
-	var fid string
 	files := d.treeView.SelectedFiles()
+	if len(files) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(files))
 	for _, file := range files {
-		fid = file.ID
-		break
+		ids = append(ids, file.ID)
 	}
 
 	tw, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-	tw.Add(tagger.New(fid))
+	tw.Add(tagger.New(ids))
 	tw.Connect("key-press-event", func(w *gtk.Window, ev *gdk.Event) bool {
 		kp := gdk.EventKeyNewFromEvent(ev)
 		switch kp.KeyVal() {
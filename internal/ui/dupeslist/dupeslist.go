@@ -0,0 +1,314 @@
+package dupeslist
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/dupes"
+	"github.com/swampapp/swamp/internal/eventbus"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/queryparser"
+	"github.com/swampapp/swamp/internal/status"
+	"github.com/swampapp/swamp/internal/ui/component"
+	"github.com/swampapp/swamp/internal/ui/util"
+)
+
+const SearchRequestedEvent = "dupeslist.search_requested"
+
+type ColID int
+
+const (
+	COLUMN_NAME ColID = iota
+	COLUMN_SIZE
+	COLUMN_DETAILS
+	COLUMN_EXTRA
+	COLUMN_QUERY
+	COLUMN_USIZE
+)
+
+const (
+	modeFiles     = "files"
+	modeDirs      = "dirs"
+	modeCrossRepo = "cross-repo"
+)
+
+type exporter interface {
+	WriteCSV(io.Writer) error
+	WriteJSON(io.Writer) error
+}
+
+type DupesList struct {
+	*component.Component
+	*gtk.Box
+	treeView   *gtk.TreeView
+	treeStore  *gtk.TreeStore
+	columns    []*gtk.TreeViewColumn
+	summaryLbl *gtk.Label
+	modeCB     *gtk.ComboBoxText
+	minSizeEnt *gtk.Entry
+	scanBtn    *gtk.Button
+	exportBtn  *gtk.Button
+	report     exporter
+	lastDir    string
+}
+
+func New() *DupesList {
+	d := &DupesList{Component: component.New("/ui/dupeslist")}
+	d.Box = d.GladeWidget("container").(*gtk.Box)
+	d.summaryLbl = d.GladeWidget("summaryLBL").(*gtk.Label)
+	d.modeCB = d.GladeWidget("modeCB").(*gtk.ComboBoxText)
+	d.minSizeEnt = d.GladeWidget("minSizeENT").(*gtk.Entry)
+	d.scanBtn = d.GladeWidget("scanBTN").(*gtk.Button)
+	d.exportBtn = d.GladeWidget("exportBTN").(*gtk.Button)
+	d.setup()
+
+	sw := d.GladeWidget("dupesSW").(*gtk.ScrolledWindow)
+	sw.Add(d.treeView)
+
+	d.scanBtn.Connect("clicked", func() {
+		d.scan()
+	})
+
+	d.minSizeEnt.Connect("activate", func() {
+		d.scan()
+	})
+
+	d.exportBtn.Connect("clicked", func() {
+		d.export()
+	})
+
+	d.modeCB.Connect("changed", func() {
+		mode := d.modeCB.GetActiveID()
+		d.minSizeEnt.SetSensitive(mode != modeCrossRepo)
+		d.treeStore.Clear()
+		d.report = nil
+		d.exportBtn.SetSensitive(false)
+	})
+
+	eventbus.RegisterEvents(SearchRequestedEvent)
+
+	return d
+}
+
+func (d *DupesList) setup() {
+	d.treeView, _ = gtk.TreeViewNew()
+	d.treeView.SetEnableSearch(false)
+	d.treeView.Set("activate-on-single-click", false)
+
+	d.columns = []*gtk.TreeViewColumn{
+		util.CreateColumn("Name", int(COLUMN_NAME), 60),
+		util.CreateColumn("Size", int(COLUMN_SIZE), 10),
+		util.CreateColumn("Details", int(COLUMN_DETAILS), 10),
+		util.CreateColumn("", int(COLUMN_EXTRA), 30),
+	}
+	d.columns[COLUMN_SIZE].SetSortColumnID(int(COLUMN_USIZE))
+	for _, c := range d.columns {
+		d.treeView.AppendColumn(c)
+	}
+
+	d.treeStore, _ = gtk.TreeStoreNew(
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_INT64,
+	)
+	d.treeView.SetModel(d.treeStore)
+
+	d.treeView.Connect("row-activated", d.rowActivated)
+}
+
+func (d *DupesList) setTitles(titles ...string) {
+	for i, t := range titles {
+		d.columns[i].SetTitle(t)
+	}
+}
+
+func (d *DupesList) scan() {
+	mode := d.modeCB.GetActiveID()
+
+	minSize := uint64(0)
+	if txt, _ := d.minSizeEnt.GetText(); txt != "" {
+		var err error
+		minSize, err = humanize.ParseBytes(txt)
+		if err != nil {
+			d.summaryLbl.SetText(fmt.Sprintf("⚠️ invalid size '%s' specified", txt))
+			return
+		}
+	}
+
+	repo, found := preferredRepo()
+	if mode != modeCrossRepo && !found {
+		d.summaryLbl.SetText("⚠️ no preferred repository currently set")
+		return
+	}
+
+	d.scanBtn.SetSensitive(false)
+	d.exportBtn.SetSensitive(false)
+	d.summaryLbl.SetText("Scanning...")
+	d.treeStore.Clear()
+	d.report = nil
+
+	go func() {
+		var err error
+		var report exporter
+		if mode == modeCrossRepo {
+			report, err = dupes.CrossRepo(config.Get().ListRepositories())
+		} else {
+			report, err = dupes.Find(repo, minSize)
+		}
+
+		glib.IdleAdd(func() {
+			d.scanBtn.SetSensitive(true)
+			if err != nil {
+				logger.Error(err, "error generating duplicates report")
+				d.summaryLbl.SetText("⚠️ Error scanning: " + err.Error())
+				return
+			}
+			d.report = report
+			switch r := report.(type) {
+			case *dupes.CrossRepoReport:
+				d.populateCrossRepo(r)
+			case *dupes.Report:
+				if mode == modeDirs {
+					d.populateDirs(r)
+				} else {
+					d.populateFiles(r)
+				}
+			}
+			d.exportBtn.SetSensitive(true)
+		})
+	}()
+}
+
+func preferredRepo() (config.Repository, bool) {
+	for _, r := range config.Get().ListRepositories() {
+		if r.ID == config.Get().PreferredRepo() {
+			return r, true
+		}
+	}
+	return config.Repository{}, false
+}
+
+func (d *DupesList) populateFiles(report *dupes.Report) {
+	d.setTitles("Name", "Size", "Copies", "Wasted")
+	for _, g := range report.Groups {
+		iter := d.treeStore.Append(nil)
+		d.setRow(iter, g.Name, g.Size, strconv.Itoa(len(g.Locations)), humanize.Bytes(g.Wasted), "bhash:"+g.BHash)
+		for _, l := range g.Locations {
+			child := d.treeStore.Append(iter)
+			d.setRow(child, l.Path, g.Size, "", "", "bhash:"+g.BHash)
+		}
+	}
+
+	d.summaryLbl.SetText(fmt.Sprintf("%d duplicated files wasting %s", len(report.Groups), humanize.Bytes(report.Wasted)))
+}
+
+func (d *DupesList) populateDirs(report *dupes.Report) {
+	d.setTitles("Directory", "Wasted", "Files", "")
+	for _, dir := range report.Directories {
+		iter := d.treeStore.Append(nil)
+		d.setRow(iter, dir.Path, dir.Wasted, strconv.Itoa(dir.Files), "", "path:"+queryparser.Quote(dir.Path))
+	}
+
+	d.summaryLbl.SetText(fmt.Sprintf("%d directories with duplicates wasting %s", len(report.Directories), humanize.Bytes(report.Wasted)))
+}
+
+func (d *DupesList) populateCrossRepo(report *dupes.CrossRepoReport) {
+	d.setTitles("Name", "Size", "Coverage", "Repositories")
+	for _, g := range report.Groups {
+		iter := d.treeStore.Append(nil)
+		d.setRow(iter, g.Name, g.Size, string(g.Coverage), strings.Join(g.Repositories, ", "), "bhash:"+g.BHash)
+		for _, l := range g.Locations {
+			child := d.treeStore.Append(iter)
+			d.setRow(child, l.Path, g.Size, "", l.RepositoryName, "bhash:"+g.BHash)
+		}
+	}
+
+	summary := fmt.Sprintf(
+		"%d files only in one repository, %d in some, %d in all %d repositories",
+		report.Count(dupes.AtRisk),
+		report.Count(dupes.Partial),
+		report.Count(dupes.Everywhere),
+		len(report.Repositories),
+	)
+	if len(report.Skipped) > 0 {
+		summary += fmt.Sprintf(" (%d not indexed yet)", len(report.Skipped))
+	}
+	d.summaryLbl.SetText(summary)
+}
+
+func (d *DupesList) setRow(iter *gtk.TreeIter, name string, size uint64, details, extra, query string) {
+	values := map[ColID]interface{}{
+		COLUMN_NAME:    name,
+		COLUMN_SIZE:    humanize.Bytes(size),
+		COLUMN_DETAILS: details,
+		COLUMN_EXTRA:   extra,
+		COLUMN_QUERY:   query,
+		COLUMN_USIZE:   int64(size),
+	}
+	for col, v := range values {
+		if err := d.treeStore.SetValue(iter, int(col), v); err != nil {
+			logger.Error(err, "unable to set duplicates row value")
+		}
+	}
+}
+
+func (d *DupesList) export() {
+	if d.report == nil {
+		return
+	}
+
+	fc, _ := gtk.FileChooserNativeDialogNew("Export", nil, gtk.FILE_CHOOSER_ACTION_SAVE, "_Save", "_Cancel")
+	fc.SetDoOverwriteConfirmation(true)
+	fc.SetCurrentName("swamp-dupes.csv")
+	if d.lastDir != "" {
+		fc.SetCurrentFolder(d.lastDir)
+	}
+	response := fc.NativeDialog.Run()
+	if gtk.ResponseType(response) != gtk.RESPONSE_ACCEPT {
+		return
+	}
+
+	fname := fc.GetFilename()
+	d.lastDir = filepath.Dir(fname)
+	f, err := os.Create(fname)
+	if err != nil {
+		status.Set("⚠️ error exporting the report: " + err.Error())
+		return
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(fname)) == ".json" {
+		err = d.report.WriteJSON(f)
+	} else {
+		err = d.report.WriteCSV(f)
+	}
+	if err != nil {
+		status.Set("⚠️ error exporting the report: " + err.Error())
+		return
+	}
+	status.Set("Report exported to " + fname)
+}
+
+// Search for the activated files in the search pane
+func (d *DupesList) rowActivated(tree *gtk.TreeView, path *gtk.TreePath, col *gtk.TreeViewColumn) {
+	iter, err := d.treeStore.GetIter(path)
+	if err != nil {
+		return
+	}
+	value, _ := d.treeStore.GetValue(iter, int(COLUMN_QUERY))
+	query, _ := value.GetString()
+
+	eventbus.Emit(context.Background(), SearchRequestedEvent, query)
+}
@@ -1,35 +1,145 @@
 package fileinfo
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+	"github.com/swampapp/swamp/internal/annotations"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/downloader"
+	"github.com/swampapp/swamp/internal/eventbus"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/previews"
+	"github.com/swampapp/swamp/internal/queryparser"
+	"github.com/swampapp/swamp/internal/repocache"
+	"github.com/swampapp/swamp/internal/snapshots"
+	"github.com/swampapp/swamp/internal/status"
+	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/component"
 	"github.com/swampapp/swamp/internal/ui/flview"
 )
 
+// SearchRequestedEvent is emitted with a query when one of the search
+// buttons is clicked
+const SearchRequestedEvent = "fileinfo.search_requested"
+
 type FileInfo struct {
 	*component.Component
 	*gtk.Box
+	grid *gtk.Grid
+	rows int
+	// cancels the snapshots lookup when the window is closed
+	cancel context.CancelFunc
+}
+
+func init() {
+	eventbus.RegisterEvents(SearchRequestedEvent)
 }
 
 func New(f flview.File) *FileInfo {
 	fi := &FileInfo{Component: component.New("/ui/fileinfo")}
 	fi.Box = fi.GladeWidget("container").(*gtk.Box)
+	fi.grid = fi.GladeWidget("fieldsGrid").(*gtk.Grid)
 
-	lblSize := fi.GladeWidget("sizeLBL").(*gtk.Label)
-	lblSize.SetText(f.HSize)
+	doc, err := index.GetDocument(f.ID)
+	if err != nil {
+		logger.Errorf(err, "error retrieving document %s", f.ID)
+	}
 
-	lblName := fi.GladeWidget("nameLBL").(*gtk.Label)
-	lblName.SetText(f.Name)
+	fi.addRow("Name", f.Name)
+	fi.addRow("Path", f.Path)
+	fi.addRow("Size", f.HSize)
+	if doc.Mtime.IsZero() {
+		fi.addRow("Modified", "unknown")
+	} else {
+		fi.addRow("Modified", doc.Mtime.Local().Format("Jan 2 15:04:05 2006"))
+	}
+	fi.addRow("Mode", valueOrUnknown(doc.Mode))
+	fi.addRow("Owner", valueOrUnknown(doc.Owner))
+	fi.addRow("ID", f.ID)
+	fi.addRow("BHash", f.BHash)
 
-	lblID := fi.GladeWidget("idLBL").(*gtk.Label)
-	lblID.SetText(f.ID)
+	blobs, err := doc.BlobIDs()
+	if err != nil {
+		logger.Errorf(err, "invalid blobs for %s", f.ID)
+	}
+	fi.addRow("Blobs", strconv.Itoa(len(blobs)))
+	dupesLbl := fi.addRow("Duplicates", "")
+	fi.addRow("Tags", tagNames(f.ID))
+	fi.addAnnotationEditor(f.ID)
+
+	d := downloader.Instance()
+	downloaded, _ := d.WasDownloaded(f.ID)
+	switch {
+	case d.IsInProgress(f.ID):
+		fi.addRow("Download", "In progress")
+		fi.addRow("Local Path", "")
+	case downloaded:
+		fi.addRow("Download", "Downloaded")
+		fi.addRow("Local Path", downloader.PathFromID(f.ID))
+	default:
+		fi.addRow("Download", "Not downloaded")
+		fi.addRow("Local Path", "")
+	}
+
+	snapshotsLbl := fi.addRow("Snapshots", "Searching snapshots...")
+	snapshotsLbl.SetEllipsize(pango.ELLIPSIZE_NONE)
+	var ctx context.Context
+	ctx, fi.cancel = context.WithCancel(context.Background())
+	go func() {
+		text := snapshotList(ctx, doc, blobs)
+		if ctx.Err() != nil {
+			return
+		}
+		glib.IdleAdd(func() {
+			snapshotsLbl.SetText(text)
+		})
+	}()
 
-	lblPath := fi.GladeWidget("pathLBL").(*gtk.Label)
-	lblPath.SetText(f.Path)
+	// files without content have no hash
+	dupesBTN := fi.GladeWidget("dupesBTN").(*gtk.Button)
+	if f.BHash == "" {
+		dupesLbl.SetText("unknown")
+		dupesBTN.SetSensitive(false)
+	} else {
+		dupesLbl.SetText("Searching...")
+		go func() {
+			text := duplicates(f)
+			if ctx.Err() != nil {
+				return
+			}
+			glib.IdleAdd(func() {
+				dupesLbl.SetText(text)
+			})
+		}()
+	}
+
+	img := fi.GladeWidget("previewIMG").(*gtk.Image)
+	previews.Request(f.ID, f.Name, f.Size, func(path string) {
+		glib.IdleAdd(func() {
+			img.SetFromFile(path)
+			img.Show()
+		})
+	})
+
+	dupesBTN.Connect("clicked", func() {
+		search("bhash:" + f.BHash)
+	})
+	fi.GladeWidget("historyBTN").(*gtk.Button).Connect("clicked", func() {
+		search("path:" + queryparser.Quote(f.Path))
+	})
+	fi.GladeWidget("folderBTN").(*gtk.Button).Connect("clicked", func() {
+		search("path:" + queryparser.Quote(filepath.Dir(f.Path)))
+	})
 
-	lblBhash := fi.GladeWidget("bhashLBL").(*gtk.Label)
-	lblBhash.SetText(f.BHash)
 	return fi
 }
 
@@ -39,6 +149,10 @@ func NewWindow(f flview.File) *gtk.Window {
 	w.SetDefaultSize(700, 250)
 	w.Add(box)
 
+	w.Connect("destroy", func() {
+		box.cancel()
+	})
+
 	btn := box.GladeWidget("closeBTN").(*gtk.Button)
 	btn.Connect("clicked", func() bool {
 		w.Destroy()
@@ -58,3 +172,159 @@ func NewWindow(f flview.File) *gtk.Window {
 
 	return w
 }
+
+// addRow appends a field to the grid, with a button to copy its value
+func (fi *FileInfo) addRow(name, value string) *gtk.Label {
+	val, _ := gtk.LabelNew(value)
+	val.SetXAlign(0)
+	val.SetHExpand(true)
+	val.SetSelectable(true)
+	val.SetEllipsize(pango.ELLIPSIZE_END)
+
+	btn, _ := gtk.ButtonNewFromIconName("edit-copy-symbolic", gtk.ICON_SIZE_BUTTON)
+	btn.SetRelief(gtk.RELIEF_NONE)
+	btn.SetVAlign(gtk.ALIGN_START)
+	btn.SetTooltipText("Copy " + strings.ToLower(name))
+	btn.Connect("clicked", func() {
+		text, _ := val.GetText()
+		clipboard, _ := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+		clipboard.SetText(text)
+	})
+
+	fi.attachRow(name, val, btn)
+
+	return val
+}
+
+// addAnnotationEditor adds the rating and note fields of the file, saved
+// with the button beside them
+func (fi *FileInfo) addAnnotationEditor(fileID string) {
+	repoID := config.Get().PreferredRepo()
+	a, err := annotations.Get(repoID, fileID)
+	if err != nil {
+		logger.Errorf(err, "error loading the annotation of %s", fileID)
+	}
+
+	rating, _ := gtk.ComboBoxTextNew()
+	rating.AppendText("Not rated")
+	for i := 1; i <= annotations.MaxRating; i++ {
+		rating.AppendText(strings.Repeat("★", i))
+	}
+	rating.SetActive(a.Rating)
+	rating.SetHAlign(gtk.ALIGN_START)
+	fi.attachRow("Rating", rating, nil)
+
+	note, _ := gtk.TextViewNew()
+	note.SetWrapMode(gtk.WRAP_WORD_CHAR)
+	buffer, _ := note.GetBuffer()
+	buffer.SetText(a.Note)
+	sw, _ := gtk.ScrolledWindowNew(nil, nil)
+	sw.SetShadowType(gtk.SHADOW_IN)
+	sw.SetSizeRequest(-1, 60)
+	sw.Add(note)
+
+	save, _ := gtk.ButtonNewFromIconName("document-save-symbolic", gtk.ICON_SIZE_BUTTON)
+	save.SetRelief(gtk.RELIEF_NONE)
+	save.SetVAlign(gtk.ALIGN_START)
+	save.SetTooltipText("Save note and rating")
+	save.Connect("clicked", func() {
+		text, _ := buffer.GetText(buffer.GetStartIter(), buffer.GetEndIter(), false)
+		a := annotations.Annotation{Note: text, Rating: rating.GetActive()}
+		if err := annotations.Set(repoID, fileID, a); err != nil {
+			status.Error("error saving note: " + err.Error())
+			return
+		}
+		status.Set("Note saved")
+	})
+	fi.attachRow("Note", sw, save)
+}
+
+// attachRow appends a row with an arbitrary value widget to the grid
+func (fi *FileInfo) attachRow(name string, value gtk.IWidget, action gtk.IWidget) {
+	key, _ := gtk.LabelNew(name)
+	key.SetXAlign(1)
+	key.SetYAlign(0)
+	key.SetSizeRequest(100, -1)
+	ctx, _ := key.GetStyleContext()
+	ctx.AddClass("dim-label")
+
+	fi.grid.Attach(key, 0, fi.rows, 1, 1)
+	fi.grid.Attach(value, 1, fi.rows, 1, 1)
+	if action != nil {
+		fi.grid.Attach(action, 2, fi.rows, 1, 1)
+	}
+	fi.rows++
+}
+
+func search(query string) {
+	eventbus.Emit(context.Background(), SearchRequestedEvent, query)
+}
+
+func valueOrUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
+
+// duplicates returns the number of other files with the same content
+func duplicates(f flview.File) string {
+	idx, err := index.Client()
+	if err != nil {
+		logger.Error(err, "error opening the index")
+		return "unknown"
+	}
+
+	docs, err := index.Collect(idx, "bhash:"+f.BHash)
+	if err != nil {
+		logger.Errorf(err, "error searching duplicates of %s", f.ID)
+		return "unknown"
+	}
+
+	count := 0
+	for _, d := range docs {
+		if d.ID != f.ID {
+			count++
+		}
+	}
+
+	return strconv.Itoa(count)
+}
+
+func tagNames(fileID string) string {
+	// files without tags aren't found in the tags database
+	ftags, _ := tags.For(fileID)
+
+	names := []string{}
+	for _, t := range ftags {
+		names = append(names, t.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// snapshotList describes the snapshots holding this version of the file,
+// one per line
+func snapshotList(ctx context.Context, doc index.Document, blobs []string) string {
+	repo, err := repocache.Open(config.Get().PreferredRepo())
+	if err != nil {
+		logger.Error(err, "error opening repository")
+		return "Error opening the repository"
+	}
+
+	found, err := snapshots.Containing(ctx, repo, doc.Path, blobs)
+	if err != nil {
+		logger.Errorf(err, "error listing snapshots containing %s", doc.Path)
+		return "Error listing snapshots"
+	}
+	if len(found) == 0 {
+		return "None found"
+	}
+
+	lines := make([]string, 0, len(found))
+	for _, s := range found {
+		lines = append(lines, fmt.Sprintf("%s  %s  %s", s.ShortID(), s.Time.Local().Format("Jan 2 15:04 2006"), s.Hostname))
+	}
+
+	return strings.Join(lines, "\n")
+}
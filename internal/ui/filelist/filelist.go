@@ -2,26 +2,29 @@ package filelist
 
 import (
 	"fmt"
-	"path/filepath"
 	"regexp"
-	"strings"
 
-	"github.com/blugelabs/bluge"
 	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/annotations"
 	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/downloader"
+	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/index"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/queryparser"
 	"github.com/swampapp/swamp/internal/resources"
 	"github.com/swampapp/swamp/internal/status"
 	"github.com/swampapp/swamp/internal/streamer"
+	"github.com/swampapp/swamp/internal/streamserver"
 	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/component"
 	"github.com/swampapp/swamp/internal/ui/fileinfo"
 	"github.com/swampapp/swamp/internal/ui/flview"
+	"github.com/swampapp/swamp/internal/ui/preview"
 	"github.com/swampapp/swamp/internal/ui/tagger"
+	"github.com/swampapp/swamp/internal/ui/util"
 )
 
 const maxResults = 500
@@ -32,6 +35,7 @@ type FileList struct {
 	*component.Component
 	*gtk.Box
 	treeView         *flview.FLView
+	preview          *preview.Preview
 	searchEntry      *gtk.SearchEntry
 	lastExportDir    string
 	uniqueCBT        *gtk.CheckButton
@@ -50,6 +54,26 @@ func New() *FileList {
 	filelistSW := f.GladeWidget("filelistSW").(*gtk.ScrolledWindow)
 	filelistSW.Add(f.treeView)
 
+	f.preview = preview.New()
+	paned := f.GladeWidget("filelistPaned").(*gtk.Paned)
+	paned.Pack2(f.preview, false, true)
+
+	selection, _ := f.treeView.GetSelection()
+	selection.Connect("changed", func() {
+		files := f.treeView.SelectedFiles()
+		if len(files) != 1 {
+			return
+		}
+		f.preview.Load(files[0])
+	})
+
+	eventbus.ListenTo(tagger.TagsChangedEvent, func(evt *eventbus.Event) {
+		ids := evt.Data.([]string)
+		glib.IdleAdd(func() {
+			f.treeView.UpdateTags(ids)
+		})
+	})
+
 	config.AddPreferredRepoListener(func(rid string) {
 		f.updateFileList("")
 		searchEntry := f.GladeWidget("searchEntry").(*gtk.SearchEntry)
@@ -115,6 +139,47 @@ func (f *FileList) secondButtonPressed(treeview *gtk.TreeView, btn *gdk.EventBut
 	})
 	menu.Add(item)
 
+	if query, _ := f.searchEntry.GetText(); query != "" {
+		item, _ = gtk.MenuItemNew()
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+		box.SetHExpand(true)
+		box.Add(resources.ScaledImage(24, 24, "action-tag"))
+		lbl, _ = gtk.LabelNew("Tag all results")
+		box.Add(lbl)
+		item.Add(box)
+		item.Connect("activate", func() bool {
+			f.tagResults(query, true)
+			return true
+		})
+		menu.Add(item)
+
+		item, _ = gtk.MenuItemNew()
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+		box.SetHExpand(true)
+		box.Add(resources.ScaledImage(24, 24, "action-tag"))
+		lbl, _ = gtk.LabelNew("Untag all results")
+		box.Add(lbl)
+		item.Add(box)
+		item.Connect("activate", func() bool {
+			f.tagResults(query, false)
+			return true
+		})
+		menu.Add(item)
+
+		item, _ = gtk.MenuItemNew()
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+		box.SetHExpand(true)
+		box.Add(resources.ScaledImage(24, 24, "action-tag"))
+		lbl, _ = gtk.LabelNew("Always tag matching files")
+		box.Add(lbl)
+		item.Add(box)
+		item.Connect("activate", func() bool {
+			f.addTagRule(query)
+			return true
+		})
+		menu.Add(item)
+	}
+
 	// Download and open
 	item, _ = gtk.MenuItemNew()
 	box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
@@ -172,6 +237,50 @@ func (f *FileList) secondButtonPressed(treeview *gtk.TreeView, btn *gdk.EventBut
 		menu.Add(item)
 	}
 
+	if f.treeView.ItemCount() > 1 {
+		item, _ = gtk.MenuItemNew()
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+		box.SetHExpand(true)
+		box.Add(resources.ScaledImage(24, 24, "action-stream"))
+		lbl, _ = gtk.LabelNew("Stream all")
+		box.Add(lbl)
+		item.Add(box)
+		item.Connect("activate", func() bool {
+			f.streamAll()
+			return true
+		})
+		menu.Add(item)
+	}
+
+	// Copy stream URL, to play the file in a browser or any other player
+	item, _ = gtk.MenuItemNew()
+	box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+	box.SetHExpand(true)
+	box.Add(resources.ScaledImage(24, 24, "action-copy"))
+	lbl, _ = gtk.LabelNew("Copy stream URL")
+	box.Add(lbl)
+	item.Add(box)
+	item.Connect("activate", func() bool {
+		f.copyStreamURL()
+		return true
+	})
+	menu.Add(item)
+
+	if streamer.IsStreaming() {
+		item, _ = gtk.MenuItemNew()
+		box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
+		box.SetHExpand(true)
+		box.Add(resources.ScaledImage(24, 24, "action-stream"))
+		lbl, _ = gtk.LabelNew("Stop streaming")
+		box.Add(lbl)
+		item.Add(box)
+		item.Connect("activate", func() bool {
+			streamer.Stop()
+			return true
+		})
+		menu.Add(item)
+	}
+
 	//Export
 	item, _ = gtk.MenuItemNew()
 	box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 16)
@@ -205,32 +314,45 @@ func (f *FileList) secondButtonPressed(treeview *gtk.TreeView, btn *gdk.EventBut
 }
 
 func (f *FileList) isStreamable(tree *gtk.TreeView, x, y float64) bool {
-	streamable := false
-
 	fid, err := f.treeView.FileAt(int(y))
 	if err != nil {
 		logger.Errorf(err, "error retrieving file at row %d", int(y))
 		return false
 	}
 
-	switch strings.ToLower(filepath.Ext(fid.Name)) {
-	case ".mp4", ".avi", ".mkv", ".m4v", ".webm", ".mpeg", ".gif", ".mp3", ".wav", ".ogg", ".flac":
-		streamable = true
-	default:
-	}
-
-	return streamable
+	return streamer.IsStreamable(fid.Name)
 }
 
+// streamSelected streams the selected files, in the order displayed
 func (f *FileList) streamSelected() {
-	for _, n := range f.treeView.SelectedFiles() {
-		go func(file flview.File) {
-			err := streamer.Stream(file.ID)
-			if err != nil {
-				status.Error("error streaming file")
-			}
-		}(n)
+	f.streamFiles(f.treeView.SelectedFiles())
+}
+
+// streamAll streams every streamable file in the list, in the order
+// displayed
+func (f *FileList) streamAll() {
+	f.streamFiles(f.treeView.AllFiles())
+}
+
+func (f *FileList) streamFiles(files []flview.File) {
+	tracks := []streamer.Track{}
+	for _, file := range files {
+		if streamer.IsStreamable(file.Name) {
+			tracks = append(tracks, streamer.Track{ID: file.ID, Name: file.Name, Path: file.Path})
+		}
+	}
+
+	if len(tracks) == 0 {
+		status.Set("Nothing to stream")
+		return
 	}
+
+	go func() {
+		err := streamer.StreamPlaylist(tracks)
+		if err != nil {
+			status.Error("error streaming files")
+		}
+	}()
 }
 
 func (f *FileList) downloadSelected(open bool) {
@@ -278,6 +400,21 @@ func (f *FileList) copyBHash(tree *gtk.TreeView) {
 	}
 }
 
+func (f *FileList) copyStreamURL() {
+	files := f.treeView.SelectedFiles()
+	if len(files) == 0 {
+		return
+	}
+
+	url, err := streamserver.URL(files[0].ID, files[0].Name)
+	if err != nil {
+		status.Set("Error starting the stream server")
+		return
+	}
+	clipboard, _ := gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
+	clipboard.SetText(url)
+}
+
 func (f *FileList) setup() {
 	f.treeView = flview.New()
 	f.treeView.Connect("realize", f.realize)
@@ -359,7 +496,8 @@ func (f *FileList) updateFileList(query string) {
 	f.notDownloadedImg = resources.ImageForDoc("some.cloud")
 	f.downloadedImg = resources.ImageForDoc("XXX")
 	f.treeView.Clear()
-	if !f.searchTags(query) {
+	f.preview.Clear()
+	if !f.searchTags(query) && !f.searchAnnotations(query) {
 		f.searchIndex(query)
 	}
 }
@@ -375,19 +513,94 @@ func (f *FileList) searchTags(query string) bool {
 		}
 		logger.Printf("searching for tag %s", tname)
 		docs, _ := tags.GetDocuments(tname)
-		for _, doc := range docs {
-			if ok, _ := downloader.Instance().WasDownloaded(doc.ID); ok {
-				f.treeView.AddRow(f.downloadedImg, doc.Name, doc.Path, doc.Size, doc.ID, doc.BHash)
-			} else {
-				f.treeView.AddRow(f.notDownloadedImg, doc.Name, doc.Path, doc.Size, doc.ID, doc.BHash)
-			}
-		}
+		f.addDocuments(docs)
 		return true
 	}
 
 	return false
 }
 
+// searchAnnotations lists the files whose notes and ratings match the
+// note: and rating: fields of the query, if any
+func (f *FileList) searchAnnotations(query string) bool {
+	filter, rest, ok, err := annotations.ParseQuery(query)
+	if !ok {
+		return false
+	}
+	if err != nil {
+		status.Set("⚠️ invalid query: " + err.Error())
+		return true
+	}
+
+	docs, err := annotatedDocuments(filter, rest)
+	if err != nil {
+		status.Set("⚠️ Error while searching: " + err.Error())
+		return true
+	}
+	if len(docs) > maxResults {
+		docs = docs[:maxResults]
+	}
+	f.addDocuments(docs)
+	status.SetRight(fmt.Sprintf("%d results", len(docs)))
+	status.Set("")
+
+	return true
+}
+
+// annotatedDocuments returns the annotated documents matching the filter and
+// the rest of the query
+func annotatedDocuments(filter annotations.Filter, query string) ([]index.Document, error) {
+	ids, err := annotations.Find(config.Get().PreferredRepo(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if query == "" {
+		return index.GetDocuments(ids)
+	}
+
+	idx, err := index.Client()
+	if err != nil {
+		return nil, err
+	}
+	q, err := queryparser.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := index.Collect(idx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	annotated := map[string]bool{}
+	for _, id := range ids {
+		annotated[id] = true
+	}
+	docs := []index.Document{}
+	for _, d := range matches {
+		if annotated[d.ID] {
+			docs = append(docs, d)
+		}
+	}
+
+	return docs, nil
+}
+
+func (f *FileList) addDocuments(docs []index.Document) {
+	for _, doc := range docs {
+		f.treeView.AddDocumentRow(f.imageFor(doc.ID), doc, "")
+	}
+}
+
+// imageFor returns the icon displayed for the file, depending on whether
+// it was downloaded
+func (f *FileList) imageFor(fileID string) *gdk.Pixbuf {
+	if ok, _ := downloader.Instance().WasDownloaded(fileID); ok {
+		return f.downloadedImg
+	}
+
+	return f.notDownloadedImg
+}
+
 func (f *FileList) searchIndex(query string) {
 	idx, err := index.Client()
 	if err != nil {
@@ -397,8 +610,7 @@ func (f *FileList) searchIndex(query string) {
 
 	filterDupes := f.uniqueCBT.GetActive()
 	idCache := map[string]struct{}{}
-	var fileID, filename, path, bhash string
-	size := 0.0
+	doc := index.Document{}
 	count := 0
 	q, err := queryparser.ParseQuery(query)
 	logger.Debugf("searching for %s", q)
@@ -406,38 +618,21 @@ func (f *FileList) searchIndex(query string) {
 		status.Set("⚠️ invalid query: " + err.Error())
 		return
 	}
+	terms := queryparser.ContentTerms(query)
 
 	_, err = idx.Search(q, func(field string, value []byte) bool {
-		switch field {
-		case "filename":
-			filename = string(value)
-		case "path":
-			path = string(value)
-		case "size":
-			size, err = bluge.DecodeNumericFloat64(value)
-			if err != nil {
-				size = -1
-			}
-		case "_id":
-			fileID = string(value)
-		case "bhash":
-			bhash = string(value)
-		}
-
+		doc.SetField(field, value)
 		return true
 	},
 		func() bool {
-			_, found := idCache[bhash]
+			defer func() { doc = index.Document{} }()
+			_, found := idCache[doc.BHash]
 			if filterDupes && found {
 				return true
 			}
-			idCache[bhash] = struct{}{}
+			idCache[doc.BHash] = struct{}{}
 			// FIXME: this is quite expensive with a large number of results
-			if ok, _ := downloader.Instance().WasDownloaded(fileID); ok {
-				f.treeView.AddRow(f.downloadedImg, filename, path, fmt.Sprintf("%.0f", size), fileID, bhash)
-			} else {
-				f.treeView.AddRow(f.notDownloadedImg, filename, path, fmt.Sprintf("%.0f", size), fileID, bhash)
-			}
+			f.treeView.AddDocumentRow(f.imageFor(doc.ID), doc, index.Snippet(doc.Content, terms))
 			count++
 			return count <= maxResults
 		},
@@ -492,17 +687,119 @@ func (f *FileList) exportFiles() {
 	}
 }
 
+// tagResults adds or removes a tag from every file matching the query, not
+// only the ones displayed
+func (f *FileList) tagResults(query string, add bool) {
+	title := "Tag all results"
+	if !add {
+		title = "Untag all results"
+	}
+	tag, ok := util.Prompt(title, fmt.Sprintf("Tag for the files matching '%s':", query))
+	if !ok {
+		return
+	}
+
+	ids, err := f.queryFileIDs(query)
+	if err != nil {
+		status.Error("error searching files: " + err.Error())
+		return
+	}
+
+	if add {
+		err = tags.Add(ids, tags.Tag{Name: tag})
+	} else {
+		err = tags.Remove(ids, tag)
+	}
+	if err != nil {
+		status.Error("error updating tags: " + err.Error())
+		return
+	}
+	f.treeView.UpdateTags(ids)
+
+	status.Set(fmt.Sprintf("%d files updated", len(ids)))
+}
+
+// addTagRule saves a rule tagging every file matching the query, including
+// the ones indexed from now on
+func (f *FileList) addTagRule(query string) {
+	tag, ok := util.Prompt("Tag rule", fmt.Sprintf("Tag every file matching '%s', now and after indexing, with:", query))
+	if !ok {
+		return
+	}
+
+	if err := tags.AddRule(config.Get().PreferredRepo(), query, tag); err != nil {
+		status.Error("error saving tag rule: " + err.Error())
+		return
+	}
+
+	// tag the files already indexed right away
+	ids, err := f.queryFileIDs(query)
+	if err == nil {
+		err = tags.Add(ids, tags.Tag{Name: tag})
+	}
+	if err != nil {
+		status.Error("error tagging files: " + err.Error())
+		return
+	}
+	f.treeView.UpdateTags(ids)
+
+	status.Set(fmt.Sprintf("Tag rule saved, %d files tagged", len(ids)))
+}
+
+// queryFileIDs returns the IDs of every file matching the query
+func (f *FileList) queryFileIDs(query string) ([]string, error) {
+	if match := tagRegexp.FindStringSubmatch(query); match != nil {
+		return tags.FileIDs(match[1])
+	}
+
+	if filter, rest, ok, err := annotations.ParseQuery(query); ok {
+		if err != nil {
+			return nil, err
+		}
+		docs, err := annotatedDocuments(filter, rest)
+		if err != nil {
+			return nil, err
+		}
+		return documentIDs(docs), nil
+	}
+
+	idx, err := index.Client()
+	if err != nil {
+		return nil, err
+	}
+	q, err := queryparser.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := index.Collect(idx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return documentIDs(docs), nil
+}
+
+func documentIDs(docs []index.Document) []string {
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+
+	return ids
+}
+
 func (f *FileList) tagSelected() {
 	files := f.treeView.SelectedFiles()
-	var fid string
-	// FIXME: we only support tagging the first one selected for now
+	if len(files) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(files))
 	for _, file := range files {
-		fid = file.ID
-		break
+		ids = append(ids, file.ID)
 	}
 
 	tw, _ := gtk.WindowNew(gtk.WINDOW_TOPLEVEL)
-	tw.Add(tagger.New(fid))
+	tw.Add(tagger.New(ids))
 	tw.Connect("key-press-event", func(w *gtk.Window, ev *gdk.Event) bool {
 		kp := gdk.EventKeyNewFromEvent(ev)
 		switch kp.KeyVal() {
@@ -2,20 +2,38 @@ package flview
 
 import (
 	"fmt"
+	"html"
 	"log"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/gotk3/gotk3/pango"
+	"github.com/swampapp/swamp/internal/downloader"
+	"github.com/swampapp/swamp/internal/index"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/previews"
 	"github.com/swampapp/swamp/internal/status"
+	"github.com/swampapp/swamp/internal/tags"
 )
 
+// size of the thumbnails displayed in the icon column
+const thumbnailSize = 48
+
+// size in bytes of the largest file fetched from the repository to display
+// its thumbnail, larger files are only previewed once downloaded
+const maxFetchedThumbnailSize = 2 * 1024 * 1024
+
 type FLView struct {
 	*gtk.TreeView
 	listStore *gtk.ListStore
+	// columns only displayed when a file in the list has a value
+	optional map[ColID]*gtk.TreeViewColumn
 }
 
 type File struct {
@@ -37,10 +55,18 @@ const (
 	COLUMN_ID
 	COLUMN_USIZE
 	COLUMN_BHASH
+	COLUMN_TAGS
+	COLUMN_MATCH
+	COLUMN_ARTIST
+	COLUMN_ALBUM
+	COLUMN_DURATION
+	COLUMN_CAMERA
+	COLUMN_DIMENSIONS
+	COLUMN_TAKEN
 )
 
 func New() *FLView {
-	flv := &FLView{}
+	flv := &FLView{optional: map[ColID]*gtk.TreeViewColumn{}}
 	flv.TreeView, _ = gtk.TreeViewNew()
 	flv.listStore, _ = gtk.ListStoreNew(
 		glib.TYPE_OBJECT,
@@ -50,6 +76,14 @@ func New() *FLView {
 		glib.TYPE_STRING,
 		glib.TYPE_INT64,
 		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
+		glib.TYPE_STRING,
 	)
 	flv.SetModel(flv.listStore)
 
@@ -61,6 +95,14 @@ func New() *FLView {
 	flv.AppendColumn(createImageColumn("", int(COLUMN_ICON)))
 	flv.AppendColumn(createColumn("Filename", int(COLUMN_NAME), 60))
 	flv.AppendColumn(createColumn("Path", int(COLUMN_PATH), 40))
+	flv.AppendColumn(createMarkupColumn("Tags", int(COLUMN_TAGS), 20))
+	flv.appendOptional(COLUMN_MATCH, createMarkupColumn("Match", int(COLUMN_MATCH), 60))
+	flv.appendOptional(COLUMN_ARTIST, createColumn("Artist", int(COLUMN_ARTIST), 20))
+	flv.appendOptional(COLUMN_ALBUM, createColumn("Album", int(COLUMN_ALBUM), 20))
+	flv.appendOptional(COLUMN_DURATION, createColumn("Duration", int(COLUMN_DURATION), 8))
+	flv.appendOptional(COLUMN_CAMERA, createColumn("Camera", int(COLUMN_CAMERA), 20))
+	flv.appendOptional(COLUMN_DIMENSIONS, createColumn("Dimensions", int(COLUMN_DIMENSIONS), 10))
+	flv.appendOptional(COLUMN_TAKEN, createColumn("Taken", int(COLUMN_TAKEN), 16))
 	flv.AppendColumn(createBytesColumn("Size", int(COLUMN_SIZE), 40))
 	flv.AppendColumn(createColumn("ID", int(COLUMN_ID), 40))
 	flv.AppendColumn(createColumn("BHash", int(COLUMN_BHASH), 40))
@@ -71,6 +113,14 @@ func New() *FLView {
 	return flv
 }
 
+// appendOptional appends a column hidden until a file with a value for it
+// is added
+func (flv *FLView) appendOptional(id ColID, column *gtk.TreeViewColumn) {
+	column.SetVisible(false)
+	flv.optional[id] = column
+	flv.AppendColumn(column)
+}
+
 func (flv *FLView) RemoveSelected() {
 	sel, _ := flv.GetSelection()
 	rows := sel.GetSelectedRows(flv.Model())
@@ -118,25 +168,42 @@ func (flv *FLView) SelectedFiles() []File {
 	rows.Foreach(func(item interface{}) {
 		path := item.(*gtk.TreePath)
 		if iter, err := flv.Model().GetIter(path); err == nil {
-			file := File{}
-			value, _ := flv.Model().GetValue(iter, int(COLUMN_ID))
-			file.ID, _ = value.GetString()
-			value, _ = flv.Model().GetValue(iter, int(COLUMN_NAME))
-			file.Name, _ = value.GetString()
-			value, _ = flv.Model().GetValue(iter, int(COLUMN_PATH))
-			file.Path, _ = value.GetString()
-			value, _ = flv.Model().GetValue(iter, int(COLUMN_SIZE))
-			file.HSize, _ = value.GetString()
-			file.Size, _ = humanize.ParseBytes(file.HSize)
-			value, _ = flv.Model().GetValue(iter, int(COLUMN_BHASH))
-			file.BHash, _ = value.GetString()
-			files = append(files, file)
+			files = append(files, flv.fileAtIter(iter))
 		}
 	})
 
 	return files
 }
 
+// AllFiles returns every file in the list, in the order displayed
+func (flv *FLView) AllFiles() []File {
+	files := []File{}
+
+	flv.Model().ForEach(func(model *gtk.TreeModel, path *gtk.TreePath, iter *gtk.TreeIter) bool {
+		files = append(files, flv.fileAtIter(iter))
+		return false
+	})
+
+	return files
+}
+
+func (flv *FLView) fileAtIter(iter *gtk.TreeIter) File {
+	file := File{}
+	value, _ := flv.Model().GetValue(iter, int(COLUMN_ID))
+	file.ID, _ = value.GetString()
+	value, _ = flv.Model().GetValue(iter, int(COLUMN_NAME))
+	file.Name, _ = value.GetString()
+	value, _ = flv.Model().GetValue(iter, int(COLUMN_PATH))
+	file.Path, _ = value.GetString()
+	value, _ = flv.Model().GetValue(iter, int(COLUMN_SIZE))
+	file.HSize, _ = value.GetString()
+	file.Size, _ = humanize.ParseBytes(file.HSize)
+	value, _ = flv.Model().GetValue(iter, int(COLUMN_BHASH))
+	file.BHash, _ = value.GetString()
+
+	return file
+}
+
 func (flv *FLView) FileAt(row int) (*File, error) {
 	path, _, _, _, _ := flv.GetPathAtPos(0, row)
 	iter, err := flv.Model().GetIter(path)
@@ -160,6 +227,9 @@ func (flv *FLView) ItemCount() int {
 
 func (flv *FLView) Clear() {
 	flv.Model().Clear()
+	for _, column := range flv.optional {
+		column.SetVisible(false)
+	}
 }
 
 func (flv *FLView) Model() *gtk.ListStore {
@@ -171,6 +241,59 @@ func (flv *FLView) TreeViewWidget() *gtk.TreeView {
 }
 
 func (flv *FLView) AddRow(image *gdk.Pixbuf, filename, path, size, fileID, bhash string) {
+	flv.addRow(image, filename, path, size, fileID, bhash)
+}
+
+// AddDocumentRow adds an indexed file, displaying its media metadata. match
+// is the Pango markup highlighting the content matching the search, if any.
+func (flv *FLView) AddDocumentRow(image *gdk.Pixbuf, doc index.Document, match string) {
+	iter := flv.addRow(image, doc.Name, doc.Path, doc.Size, doc.ID, doc.BHash)
+
+	values := map[ColID]string{
+		COLUMN_MATCH:    match,
+		COLUMN_ARTIST:   doc.Artist,
+		COLUMN_ALBUM:    doc.Album,
+		COLUMN_DURATION: formatDuration(doc.Duration),
+		COLUMN_CAMERA:   doc.Camera,
+		COLUMN_TAKEN:    formatDate(doc.ExifDate),
+	}
+	if doc.Width > 0 && doc.Height > 0 {
+		values[COLUMN_DIMENSIONS] = fmt.Sprintf("%d×%d", doc.Width, doc.Height)
+	}
+	for id, value := range values {
+		if value == "" {
+			continue
+		}
+		if err := flv.Model().SetValue(iter, int(id), value); err != nil {
+			logger.Error(err, "error setting file metadata")
+		}
+		flv.optional[id].SetVisible(true)
+	}
+}
+
+// formatDuration returns the duration as [h:]mm:ss, empty if 0
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s%3600/60, s%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format("2006-01-02 15:04")
+}
+
+func (flv *FLView) addRow(image *gdk.Pixbuf, filename, path, size, fileID, bhash string) *gtk.TreeIter {
 	iter := flv.Model().Append()
 
 	// Set the contents of the list store row that the iterator represents
@@ -178,13 +301,104 @@ func (flv *FLView) AddRow(image *gdk.Pixbuf, filename, path, size, fileID, bhash
 	// the 5 column is an invisible column used to store the size in bytes, so it can be
 	// properly sorted when clicking the column
 	err := flv.Model().Set(iter,
-		[]int{int(COLUMN_ICON), int(COLUMN_NAME), int(COLUMN_PATH), int(COLUMN_SIZE), int(COLUMN_ID), int(COLUMN_USIZE), int(COLUMN_BHASH)},
-		[]interface{}{image, filename, path, humanize.Bytes(usize), fileID, usize, bhash})
+		[]int{int(COLUMN_ICON), int(COLUMN_NAME), int(COLUMN_PATH), int(COLUMN_SIZE), int(COLUMN_ID), int(COLUMN_USIZE), int(COLUMN_BHASH), int(COLUMN_TAGS)},
+		[]interface{}{image, filename, path, humanize.Bytes(usize), fileID, usize, bhash, tagChips(fileID)})
 
 	if err != nil {
 		log.Print("Unable to add row")
 		panic(err)
 	}
+
+	// search results can list thousands of images, don't fetch large ones
+	if _, err := os.Stat(downloader.PathFromID(fileID)); err == nil || usize <= maxFetchedThumbnailSize {
+		previews.Request(fileID, filename, usize, func(path string) {
+			glib.IdleAdd(func() {
+				flv.setThumbnail(fileID, path)
+			})
+		})
+	}
+
+	return iter
+}
+
+// UpdateTags displays the current tags of the files
+func (flv *FLView) UpdateTags(fileIDs []string) {
+	ids := map[string]bool{}
+	for _, id := range fileIDs {
+		ids[id] = true
+	}
+
+	flv.Model().ForEach(func(model *gtk.TreeModel, tpath *gtk.TreePath, iter *gtk.TreeIter) bool {
+		value, _ := model.GetValue(iter, int(COLUMN_ID))
+		if id, _ := value.GetString(); ids[id] {
+			if err := flv.Model().SetValue(iter, int(COLUMN_TAGS), tagChips(id)); err != nil {
+				logger.Error(err, "error setting tags")
+			}
+		}
+		return false
+	})
+}
+
+// tagChips returns the markup displaying the tags of the file as chips
+// with the tag colors
+func tagChips(fileID string) string {
+	ftags, err := tags.For(fileID)
+	if err != nil {
+		return ""
+	}
+
+	chips := make([]string, 0, len(ftags))
+	for _, t := range ftags {
+		bg := t.Color
+		if bg == "" {
+			bg = defaultTagColor
+		}
+		chips = append(chips, fmt.Sprintf(
+			`<span background="%s" foreground="%s"> %s </span>`,
+			html.EscapeString(bg),
+			foregroundFor(bg),
+			html.EscapeString(t.Name),
+		))
+	}
+
+	return strings.Join(chips, " ")
+}
+
+// color of the tags without one
+const defaultTagColor = "#888888"
+
+// foregroundFor returns black or white, whatever reads better over the
+// #rrggbb background color
+func foregroundFor(bg string) string {
+	var r, g, b int
+	if _, err := fmt.Sscanf(bg, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return "#ffffff"
+	}
+	if 299*r+587*g+114*b > 150000 {
+		return "#000000"
+	}
+
+	return "#ffffff"
+}
+
+// setThumbnail replaces the icon of the rows displaying the file with its
+// thumbnail
+func (flv *FLView) setThumbnail(fileID, path string) {
+	pixbuf, err := gdk.PixbufNewFromFileAtScale(path, thumbnailSize, thumbnailSize, true)
+	if err != nil {
+		logger.Errorf(err, "error loading thumbnail %s", path)
+		return
+	}
+
+	flv.Model().ForEach(func(model *gtk.TreeModel, tpath *gtk.TreePath, iter *gtk.TreeIter) bool {
+		value, _ := model.GetValue(iter, int(COLUMN_ID))
+		if id, _ := value.GetString(); id == fileID {
+			if err := flv.Model().SetValue(iter, int(COLUMN_ICON), pixbuf); err != nil {
+				logger.Error(err, "error setting thumbnail")
+			}
+		}
+		return false
+	})
 }
 
 // Add a column to the tree view (during the initialization of the tree view)
@@ -218,6 +432,20 @@ func createBytesColumn(title string, id int, width int) *gtk.TreeViewColumn {
 	return column
 }
 
+// createMarkupColumn adds a column displaying Pango markup
+func createMarkupColumn(title string, id int, width int) *gtk.TreeViewColumn {
+	cellRenderer, _ := gtk.CellRendererTextNew()
+	cellRenderer.Set("xpad", 20)
+	cellRenderer.Set("ellipsize-set", true)
+	cellRenderer.Set("ellipsize", pango.ELLIPSIZE_END)
+	cellRenderer.Set("width-chars", width)
+
+	column, _ := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "markup", id)
+	column.SetResizable(true)
+
+	return column
+}
+
 func createImageColumn(title string, id int) *gtk.TreeViewColumn {
 	// In this column we want to show image data from Pixbuf, hence
 	// create a pixbuf renderer
@@ -3,6 +3,7 @@ package indexer
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/rubiojr/rindex"
+	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/indexer"
 	"github.com/swampapp/swamp/internal/logger"
@@ -27,7 +29,18 @@ type Indexer struct {
 	statusLbl, cpuTimeLbl *gtk.Label
 	durationLbl, rssLbl   *gtk.Label
 	startTimeLbl          *gtk.Label
+	nextRunLbl            *gtk.Label
+	deferredLbl           *gtk.Label
 	statusProgress        *gtk.ProgressBar
+	reposBox              *gtk.Box
+	repoRows              map[string]*repoRow
+}
+
+// repoRow displays the indexing progress of a single repository
+type repoRow struct {
+	*gtk.Box
+	label     *gtk.Label
+	cancelBtn *gtk.Button
 }
 
 func New() *Indexer {
@@ -38,7 +51,11 @@ func New() *Indexer {
 	i.cpuTimeLbl = i.GladeWidget("cpuTimeLbl").(*gtk.Label)
 	i.durationLbl = i.GladeWidget("durationLbl").(*gtk.Label)
 	i.startTimeLbl = i.GladeWidget("startTimeLbl").(*gtk.Label)
+	i.nextRunLbl = i.GladeWidget("nextRunLbl").(*gtk.Label)
+	i.deferredLbl = i.GladeWidget("deferredLbl").(*gtk.Label)
 	i.statusProgress = i.GladeWidget("statusProgress").(*gtk.ProgressBar)
+	i.reposBox = i.GladeWidget("reposVBX").(*gtk.Box)
+	i.repoRows = map[string]*repoRow{}
 	i.ctx, i.cancelFunc = context.WithCancel(context.Background())
 
 	i.indexButton = i.GladeWidget("indexBTN").(*gtk.Button)
@@ -55,6 +72,14 @@ func New() *Indexer {
 
 	i.indexAnimation, _ = i.GladeWidget("indexingFlask").(*gtk.Image)
 
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		for {
+			glib.IdleAdd(i.updateNextRun)
+			<-ticker.C
+		}
+	}()
+
 	eventbus.ListenTo(
 		indexer.IndexingStoppedEvent,
 		func(*eventbus.Event) {
@@ -62,6 +87,16 @@ func New() *Indexer {
 		},
 	)
 
+	eventbus.ListenTo(
+		indexer.IndexingDeferredEvent,
+		func(evt *eventbus.Event) {
+			reason, _ := evt.Data.(string)
+			glib.IdleAdd(func() {
+				i.setDeferReason(reason)
+			})
+		},
+	)
+
 	eventbus.ListenTo(
 		indexer.IndexingStartedEvent,
 		func(*eventbus.Event) {
@@ -79,6 +114,44 @@ func (i *Indexer) stop() {
 	resources.UpdateImageFromResource(i.indexAnimation, "indexing-done")
 	i.statusProgress.SetText("Indexing finished")
 	i.indexAnimation.Show()
+	i.updateNextRun()
+}
+
+func (i *Indexer) setDeferReason(reason string) {
+	i.deferredLbl.SetText(reason)
+	i.deferredLbl.SetVisible(reason != "")
+}
+
+// updateNextRun displays the next scheduled indexing run
+func (i *Indexer) updateNextRun() {
+	if !config.Exists() {
+		return
+	}
+
+	var next time.Time
+	var name string
+	for _, r := range config.Get().ListRepositories() {
+		t, ok := indexer.Daemon().NextRun(r.ID)
+		if !ok {
+			continue
+		}
+		if next.IsZero() || t.Before(next) {
+			next = t
+			name = r.Name
+		}
+	}
+
+	if next.IsZero() {
+		i.nextRunLbl.SetText("Automatic indexing disabled")
+		return
+	}
+
+	if next.Before(time.Now()) {
+		i.nextRunLbl.SetText(fmt.Sprintf("Next run: %s, as soon as possible", name))
+		return
+	}
+
+	i.nextRunLbl.SetText(fmt.Sprintf("Next run: %s on %s", name, next.Local().Format("Jan 2 15:04 2006")))
 }
 
 func (i *Indexer) updateTopLabel(pstats indexer.ProcStats, start time.Time, stats rindex.IndexStats) {
@@ -97,6 +170,10 @@ func (i *Indexer) start() {
 	sTime := time.Now()
 
 	glib.IdleAdd(func() {
+		for id, row := range i.repoRows {
+			row.Destroy()
+			delete(i.repoRows, id)
+		}
 		i.indexButton.SetLabel("Stop Indexing")
 		i.statusProgress.SetText("Preparing to index repository...")
 		resources.UpdateImageFromResource(i.indexAnimation, "indexing")
@@ -118,6 +195,10 @@ func (i *Indexer) start() {
 				})
 				return
 			case <-ticker.C:
+				// swampd doesn't reply while paused
+				if indexer.Daemon().Paused() {
+					continue
+				}
 				pstats, err := indexer.GetProcStats()
 				if err != nil {
 					logger.Error(err, "error fetching swampd procstats")
@@ -134,7 +215,12 @@ func (i *Indexer) start() {
 				if stats.CurrentSnapshotTotalFiles > 0 {
 					percentage = float64(stats.CurrentSnapshotFiles) / float64(stats.CurrentSnapshotTotalFiles)
 				}
+				repos, err := indexer.ReposStats()
+				if err != nil {
+					logger.Error(err, "indexerui: error retrieving repository stats")
+				}
 				glib.IdleAdd(func() {
+					i.updateRepos(repos)
 					if stats.ScannedFiles > 0 {
 						i.statusProgress.SetText(fmt.Sprintf("Snapshot Progress: %d%%", int(percentage*100)))
 					}
@@ -150,3 +236,50 @@ func (i *Indexer) start() {
 		}
 	}()
 }
+
+func (i *Indexer) updateRepos(repos map[string]indexer.RepoStats) {
+	ids := make([]string, 0, len(repos))
+	for id := range repos {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return repos[ids[a]].Name < repos[ids[b]].Name
+	})
+
+	for _, id := range ids {
+		rs := repos[id]
+		row, ok := i.repoRows[id]
+		if !ok {
+			row = i.newRepoRow(id)
+			i.repoRows[id] = row
+		}
+		row.label.SetText(fmt.Sprintf("%s: %s, %d added, snapshots [%d/%d]",
+			rs.Name,
+			rs.State,
+			rs.Stats.IndexedFiles,
+			rs.Stats.ScannedSnapshots,
+			rs.Stats.TotalSnapshots,
+		))
+		running := rs.State == indexer.StateQueued || rs.State == indexer.StateIndexing
+		row.cancelBtn.SetSensitive(running)
+	}
+}
+
+func (i *Indexer) newRepoRow(id string) *repoRow {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	lbl, _ := gtk.LabelNew("")
+	lbl.SetXAlign(0)
+	btn, _ := gtk.ButtonNewWithLabel("Cancel")
+	btn.Connect("clicked", func() {
+		logger.Printf("manual indexing stop for repository %s", id)
+		if err := indexer.Daemon().Cancel(id); err != nil {
+			logger.Error(err, "error cancelling repository indexing")
+		}
+	})
+	box.PackStart(lbl, true, true, 0)
+	box.PackEnd(btn, false, false, 0)
+	box.ShowAll()
+	i.reposBox.PackStart(box, false, false, 0)
+
+	return &repoRow{Box: box, label: lbl, cancelBtn: btn}
+}
@@ -2,6 +2,7 @@ package mainwindow
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
@@ -10,14 +11,18 @@ import (
 	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/downloader"
 	"github.com/swampapp/swamp/internal/eventbus"
+	"github.com/swampapp/swamp/internal/index"
 	indexerd "github.com/swampapp/swamp/internal/indexer"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/resources"
 	"github.com/swampapp/swamp/internal/status"
 	"github.com/swampapp/swamp/internal/streamer"
+	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/appmenu"
 	"github.com/swampapp/swamp/internal/ui/component"
 	"github.com/swampapp/swamp/internal/ui/downloadlist"
+	"github.com/swampapp/swamp/internal/ui/dupeslist"
+	"github.com/swampapp/swamp/internal/ui/fileinfo"
 	"github.com/swampapp/swamp/internal/ui/filelist"
 	"github.com/swampapp/swamp/internal/ui/indexer"
 	"github.com/swampapp/swamp/internal/ui/inprogresslist"
@@ -36,6 +41,7 @@ type MainWindow struct {
 	paned          *gtk.Paned
 	searchText     string
 	indexerUI      *indexer.Indexer
+	dupesList      *dupeslist.DupesList
 }
 
 func New(a *gtk.Application) (*MainWindow, error) {
@@ -62,6 +68,7 @@ func New(a *gtk.Application) (*MainWindow, error) {
 		tagList:           taglist.New(),
 		fileList:          filelist.New(),
 		indexerUI:         indexer.New(),
+		dupesList:         dupeslist.New(),
 	}
 
 	resources.LoadImages()
@@ -127,6 +134,24 @@ func New(a *gtk.Application) (*MainWindow, error) {
 		},
 	)
 
+	eventbus.ListenTo(
+		dupeslist.SearchRequestedEvent,
+		func(evt *eventbus.Event) {
+			mw.searchText = evt.Data.(string)
+			mw.appMenu.SelectPath("0")
+			mw.searchText = ""
+		},
+	)
+
+	eventbus.ListenTo(
+		fileinfo.SearchRequestedEvent,
+		func(evt *eventbus.Event) {
+			mw.searchText = evt.Data.(string)
+			mw.appMenu.SelectPath("0")
+			mw.searchText = ""
+		},
+	)
+
 	mw.StopDownloading()
 	mw.StopIndexing()
 	mw.appMenu.SelectPath("0")
@@ -156,6 +181,10 @@ func New(a *gtk.Application) (*MainWindow, error) {
 		indexerd.IndexingStoppedEvent,
 		func(*eventbus.Event) {
 			mw.StopIndexing()
+			go func() {
+				relinkTags()
+				applyTagRules()
+			}()
 		},
 	)
 
@@ -193,6 +222,8 @@ func New(a *gtk.Application) (*MainWindow, error) {
 		mw.downloadQueueEmpty,
 	)
 
+	go backupTags()
+
 	return mw, nil
 }
 
@@ -224,6 +255,8 @@ func (w *MainWindow) SetMainPanel(t string) {
 		w.paned.Add2(w.inprogressList)
 	case "Indexer":
 		w.paned.Add2(w.indexerUI)
+	case "Duplicates":
+		w.paned.Add2(w.dupesList)
 	case "Settings":
 		w.paned.Add2(settingsui.New())
 	default:
@@ -319,3 +352,60 @@ func (w *MainWindow) downloadFinished(evt *eventbus.Event) {
 func (w *MainWindow) downloadQueueEmpty(evt *eventbus.Event) {
 	w.StopDownloading()
 }
+
+// applyTagRules tags the files of the preferred repository matching its
+// tag rules. swampd skips them, the app holds the tags database open.
+func applyTagRules() {
+	pr := config.Get().PreferredRepo()
+	if pr == "" {
+		return
+	}
+
+	idx, err := index.Client()
+	if err != nil {
+		logger.Error(err, "error opening the index to apply tag rules")
+		return
+	}
+
+	tagged, err := tags.ApplyRules(pr, idx)
+	if err != nil {
+		logger.Error(err, "error applying tag rules")
+		return
+	}
+	if tagged > 0 {
+		status.Set(fmt.Sprintf("%d files tagged by tag rules", tagged))
+	}
+}
+
+// relinkTags moves the tags of files whose ID changed when indexed again
+func relinkTags() {
+	pr := config.Get().PreferredRepo()
+	if pr == "" {
+		return
+	}
+
+	report, err := tags.Relink(pr)
+	if err != nil {
+		logger.Error(err, "error relinking orphaned tags")
+		return
+	}
+	for _, o := range report.Dangling {
+		logger.Debugf("tagged file %s (%s) not found in the index", o.FileID, o.Path)
+	}
+	if len(report.Recovered) > 0 {
+		status.Set(fmt.Sprintf("Tags of %d re-indexed files recovered", len(report.Recovered)))
+	}
+}
+
+// backupTags backs up the tags of the preferred repository once a day
+func backupTags() {
+	ticker := time.NewTicker(time.Hour)
+	for {
+		if pr := config.Get().PreferredRepo(); pr != "" {
+			if _, err := tags.BackupIfDue(pr); err != nil {
+				logger.Error(err, "error backing up tags")
+			}
+		}
+		<-ticker.C
+	}
+}
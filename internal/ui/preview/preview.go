@@ -0,0 +1,153 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/players"
+	"github.com/swampapp/swamp/internal/textpreview"
+	"github.com/swampapp/swamp/internal/ui/component"
+	"github.com/swampapp/swamp/internal/ui/flview"
+)
+
+// wait before fetching, so moving quickly through the file list doesn't
+// fetch every file
+const fetchDelay = 300 * time.Millisecond
+
+// Preview displays the beginning of text files, fetched from the repository
+// without downloading them
+type Preview struct {
+	*component.Component
+	*gtk.Box
+	nameLbl     *gtk.Label
+	infoLbl     *gtk.Label
+	searchEntry *gtk.SearchEntry
+	textView    *gtk.TextView
+	buffer      *gtk.TextBuffer
+	cancel      context.CancelFunc
+}
+
+func New() *Preview {
+	p := &Preview{Component: component.New("/ui/preview")}
+	p.Box = p.GladeWidget("container").(*gtk.Box)
+	p.nameLbl = p.GladeWidget("nameLBL").(*gtk.Label)
+	p.infoLbl = p.GladeWidget("infoLBL").(*gtk.Label)
+	p.searchEntry = p.GladeWidget("searchEntry").(*gtk.SearchEntry)
+	p.textView = p.GladeWidget("textView").(*gtk.TextView)
+	p.buffer, _ = p.textView.GetBuffer()
+	p.cancel = func() {}
+
+	p.searchEntry.Connect("search-changed", func() {
+		p.find(false)
+	})
+	p.searchEntry.Connect("activate", func() {
+		p.find(true)
+	})
+	p.searchEntry.Connect("next-match", func() {
+		p.find(true)
+	})
+
+	return p
+}
+
+// Load fetches and displays the beginning of the file
+func (p *Preview) Load(file flview.File) {
+	p.cancel()
+	p.nameLbl.SetText(file.Name)
+	p.buffer.SetText("")
+
+	switch players.KindOf(file.Name) {
+	case players.Video, players.Audio, players.Image:
+		p.infoLbl.SetText("No text preview available")
+		return
+	}
+
+	p.infoLbl.SetText("Loading...")
+
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(fetchDelay):
+		}
+
+		preview, err := textpreview.Fetch(ctx, file.ID, textpreview.DefaultMaxBytes)
+		if ctx.Err() != nil {
+			return
+		}
+
+		glib.IdleAdd(func() {
+			// a different file was selected meanwhile
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				logger.Errorf(err, "error previewing %s", file.Name)
+				p.infoLbl.SetText("Error loading the preview")
+				return
+			}
+
+			p.display(preview)
+		})
+	}()
+}
+
+// Clear stops loading and empties the preview
+func (p *Preview) Clear() {
+	p.cancel()
+	p.nameLbl.SetText("Preview")
+	p.infoLbl.SetText("Select a file to preview it")
+	p.buffer.SetText("")
+}
+
+func (p *Preview) display(preview *textpreview.Preview) {
+	if preview.Binary {
+		p.infoLbl.SetText("Binary file, no text preview available")
+		return
+	}
+
+	info := preview.Encoding
+	if preview.Truncated {
+		info = fmt.Sprintf("%s, first %s", info, humanize.IBytes(textpreview.DefaultMaxBytes))
+	}
+	p.infoLbl.SetText(info)
+	p.buffer.SetText(preview.Text)
+
+	p.find(false)
+}
+
+// find selects the next match of the search text, from the start of the
+// preview or after the current match, wrapping around
+func (p *Preview) find(next bool) {
+	text, _ := p.searchEntry.GetText()
+	if text == "" {
+		return
+	}
+
+	start := p.buffer.GetStartIter()
+	if next {
+		if _, end, ok := p.buffer.GetSelectionBounds(); ok {
+			start = end
+		}
+	}
+
+	mstart, mend, ok := start.ForwardSearch(text, gtk.TEXT_SEARCH_CASE_INSENSITIVE, nil)
+	if !ok && next {
+		mstart, mend, ok = p.buffer.GetStartIter().ForwardSearch(text, gtk.TEXT_SEARCH_CASE_INSENSITIVE, nil)
+	}
+	if !ok {
+		return
+	}
+
+	p.buffer.SelectRange(mstart, mend)
+	p.textView.ScrollToIter(mstart, 0.1, false, 0, 0)
+}
@@ -1,10 +1,24 @@
 package settings
 
 import (
+	"fmt"
+
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/config"
+	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/players"
+	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/component"
 )
 
+var kindLabels = map[players.Kind]string{
+	players.Video: "Video",
+	players.Audio: "Audio",
+	players.Image: "Image viewer",
+	players.PDF:   "PDF viewer",
+	players.Other: "Other files",
+}
+
 type Settings struct {
 	*gtk.Box
 	*component.Component
@@ -14,6 +28,17 @@ func New() *Settings {
 	s := &Settings{Component: component.New("/ui/settings")}
 	s.Box = s.GladeWidget("container").(*gtk.Box)
 
+	grid := s.GladeWidget("playersGrid").(*gtk.Grid)
+	for i, kind := range players.Kinds {
+		lbl, _ := gtk.LabelNew(kindLabels[kind])
+		lbl.SetXAlign(0)
+		grid.Attach(lbl, 0, i+1, 1, 1)
+		grid.Attach(playerCombo(kind), 1, i+1, 1, 1)
+	}
+	grid.ShowAll()
+
+	populateTagRules(s.GladeWidget("tagRulesBox").(*gtk.Box))
+
 	btn := s.GladeWidget("testBTN").(*gtk.Button)
 	btn.Connect("clicked", func() bool {
 		img, _ := gtk.ImageNewFromResource("/ui/behappy")
@@ -26,3 +51,111 @@ func New() *Settings {
 
 	return s
 }
+
+// playerCombo lets the user pick one of the default command templates for
+// the kind of file, or type a custom one.
+//
+// The selected template is saved first, followed by the defaults as
+// fallbacks. Custom templates are saved when Enter is pressed or the entry
+// loses focus.
+func playerCombo(kind players.Kind) *gtk.ComboBoxText {
+	combo, _ := gtk.ComboBoxTextNewWithEntry()
+	combo.SetHExpand(true)
+
+	templates := append([]string{}, players.Defaults[kind]...)
+	selected := players.Templates(kind)[0]
+	active := -1
+	for i, t := range templates {
+		if t == selected {
+			active = i
+		}
+	}
+	if active < 0 {
+		templates = append([]string{selected}, templates...)
+		active = 0
+	}
+	for _, t := range templates {
+		combo.AppendText(t)
+	}
+	combo.SetActive(active)
+
+	saved := selected
+	save := func() {
+		t := combo.GetActiveText()
+		if t == "" || t == saved {
+			return
+		}
+		saved = t
+
+		selected := []string{t}
+		for _, d := range players.Defaults[kind] {
+			if d != t {
+				selected = append(selected, d)
+			}
+		}
+		config.Get().SetPlayers(string(kind), selected)
+	}
+
+	// changed is emitted on every keystroke too, custom templates are saved
+	// once entered
+	combo.Connect("changed", func() {
+		if combo.GetActive() >= 0 {
+			save()
+		}
+	})
+	entry, err := combo.GetEntry()
+	if err != nil {
+		logger.Error(err, "error getting the player entry")
+		return combo
+	}
+	entry.Connect("activate", save)
+	entry.Connect("focus-out-event", func() bool {
+		save()
+		return false
+	})
+
+	return combo
+}
+
+// populateTagRules lists the tag rules of the current repository, with a
+// button to remove them
+func populateTagRules(box *gtk.Box) {
+	repoID := config.Get().PreferredRepo()
+	if repoID == "" {
+		return
+	}
+
+	rules, err := tags.Rules(repoID)
+	if err != nil {
+		logger.Error(err, "error loading tag rules")
+		return
+	}
+
+	if len(rules) == 0 {
+		lbl, _ := gtk.LabelNew("No tag rules. Use \"Always tag matching files\" in the search results menu to add one.")
+		lbl.SetXAlign(0)
+		box.Add(lbl)
+	}
+
+	for _, r := range rules {
+		r := r
+		row, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 12)
+		lbl, _ := gtk.LabelNew(fmt.Sprintf("%s → %s", r.Query, r.Tag))
+		lbl.SetXAlign(0)
+		lbl.SetHExpand(true)
+		row.Add(lbl)
+
+		btn, _ := gtk.ButtonNewWithLabel("Remove")
+		btn.Connect("clicked", func() {
+			if err := tags.RemoveRule(repoID, r.Query, r.Tag); err != nil {
+				logger.Error(err, "error removing tag rule")
+				return
+			}
+			row.Destroy()
+		})
+		row.Add(btn)
+		box.Add(row)
+	}
+
+	box.ShowAll()
+}
@@ -1,27 +1,45 @@
 package tagger
 
 import (
+	"context"
+
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/component"
 	"github.com/swampapp/swamp/internal/ui/util"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
+// TagsChangedEvent is emitted with the IDs of the files whose tags changed
+const TagsChangedEvent = "tagger.tags_changed"
+
+func init() {
+	eventbus.RegisterEvents(TagsChangedEvent)
+}
+
 type Tagger struct {
 	*component.Component
 	*gtk.Box
 	listStore *gtk.ListStore
 	treeView  *gtk.TreeView
-	fileID    string
+	fileIDs   []string
+	// tags of the files when the tagger was opened
+	initial map[string]struct{}
 }
 
-func New(fileID string) *Tagger {
+// New returns a tagger editing the tags of the files.
+//
+// Every tag found in any of the files is listed. Tags added are added to
+// every file, tags removed are removed from every file.
+func New(fileIDs []string) *Tagger {
 	t := &Tagger{
 		Component: component.New("/ui/tagger"),
-		fileID:    fileID,
+		fileIDs:   fileIDs,
+		initial:   map[string]struct{}{},
 	}
 	t.Box = t.GladeWidget("component").(*gtk.Box)
 
@@ -95,41 +113,74 @@ func (t *Tagger) removeSelected() {
 }
 
 func (t *Tagger) saveTags() {
-	var tl []tags.Tag
+	current := map[string]struct{}{}
 	t.listStore.ForEach(func(model *gtk.TreeModel, path *gtk.TreePath, iter *gtk.TreeIter) bool {
 		value, _ := t.listStore.GetValue(iter, 0)
 		tname, _ := value.GetString()
-		tag := tags.Tag{Name: tname}
-		tl = append(tl, tag)
+		current[tname] = struct{}{}
 		return false
 	})
-	err := tags.Save(t.fileID, tl)
-	if err != nil {
-		logger.Errorf(err, "error saving tags for %s", t.fileID)
-	} else {
-		logger.Infof("saved tags for %s", t.fileID)
+
+	changed := false
+	for name := range current {
+		if _, ok := t.initial[name]; ok {
+			continue
+		}
+		changed = true
+		if err := tags.Add(t.fileIDs, tags.Tag{Name: name}); err != nil {
+			logger.Errorf(err, "error adding tag %s", name)
+		}
+	}
+
+	for name := range t.initial {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		changed = true
+		if err := tags.Remove(t.fileIDs, name); err != nil {
+			logger.Errorf(err, "error removing tag %s", name)
+		}
 	}
+
+	t.initial = current
+	if !changed {
+		return
+	}
+	logger.Infof("saved tags for %d files", len(t.fileIDs))
+	eventbus.Emit(context.Background(), TagsChangedEvent, t.fileIDs)
 }
 
 func (t *Tagger) populate() {
 	t.listStore.Clear()
 
-	tl, err := tags.For(t.fileID)
-	if err != nil {
-		logger.Errorf(err, "error loading tags for %s", t.fileID)
-		return
-	}
-	for _, tag := range tl {
-		logger.Infof("populating with tag %s", tag.Name)
-		iter := t.listStore.Append()
-		t.listStore.Set(iter,
-			[]int{0},
-			[]interface{}{tag.Name})
+	for _, fid := range t.fileIDs {
+		tl, err := tags.For(fid)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			logger.Errorf(err, "error loading tags for %s", fid)
+			return
+		}
+		for _, tag := range tl {
+			if _, ok := t.initial[tag.Name]; ok {
+				continue
+			}
+			t.initial[tag.Name] = struct{}{}
+			iter := t.listStore.Append()
+			t.listStore.Set(iter,
+				[]int{0},
+				[]interface{}{tag.Name})
+		}
 	}
 }
 
 // Append a row to the list store for the tree view
 func (t *Tagger) AddTag(tag string) {
+	tag = tags.Normalize(tag)
+	if tag == "" {
+		return
+	}
 	iter := t.listStore.Append()
 
 	// Set the contents of the list store row that the iterator represents
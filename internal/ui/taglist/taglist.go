@@ -3,15 +3,19 @@ package taglist
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/eventbus"
 	"github.com/swampapp/swamp/internal/logger"
 	"github.com/swampapp/swamp/internal/resources"
+	"github.com/swampapp/swamp/internal/status"
 	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/component"
 	"github.com/swampapp/swamp/internal/ui/util"
@@ -21,7 +25,7 @@ type TagList struct {
 	*component.Component
 	*gtk.Box
 	treeView       *gtk.TreeView
-	listStore      *gtk.ListStore
+	treeStore      *gtk.TreeStore
 	tagActionImage *gdk.Pixbuf
 	searchEntry    *gtk.SearchEntry
 }
@@ -34,6 +38,9 @@ const (
 	COLUMN_ICON ColID = iota
 	COLUMN_NAME
 	COLUMN_COLOR
+	COLUMN_FILES
+	// full name of the tag, the name column displays the last level
+	COLUMN_TAG
 )
 
 // Creates a tree view and the list store that holds its data
@@ -63,11 +70,12 @@ func (t *TagList) setup() {
 	t.treeView.AppendColumn(util.CreateImageColumn("", int(COLUMN_ICON)))
 	t.treeView.AppendColumn(util.CreateColumn("Name", int(COLUMN_NAME), 80))
 	t.treeView.AppendColumn(util.CreateColumn("Color", int(COLUMN_COLOR), 40))
+	t.treeView.AppendColumn(util.CreateColumn("Files", int(COLUMN_FILES), 40))
 	t.treeView.SetEnableSearch(false)
 
 	// Creating a list store. This is what holds the data that will be shown on our tree view.
-	t.listStore, _ = gtk.ListStoreNew(glib.TYPE_OBJECT, glib.TYPE_STRING, glib.TYPE_STRING)
-	t.treeView.SetModel(t.listStore)
+	t.treeStore, _ = gtk.TreeStoreNew(glib.TYPE_OBJECT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_INT, glib.TYPE_STRING)
+	t.treeView.SetModel(t.treeStore)
 	t.treeView.Connect("row-activated", t.rowActivated)
 	t.treeView.Connect("realize", t.isShown)
 
@@ -88,7 +96,7 @@ func (t *TagList) setup() {
 		btn := gdk.EventButtonNewFromEvent(ev)
 		switch btn.Button() {
 		case gdk.BUTTON_SECONDARY:
-			secondButtonPressed(t.treeView, btn)
+			t.secondButtonPressed(btn)
 			return true
 		default:
 			return false
@@ -96,39 +104,345 @@ func (t *TagList) setup() {
 	})
 }
 
-func secondButtonPressed(treeview *gtk.TreeView, btn *gdk.EventButton) {
+func (t *TagList) secondButtonPressed(btn *gdk.EventButton) {
+	menu, _ := gtk.MenuNew()
+
+	path, _, _, _, ok := t.treeView.GetPathAtPos(int(btn.X()), int(btn.Y()))
+	if ok {
+		iter, err := t.treeStore.GetIter(path)
+		if err != nil {
+			return
+		}
+		value, _ := t.treeStore.GetValue(iter, int(COLUMN_TAG))
+		tag, _ := value.GetString()
+		// levels without a tag of their own have no files
+		value, _ = t.treeStore.GetValue(iter, int(COLUMN_FILES))
+		v, _ := value.GoValue()
+		files, _ := v.(int)
+		t.addTagItems(menu, tag, files > 0)
+		sep, _ := gtk.SeparatorMenuItemNew()
+		menu.Add(sep)
+	}
+
+	item, _ := gtk.MenuItemNewWithLabel("Export tags...")
+	item.Connect("activate", func() bool {
+		t.exportTags()
+		return true
+	})
+	menu.Add(item)
+
+	item, _ = gtk.MenuItemNewWithLabel("Import tags...")
+	item.Connect("activate", func() bool {
+		t.importTags()
+		return true
+	})
+	menu.Add(item)
+
+	item, _ = gtk.MenuItemNewWithLabel("Relink orphaned tags")
+	item.Connect("activate", func() bool {
+		t.relinkTags()
+		return true
+	})
+	menu.Add(item)
+
+	menu.ShowAll()
+	menu.PopupAtPointer(btn.Event)
+}
+
+// addTagItems adds the actions on a single tag to the menu. Renaming and
+// merging move the tags below it too, the color and deletion need a tag of
+// its own.
+func (t *TagList) addTagItems(menu *gtk.Menu, tag string, own bool) {
+	item, _ := gtk.MenuItemNewWithLabel("Rename")
+	item.Connect("activate", func() bool {
+		t.renameTag(tag)
+		return true
+	})
+	menu.Add(item)
+
+	item, _ = gtk.MenuItemNewWithLabel("Merge into...")
+	item.Connect("activate", func() bool {
+		t.mergeTag(tag)
+		return true
+	})
+	menu.Add(item)
+
+	if !own {
+		return
+	}
+
+	item, _ = gtk.MenuItemNewWithLabel("Change color")
+	item.Connect("activate", func() bool {
+		t.recolorTag(tag)
+		return true
+	})
+	menu.Add(item)
+
+	item, _ = gtk.MenuItemNewWithLabel("Delete")
+	item.Connect("activate", func() bool {
+		t.deleteTag(tag)
+		return true
+	})
+	menu.Add(item)
+}
+
+func (t *TagList) exportTags() {
+	fc, _ := gtk.FileChooserNativeDialogNew("Export tags", nil, gtk.FILE_CHOOSER_ACTION_SAVE, "_Save", "_Cancel")
+	fc.SetDoOverwriteConfirmation(true)
+	fc.SetCurrentName("swamp-tags.json")
+	if gtk.ResponseType(fc.NativeDialog.Run()) != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	fname := fc.GetFilename()
+
+	// exporting walks the index, away from the GTK thread
+	status.Set("Exporting tags...")
+	go func() {
+		n, err := exportFile(fname)
+		if err != nil {
+			status.Error("error exporting tags: " + err.Error())
+			return
+		}
+		status.Set(fmt.Sprintf("%d tagged files exported to %s", n, fname))
+	}()
+}
+
+// exportFile writes the tags of the preferred repository to fname, as CSV
+// if it has the .csv extension or JSON otherwise, returning the number of
+// files exported
+func exportFile(fname string) (int, error) {
+	e, err := tags.NewExport(config.Get().PreferredRepo())
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(fname)) == ".csv" {
+		err = e.WriteCSV(f)
+	} else {
+		err = e.WriteJSON(f)
+	}
+
+	return len(e.Files), err
 }
 
+func (t *TagList) importTags() {
+	fc, _ := gtk.FileChooserNativeDialogNew("Import tags", nil, gtk.FILE_CHOOSER_ACTION_OPEN, "_Open", "_Cancel")
+	if gtk.ResponseType(fc.NativeDialog.Run()) != gtk.RESPONSE_ACCEPT {
+		return
+	}
+	fname := fc.GetFilename()
+
+	status.Set("Importing tags...")
+	go func() {
+		e, err := tags.ReadFile(fname)
+		if err != nil {
+			status.Error("error reading tags: " + err.Error())
+			return
+		}
+
+		result, err := tags.Import(config.Get().PreferredRepo(), e)
+		if err != nil {
+			status.Error("error importing tags: " + err.Error())
+			return
+		}
+		status.Set(fmt.Sprintf(
+			"Tags imported: %d files matched, %d relinked, %d not found in the index",
+			result.Matched,
+			result.Relinked,
+			len(result.Unmatched),
+		))
+		glib.IdleAdd(t.refresh)
+	}()
+}
+
+func (t *TagList) renameTag(tag string) {
+	name, ok := util.Prompt("Rename tag", fmt.Sprintf("New name for '%s':", tag))
+	if !ok {
+		return
+	}
+
+	if err := tags.Rename(tag, name); err != nil {
+		status.Error("error renaming tag: " + err.Error())
+		return
+	}
+	status.Set(fmt.Sprintf("Tag %s renamed to %s", tag, name))
+	t.refresh()
+}
+
+func (t *TagList) mergeTag(tag string) {
+	into, ok := util.Prompt("Merge tag", fmt.Sprintf("Tag to merge '%s' into:", tag))
+	if !ok {
+		return
+	}
+
+	if err := tags.Merge(tag, into); err != nil {
+		status.Error("error merging tags: " + err.Error())
+		return
+	}
+	status.Set(fmt.Sprintf("Tag %s merged into %s", tag, into))
+	t.refresh()
+}
+
+func (t *TagList) recolorTag(tag string) {
+	d, _ := gtk.ColorChooserDialogNew("Tag color", nil)
+	defer d.Destroy()
+	if d.Run() != gtk.RESPONSE_OK {
+		return
+	}
+
+	c := d.GetRGBA().Floats()
+	color := fmt.Sprintf("#%02x%02x%02x", int(c[0]*255), int(c[1]*255), int(c[2]*255))
+	if err := tags.SetColor(tag, color); err != nil {
+		status.Error("error changing the tag color: " + err.Error())
+		return
+	}
+	t.refresh()
+}
+
+func (t *TagList) deleteTag(tag string) {
+	d := gtk.MessageDialogNew(
+		nil,
+		gtk.DIALOG_MODAL,
+		gtk.MESSAGE_QUESTION,
+		gtk.BUTTONS_YES_NO,
+		"Remove the tag '%s' from every file?",
+		tag,
+	)
+	defer d.Destroy()
+	if d.Run() != gtk.RESPONSE_YES {
+		return
+	}
+
+	if err := tags.Delete(tag); err != nil {
+		status.Error("error deleting tag: " + err.Error())
+		return
+	}
+	status.Set(fmt.Sprintf("Tag %s deleted", tag))
+	t.refresh()
+}
+
+// relinkTags moves the tags of files not found in the index to the indexed
+// files with the same content and path, reporting the result once done
+func (t *TagList) relinkTags() {
+	status.Set("Relinking orphaned tags...")
+	go func() {
+		report, err := tags.Relink(config.Get().PreferredRepo())
+		if err != nil {
+			status.Error("error relinking tags: " + err.Error())
+			return
+		}
+		status.Set("")
+		glib.IdleAdd(func() {
+			t.showRelinkReport(report)
+		})
+	}()
+}
+
+func (t *TagList) showRelinkReport(report tags.RelinkReport) {
+	msg := fmt.Sprintf("%d orphaned files recovered, %d not found in the index.",
+		len(report.Recovered), len(report.Dangling))
+	for i, o := range report.Dangling {
+		if i == 10 {
+			msg += fmt.Sprintf("\n...and %d more", len(report.Dangling)-i)
+			break
+		}
+		path := o.Path
+		if path == "" {
+			path = o.FileID
+		}
+		msg += "\n" + path
+	}
+
+	d := gtk.MessageDialogNew(nil, gtk.DIALOG_MODAL, gtk.MESSAGE_INFO, gtk.BUTTONS_OK, "%s", msg)
+	defer d.Destroy()
+	d.Run()
+	t.refresh()
+}
+
+// refresh lists the tags again, keeping the search filter
+func (t *TagList) refresh() {
+	txt, _ := t.searchEntry.GetText()
+	if txt == "" {
+		txt = "*"
+	}
+	t.updateFileList(txt)
+}
+
+// updateFileList lists the tags matching the query as a tree, along with
+// the tags above them
 func (t *TagList) updateFileList(query string) {
 	logger.Print("taglist: searching for ", query)
-	t.listStore.Clear()
+	t.treeStore.Clear()
 
-	tags, err := tags.All()
+	counts, err := tags.Counts()
 	if err != nil {
 		logger.Error(err, "error listing tags")
 		return
 	}
 
-	for _, tag := range tags {
-		match, _ := filepath.Match(fmt.Sprintf("*%s*", strings.ToLower(query)), strings.ToLower(tag.Name))
-		if query == "*" || match {
-			t.addTagRow(tag.Name, tag.Color)
+	all := map[string]tags.TagCount{}
+	for _, c := range counts {
+		all[c.Name] = c
+	}
+
+	shown := map[string]tags.TagCount{}
+	for _, c := range counts {
+		match, _ := filepath.Match(fmt.Sprintf("*%s*", strings.ToLower(query)), strings.ToLower(c.Name))
+		if query != "*" && !match {
+			continue
+		}
+		shown[c.Name] = c
+		// levels without a tag of their own are listed too
+		for p := tags.Parent(c.Name); p != ""; p = tags.Parent(p) {
+			if a, ok := all[p]; ok {
+				shown[p] = a
+			} else {
+				shown[p] = tags.TagCount{Tag: tags.Tag{Name: p}}
+			}
 		}
 	}
+
+	names := make([]string, 0, len(shown))
+	for name := range shown {
+		names = append(names, name)
+	}
+	// parents sort before their children
+	sort.Strings(names)
+
+	iters := map[string]*gtk.TreeIter{}
+	for _, name := range names {
+		iters[name] = t.addTagRow(iters[tags.Parent(name)], shown[name])
+	}
+
+	if query != "*" && query != "" {
+		t.treeView.ExpandAll()
+	}
 }
 
-func (t *TagList) addTagRow(name, color string) {
-	// Get an iterator for a new row at the end of the list store
-	iter := t.listStore.Append()
+// addTagRow adds the tag below parent, at the top level if parent is nil
+func (t *TagList) addTagRow(parent *gtk.TreeIter, tag tags.TagCount) *gtk.TreeIter {
+	iter := t.treeStore.Append(parent)
 
-	// properly sorted when clicking the column
-	err := t.listStore.Set(iter,
-		[]int{int(COLUMN_ICON), int(COLUMN_NAME), int(COLUMN_COLOR)},
-		[]interface{}{t.tagActionImage, name, color})
-	if err != nil {
-		logger.Print("Unable to add row")
-		panic(err)
+	values := map[ColID]interface{}{
+		COLUMN_ICON:  t.tagActionImage,
+		COLUMN_NAME:  tags.Base(tag.Name),
+		COLUMN_COLOR: tag.Color,
+		COLUMN_FILES: tag.Files,
+		COLUMN_TAG:   tag.Name,
+	}
+	for col, v := range values {
+		if err := t.treeStore.SetValue(iter, int(col), v); err != nil {
+			logger.Error(err, "unable to set tag row value")
+		}
 	}
+
+	return iter
 }
 
 func (t *TagList) isShown(tree *gtk.TreeView) {
@@ -137,9 +451,14 @@ func (t *TagList) isShown(tree *gtk.TreeView) {
 }
 
 func (t *TagList) rowActivated(tree *gtk.TreeView, path *gtk.TreePath, col *gtk.TreeViewColumn) {
-	iter, _ := t.listStore.GetIter(path)
-	value, _ := t.listStore.GetValue(iter, int(COLUMN_NAME))
+	iter, _ := t.treeStore.GetIter(path)
+	value, _ := t.treeStore.GetValue(iter, int(COLUMN_TAG))
 	tag, _ := value.GetString()
 
+	// tags with others below them select the whole subtree
+	if t.treeStore.IterHasChild(iter) {
+		tag = tags.SubtreeQuery(tag)
+	}
+
 	eventbus.Emit(context.Background(), TagSelectedEvent, tag)
 }
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"strings"
+
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/gotk3/gotk3/pango"
 )
@@ -31,3 +33,33 @@ func CreateImageColumn(title string, id int) *gtk.TreeViewColumn {
 
 	return column
 }
+
+// Prompt asks for a line of text, returning false if cancelled or empty
+func Prompt(title, message string) (string, bool) {
+	d, _ := gtk.DialogNew()
+	defer d.Destroy()
+	d.SetTitle(title)
+	d.SetModal(true)
+	d.AddButton("_Cancel", gtk.RESPONSE_CANCEL)
+	d.AddButton("_OK", gtk.RESPONSE_OK)
+	d.SetDefaultResponse(gtk.RESPONSE_OK)
+
+	box, _ := d.GetContentArea()
+	box.SetSpacing(6)
+	box.SetBorderWidth(12)
+	lbl, _ := gtk.LabelNew(message)
+	lbl.SetXAlign(0)
+	box.Add(lbl)
+	entry, _ := gtk.EntryNew()
+	entry.SetActivatesDefault(true)
+	box.Add(entry)
+	d.ShowAll()
+
+	if d.Run() != gtk.RESPONSE_OK {
+		return "", false
+	}
+
+	text, _ := entry.GetText()
+	text = strings.TrimSpace(text)
+	return text, text != ""
+}
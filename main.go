@@ -11,11 +11,14 @@ import (
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
+	"github.com/swampapp/swamp/internal/annotations"
 	"github.com/swampapp/swamp/internal/config"
 	"github.com/swampapp/swamp/internal/credentials"
 	"github.com/swampapp/swamp/internal/logger"
+	"github.com/swampapp/swamp/internal/mpris"
 	"github.com/swampapp/swamp/internal/paths"
 	"github.com/swampapp/swamp/internal/resources"
+	"github.com/swampapp/swamp/internal/tags"
 	"github.com/swampapp/swamp/internal/ui/assistant"
 	"github.com/swampapp/swamp/internal/ui/mainwindow"
 	"github.com/swampapp/swamp/internal/version"
@@ -47,6 +50,10 @@ func main() {
 
 	resources.InitResources()
 
+	if err := mpris.Start(); err != nil {
+		logger.Error(err, "error registering the MPRIS player")
+	}
+
 	logger.Print("starting app")
 
 	gtk.Init(nil)
@@ -68,7 +75,14 @@ func main() {
 		}
 	})
 
-	if exitCode := app.Run(os.Args); exitCode > 0 {
+	exitCode := app.Run(os.Args)
+	if err := tags.Close(); err != nil {
+		logger.Error(err, "error closing the tags database")
+	}
+	if err := annotations.Close(); err != nil {
+		logger.Error(err, "error closing the annotations database")
+	}
+	if exitCode > 0 {
 		os.Exit(exitCode)
 	}
 }